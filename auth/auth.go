@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+type Scope string
+
+const (
+	// ScopeRead allows queries and subscriptions
+	ScopeRead Scope = "READ"
+	// ScopeWrite allows mutations on jobs
+	ScopeWrite Scope = "WRITE"
+	// ScopeAdmin allows everything, including managing keys
+	ScopeAdmin Scope = "ADMIN"
+)
+
+// keyPrefix tells API keys apart from JWTs
+const keyPrefix = "qron_"
+
+// Principal is the authenticated caller
+type Principal struct {
+	// Subject is the key id or the JWT subject
+	Subject string
+	Owner   string
+	Scopes  []Scope
+	// Executors the caller is restricted to. Empty means any
+	Executors []string
+	// Roles restrict the caller to the operations granted to them
+	// by policies. Only scopes and executors are checked when empty
+	Roles []string
+}
+
+// HasScope reports whether p is granted `scope`.
+// Admins are granted every scope
+func (p Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccess reports whether p is allowed to access jobs of `executor`
+func (p Principal) CanAccess(executor string) bool {
+	if len(p.Executors) == 0 {
+		return true
+	}
+	for _, e := range p.Executors {
+		if e == executor {
+			return true
+		}
+	}
+	return false
+}
+
+type principalCtx struct{}
+
+var key = principalCtx{}
+
+func NewCtx(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, key, principal)
+}
+
+// FromCtx returns the authenticated caller. `ok` is false
+// for callers not going through the auth middleware
+func FromCtx(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(key).(Principal)
+	return principal, ok
+}
+
+// NewKey generates an API key. Only its hash is meant to be
+// stored, the prefix is kept to tell keys apart
+func NewKey() (key, prefix, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:len(keyPrefix)+6], HashKey(key), nil
+}
+
+// HashKey hashes an API key for lookup. Keys are random
+// enough for a plain hash to be safe
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func isKey(token string) bool {
+	return strings.HasPrefix(token, keyPrefix)
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestPrincipal(t *testing.T) {
+	t.Run("Should grant every scope to admins", func(t *testing.T) {
+		admin := Principal{Scopes: []Scope{ScopeAdmin}}
+		assert.True(t, admin.HasScope(ScopeRead))
+		assert.True(t, admin.HasScope(ScopeWrite))
+
+		reader := Principal{Scopes: []Scope{ScopeRead}}
+		assert.True(t, reader.HasScope(ScopeRead))
+		assert.False(t, reader.HasScope(ScopeWrite))
+		assert.False(t, reader.HasScope(ScopeAdmin))
+	})
+
+	t.Run("Should restrict executors when set", func(t *testing.T) {
+		assert.True(t, Principal{}.CanAccess("email"))
+
+		restricted := Principal{Executors: []string{"email"}}
+		assert.True(t, restricted.CanAccess("email"))
+		assert.False(t, restricted.CanAccess("sms"))
+	})
+}
+
+func TestKey(t *testing.T) {
+	key, prefix, hash, err := NewKey()
+	assert.Nil(t, err)
+	assert.True(t, isKey(key))
+	assert.Contains(t, key, prefix)
+	assert.Equal(t, HashKey(key), hash)
+	assert.NotContains(t, hash, key)
+
+	other, _, _, err := NewKey()
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestJWT(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	jwk := jose.JSONWebKey{Key: private, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"}
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jwk},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	assert.Nil(t, err)
+
+	authenticator := New(nil, Config{
+		JWKS:     &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+		Issuer:   "qron-test",
+		Audience: "qron",
+	})
+
+	sign := func(claims jwt.Claims, custom map[string]interface{}) string {
+		token, err := jwt.Signed(signer).Claims(claims).Claims(custom).CompactSerialize()
+		assert.Nil(t, err)
+		return token
+	}
+	valid := jwt.Claims{
+		Subject:  "alice",
+		Issuer:   "qron-test",
+		Audience: jwt.Audience{"qron"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+
+	t.Run("Should authenticate valid token", func(t *testing.T) {
+		token := sign(valid, map[string]interface{}{
+			"scope":     "read write",
+			"executors": []string{"email"},
+		})
+
+		principal, err := authenticator.Authenticate(context.Background(), token)
+		assert.Nil(t, err)
+		assert.Equal(t, "alice", principal.Subject)
+		assert.Equal(t, "alice", principal.Owner)
+		assert.Equal(t, []Scope{ScopeRead, ScopeWrite}, principal.Scopes)
+		assert.Equal(t, []string{"email"}, principal.Executors)
+	})
+
+	t.Run("Should reject invalid claims", func(t *testing.T) {
+		expired := valid
+		expired.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+		_, err := authenticator.Authenticate(context.Background(), sign(expired, nil))
+		assert.NotNil(t, err)
+
+		wrongIssuer := valid
+		wrongIssuer.Issuer = "someone-else"
+		_, err = authenticator.Authenticate(context.Background(), sign(wrongIssuer, nil))
+		assert.NotNil(t, err)
+
+		wrongAudience := valid
+		wrongAudience.Audience = jwt.Audience{"other"}
+		_, err = authenticator.Authenticate(context.Background(), sign(wrongAudience, nil))
+		assert.NotNil(t, err)
+
+		noExpiry := valid
+		noExpiry.Expiry = nil
+		_, err = authenticator.Authenticate(context.Background(), sign(noExpiry, nil))
+		assert.NotNil(t, err)
+
+		noIssuer := valid
+		noIssuer.Issuer = ""
+		_, err = authenticator.Authenticate(context.Background(), sign(noIssuer, nil))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should reject JWTs without configured issuer", func(t *testing.T) {
+		anyIssuer := New(nil, Config{
+			JWKS: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+		})
+		_, err := anyIssuer.Authenticate(context.Background(), sign(valid, nil))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should reject tokens signed by unknown keys", func(t *testing.T) {
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.Nil(t, err)
+		otherSigner, err := jose.NewSigner(jose.SigningKey{
+			Algorithm: jose.RS256,
+			Key:       jose.JSONWebKey{Key: other, KeyID: "test"},
+		}, nil)
+		assert.Nil(t, err)
+
+		token, err := jwt.Signed(otherSigner).Claims(valid).CompactSerialize()
+		assert.Nil(t, err)
+		_, err = authenticator.Authenticate(context.Background(), token)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should read owner from configured claim", func(t *testing.T) {
+		tenant := New(nil, Config{
+			JWKS:       &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}},
+			Issuer:     "qron-test",
+			OwnerClaim: "tenant",
+		})
+
+		principal, err := tenant.Authenticate(context.Background(), sign(valid, map[string]interface{}{
+			"tenant": "acme",
+		}))
+		assert.Nil(t, err)
+		assert.Equal(t, "acme", principal.Owner)
+
+		_, err = tenant.Authenticate(context.Background(), sign(valid, nil))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should reject requests without bearer token", func(t *testing.T) {
+		handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := FromCtx(r.Context())
+			assert.True(t, ok)
+			assert.Equal(t, "alice", principal.Owner)
+		}))
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+		req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		req.Header.Set("authorization", "Bearer "+sign(valid, nil))
+		res = httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+
+	t.Run("Should authenticate websockets on init", func(t *testing.T) {
+		var ctx context.Context
+		handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx = r.Context()
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/graphql", nil)
+		req.Header.Set("upgrade", "websocket")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// Denied everything until init
+		principal, ok := FromCtx(ctx)
+		assert.True(t, ok)
+		assert.False(t, principal.HasScope(ScopeRead))
+
+		_, err := authenticator.InitFunc(ctx, transport.InitPayload{})
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+
+		_, err = authenticator.InitFunc(ctx, transport.InitPayload{"authorization": "Bearer invalid"})
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+
+		initCtx, err := authenticator.InitFunc(ctx, transport.InitPayload{
+			"authorization": "Bearer " + sign(valid, map[string]interface{}{"scope": "read"}),
+		})
+		assert.Nil(t, err)
+		principal, ok = FromCtx(initCtx)
+		assert.True(t, ok)
+		assert.Equal(t, "alice", principal.Owner)
+		assert.True(t, principal.HasScope(ScopeRead))
+	})
+}
+
+func TestExtension(t *testing.T) {
+	resolve := func(ctx context.Context, object, field string, args map[string]interface{}) error {
+		ctx = graphql.WithFieldContext(ctx, &graphql.FieldContext{
+			Object: object,
+			Field:  graphql.CollectedField{Field: &ast.Field{Name: field}},
+			Args:   args,
+		})
+		_, err := Extension{}.InterceptField(ctx, func(ctx context.Context) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	reader := NewCtx(context.Background(), Principal{
+		Scopes:    []Scope{ScopeRead},
+		Executors: []string{"email"},
+	})
+	email := map[string]interface{}{"executor": "email"}
+
+	assert.Nil(t, resolve(reader, "Query", "searchJobs", email))
+	assert.ErrorIs(t, resolve(reader, "Query", "searchJobs", map[string]interface{}{"executor": "sms"}), ErrForbidden)
+	assert.ErrorIs(t, resolve(reader, "Mutation", "createJob", email), ErrForbidden)
+	assert.ErrorIs(t, resolve(reader, "Query", "apiKeys", nil), ErrForbidden)
+
+	// Executors are shared by every owner
+	writer := NewCtx(context.Background(), Principal{Scopes: []Scope{ScopeRead, ScopeWrite}})
+	assert.Nil(t, resolve(writer, "Mutation", "createJob", email))
+	assert.ErrorIs(t, resolve(writer, "Mutation", "pauseExecutor", email), ErrForbidden)
+	assert.ErrorIs(t, resolve(writer, "Mutation", "resumeExecutor", email), ErrForbidden)
+	assert.Nil(t, resolve(reader, "TinyJob", "id", nil))
+	assert.Nil(t, resolve(context.Background(), "Mutation", "createJob", email))
+}
+
+func TestDeniedError(t *testing.T) {
+	var err error = &DeniedError{
+		Subject:   "42",
+		Executor:  "billing",
+		Operation: OperationDelete,
+		Reason:    "no policy grants it",
+	}
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.Equal(t, "forbidden: DELETE on billing denied: no policy grants it", err.Error())
+
+	var denied *DeniedError
+	assert.ErrorAs(t, err, &denied)
+	assert.Equal(t, "FORBIDDEN", denied.Extensions()["code"])
+
+	// Checked before policies, so no database is needed
+	authorizer := NewAuthorizer(nil, nil)
+	ctx := NewCtx(context.Background(), Principal{
+		Scopes:    []Scope{ScopeRead},
+		Executors: []string{"email"},
+	})
+	allowed, err := authorizer.Allowed(ctx, "email", OperationRead)
+	assert.Nil(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = authorizer.Allowed(ctx, "email", OperationCreate)
+	assert.Nil(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = authorizer.Allowed(ctx, "sms", OperationRead)
+	assert.Nil(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = authorizer.Allowed(context.Background(), "sms", OperationAdmin)
+	assert.Nil(t, err)
+	assert.True(t, allowed)
+}
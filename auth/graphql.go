@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// adminFields are the top level fields only admins can resolve
+var adminFields = map[string]bool{
+	"apiKeys":      true,
+	"issueApiKey":  true,
+	"revokeApiKey": true,
+	"policies":     true,
+	"auditLog":     true,
+	"grantPolicy":  true,
+	"revokePolicy": true,
+	// Executors are shared by every owner
+	"pauseExecutor":  true,
+	"resumeExecutor": true,
+}
+
+var rootObjects = map[string]bool{
+	"Query":        true,
+	"Mutation":     true,
+	"Subscription": true,
+}
+
+// Extension checks the scopes and executors of the principal
+// before resolving top level GraphQL fields. Queries and
+// subscriptions need READ, mutations need WRITE.
+// Requests without a principal are not checked.
+// Policies are evaluated by the resolvers
+type Extension struct {
+	// Authorizer records denials in the audit log when set
+	Authorizer *Authorizer
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.FieldInterceptor
+} = Extension{}
+
+func (Extension) ExtensionName() string {
+	return "Auth"
+}
+
+func (Extension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e Extension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !rootObjects[fc.Object] {
+		return next(ctx)
+	}
+
+	principal, ok := FromCtx(ctx)
+	if !ok {
+		return next(ctx)
+	}
+
+	op := OperationRead
+	switch {
+	case adminFields[fc.Field.Name]:
+		op = OperationAdmin
+	case fc.Object == "Mutation":
+		// Any write operation, the resolver
+		// checks the one it performs
+		op = OperationUpdate
+	}
+	executor, _ := fc.Args["executor"].(string)
+
+	var reason string
+	switch {
+	case !principal.HasScope(op.scope()):
+		reason = fmt.Sprintf("%s requires %s scope", fc.Field.Name, op.scope())
+	case executor != "" && !principal.CanAccess(executor):
+		reason = "executor not granted"
+	default:
+		return next(ctx)
+	}
+
+	denied := &DeniedError{
+		Subject:   principal.Subject,
+		Executor:  executor,
+		Operation: op,
+		Reason:    reason,
+	}
+	if e.Authorizer != nil {
+		e.Authorizer.audit(ctx, principal, denied)
+	}
+	return nil, denied
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/jackc/pgx/v5"
+	"github.com/lucagez/qron/sqlc"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+type Config struct {
+	// JWKS verifies bearer JWTs. Only API keys
+	// are accepted when nil
+	JWKS *jose.JSONWebKeySet
+	// Issuer is the expected `iss` claim. Required
+	// for JWTs to be accepted
+	Issuer string
+	// Audience is the expected `aud` claim. Not checked when empty
+	Audience string
+	// OwnerClaim is the claim holding the owner. Defaults to `sub`
+	OwnerClaim string
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
+}
+
+// LoadJWKS reads a JSON web key set from `path`
+func LoadJWKS(path string) (*jose.JSONWebKeySet, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(raw, &jwks); err != nil {
+		return nil, err
+	}
+	return &jwks, nil
+}
+
+// Authenticator resolves bearer tokens, either API keys
+// or JWTs, into the principal making the request
+type Authenticator struct {
+	queries *sqlc.Queries
+	cfg     Config
+}
+
+func New(queries *sqlc.Queries, cfg Config) *Authenticator {
+	if cfg.OwnerClaim == "" {
+		cfg.OwnerClaim = "sub"
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &Authenticator{queries: queries, cfg: cfg}
+}
+
+// Middleware rejects requests without a valid bearer token. The
+// principal and its owner are set in the context of the request.
+// Browsers can't set headers on websocket upgrades, so those are let
+// through with an empty principal, denied everything until InitFunc
+// authenticates the token sent in the connection_init payload
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("authorization"), "Bearer ")
+		if (!ok || token == "") && isUpgrade(r) {
+			next.ServeHTTP(w, r.WithContext(NewCtx(r.Context(), Principal{})))
+			return
+		}
+		if !ok || token == "" {
+			http.Error(w, ErrUnauthenticated.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		principal, err := a.Authenticate(r.Context(), token)
+		if err != nil {
+			a.cfg.Logger.Warn("rejected request", "remote_addr", r.RemoteAddr, "error", err)
+			http.Error(w, ErrUnauthenticated.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		ctx := NewCtx(r.Context(), principal)
+		ctx = sqlc.NewCtx(ctx, principal.Owner)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// InitFunc authenticates websocket connections from the
+// `authorization` field of the connection_init payload. Connections
+// already authenticated by Middleware keep their principal
+func (a *Authenticator) InitFunc(ctx context.Context, payload transport.InitPayload) (context.Context, error) {
+	if principal, ok := FromCtx(ctx); ok && principal.Owner != "" {
+		return ctx, nil
+	}
+
+	token := strings.TrimPrefix(payload.Authorization(), "Bearer ")
+	if token == "" {
+		return ctx, ErrUnauthenticated
+	}
+	principal, err := a.Authenticate(ctx, token)
+	if err != nil {
+		a.cfg.Logger.Warn("rejected websocket", "error", err)
+		return ctx, ErrUnauthenticated
+	}
+
+	ctx = NewCtx(ctx, principal)
+	return sqlc.NewCtx(ctx, principal.Owner), nil
+}
+
+func isUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("upgrade"), "websocket")
+}
+
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (Principal, error) {
+	if isKey(token) {
+		return a.authenticateKey(ctx, token)
+	}
+	if a.cfg.JWKS != nil {
+		return a.authenticateJWT(token)
+	}
+	return Principal{}, ErrUnauthenticated
+}
+
+func (a *Authenticator) authenticateKey(ctx context.Context, token string) (Principal, error) {
+	key, err := a.queries.GetActiveApiKey(ctx, HashKey(token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Principal{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+
+	principal := Principal{
+		Subject:   strconv.FormatInt(key.ID, 10),
+		Owner:     key.Owner,
+		Executors: key.Executors,
+		Roles:     key.Roles,
+	}
+	for _, scope := range key.Scopes {
+		principal.Scopes = append(principal.Scopes, Scope(scope))
+	}
+	return principal, nil
+}
+
+type jwtClaims struct {
+	// Space separated scopes, as in OAuth
+	Scope     string   `json:"scope"`
+	Executors []string `json:"executors"`
+	Roles     []string `json:"roles"`
+}
+
+func (a *Authenticator) authenticateJWT(token string) (Principal, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	var kid string
+	if len(parsed.Headers) > 0 {
+		kid = parsed.Headers[0].KeyID
+	}
+	keys := a.cfg.JWKS.Key(kid)
+	if kid == "" && len(a.cfg.JWKS.Keys) == 1 {
+		keys = a.cfg.JWKS.Keys
+	}
+	if len(keys) == 0 {
+		return Principal{}, errors.New("unknown signing key")
+	}
+
+	// Local key sets may hold private keys,
+	// while symmetric keys have no public part
+	verificationKey := keys[0]
+	if public := verificationKey.Public(); public.Valid() {
+		verificationKey = public
+	}
+
+	var std jwt.Claims
+	var custom jwtClaims
+	var raw map[string]interface{}
+	if err := parsed.Claims(verificationKey, &std, &custom, &raw); err != nil {
+		return Principal{}, err
+	}
+
+	// Validate only checks claims that are present, tokens
+	// without expiry or issuer would be accepted forever
+	if std.Expiry == nil {
+		return Principal{}, errors.New("missing exp claim")
+	}
+	if a.cfg.Issuer == "" {
+		return Principal{}, errors.New("issuer not configured")
+	}
+	expected := jwt.Expected{Issuer: a.cfg.Issuer, Time: time.Now()}
+	if a.cfg.Audience != "" {
+		expected.Audience = jwt.Audience{a.cfg.Audience}
+	}
+	if err := std.Validate(expected); err != nil {
+		return Principal{}, err
+	}
+
+	owner, _ := raw[a.cfg.OwnerClaim].(string)
+	if owner == "" {
+		return Principal{}, errors.New("missing owner claim " + a.cfg.OwnerClaim)
+	}
+
+	principal := Principal{
+		Subject:   std.Subject,
+		Owner:     owner,
+		Executors: custom.Executors,
+		Roles:     custom.Roles,
+	}
+	for _, scope := range strings.Fields(custom.Scope) {
+		principal.Scopes = append(principal.Scopes, Scope(strings.ToUpper(scope)))
+	}
+	return principal, nil
+}
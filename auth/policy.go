@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/lucagez/qron/sqlc"
+)
+
+type Operation string
+
+const (
+	// OperationAny matches every operation in policies
+	OperationAny Operation = "*"
+	// OperationRead allows queries and subscriptions
+	OperationRead   Operation = "READ"
+	OperationCreate Operation = "CREATE"
+	// OperationUpdate allows updating, pausing, resuming,
+	// cancelling and requeueing jobs
+	OperationUpdate Operation = "UPDATE"
+	OperationDelete Operation = "DELETE"
+	// OperationProcess allows fetching and committing jobs
+	OperationProcess Operation = "PROCESS"
+	// OperationManage allows pausing and resuming executors and
+	// setting rate limits. Executors are shared by every owner,
+	// so it requires the admin scope
+	OperationManage Operation = "MANAGE"
+	// OperationAdmin allows managing keys and policies
+	OperationAdmin Operation = "ADMIN"
+)
+
+// anyExecutor matches every executor in policies
+const anyExecutor = "*"
+
+// scope is the scope required on top of the policies
+func (o Operation) scope() Scope {
+	switch o {
+	case OperationRead:
+		return ScopeRead
+	case OperationAdmin, OperationManage:
+		return ScopeAdmin
+	}
+	return ScopeWrite
+}
+
+// DeniedError is returned for operations the principal is not
+// allowed to perform. It matches ErrForbidden with errors.Is
+type DeniedError struct {
+	Subject   string
+	Executor  string
+	Operation Operation
+	Reason    string
+}
+
+func (e *DeniedError) Error() string {
+	if e.Executor == "" {
+		return fmt.Sprintf("%s: %s denied: %s", ErrForbidden, e.Operation, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s on %s denied: %s", ErrForbidden, e.Operation, e.Executor, e.Reason)
+}
+
+func (e *DeniedError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Extensions are exposed in GraphQL errors
+func (e *DeniedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":      "FORBIDDEN",
+		"executor":  e.Executor,
+		"operation": e.Operation,
+	}
+}
+
+// Authorizer decides whether the principal in context can perform
+// an operation on an executor. Principals are checked against their
+// scopes and executors, and against the policies of their roles when
+// they have any. Denials are recorded in the audit log
+type Authorizer struct {
+	queries *sqlc.Queries
+	logger  *slog.Logger
+}
+
+// NewAuthorizer creates an Authorizer. A nil logger uses slog.Default()
+func NewAuthorizer(queries *sqlc.Queries, logger *slog.Logger) *Authorizer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Authorizer{queries: queries, logger: logger}
+}
+
+// Authorize returns a *DeniedError when the principal in `ctx` is
+// not allowed `op` on `executor`. Empty executors stand for operations
+// not bound to an executor. Contexts without a principal are trusted
+func (a *Authorizer) Authorize(ctx context.Context, executor string, op Operation) error {
+	principal, ok := FromCtx(ctx)
+	if !ok {
+		return nil
+	}
+
+	reason, err := a.check(ctx, principal, executor, op)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return nil
+	}
+
+	denied := &DeniedError{
+		Subject:   principal.Subject,
+		Executor:  executor,
+		Operation: op,
+		Reason:    reason,
+	}
+	a.audit(ctx, principal, denied)
+	return denied
+}
+
+// Allowed is like Authorize, without auditing
+func (a *Authorizer) Allowed(ctx context.Context, executor string, op Operation) (bool, error) {
+	principal, ok := FromCtx(ctx)
+	if !ok {
+		return true, nil
+	}
+	reason, err := a.check(ctx, principal, executor, op)
+	return reason == "", err
+}
+
+// check returns why the principal is denied,
+// or an empty string when it is allowed
+func (a *Authorizer) check(ctx context.Context, principal Principal, executor string, op Operation) (string, error) {
+	if !principal.HasScope(op.scope()) {
+		return fmt.Sprintf("missing %s scope", op.scope()), nil
+	}
+	if executor != "" && !principal.CanAccess(executor) {
+		return "executor not granted", nil
+	}
+	if len(principal.Roles) == 0 {
+		return "", nil
+	}
+
+	if executor == "" {
+		executor = anyExecutor
+	}
+	allowed, err := a.queries.IsAllowed(sqlc.NewCtx(ctx, principal.Owner), sqlc.IsAllowedParams{
+		Owner:     principal.Owner,
+		Roles:     principal.Roles,
+		Executor:  executor,
+		Operation: string(op),
+	})
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return "no policy grants it", nil
+	}
+	return "", nil
+}
+
+// audit records a denial. Failing to do so is
+// logged, the operation is denied anyway
+func (a *Authorizer) audit(ctx context.Context, principal Principal, denied *DeniedError) {
+	roles := principal.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	err := a.queries.CreateAuditEntry(sqlc.NewCtx(ctx, principal.Owner), sqlc.CreateAuditEntryParams{
+		Owner:     principal.Owner,
+		Subject:   principal.Subject,
+		Roles:     roles,
+		Executor:  denied.Executor,
+		Operation: string(denied.Operation),
+		Reason:    denied.Reason,
+	})
+	if err != nil {
+		a.logger.Error("failed to audit denied operation", "error", err)
+	}
+	a.logger.Warn("denied operation",
+		"subject", principal.Subject,
+		"owner", principal.Owner,
+		"executor", denied.Executor,
+		"operation", denied.Operation,
+		"reason", denied.Reason,
+	)
+}
@@ -2,36 +2,68 @@ package qron
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
-	"log"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"os"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/lucagez/qron/auth"
 	tinyctx "github.com/lucagez/qron/ctx"
 	"github.com/lucagez/qron/graph"
 	"github.com/lucagez/qron/graph/generated"
 	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/metrics"
 	"github.com/lucagez/qron/migrations"
 	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/tracing"
 	"github.com/pressly/goose/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Client struct {
-	Resolver      graph.Resolver
-	MaxInFlight   uint64
-	MaxFlushSize  int
-	FlushInterval time.Duration
-	PollInterval  time.Duration
-	ResetInterval time.Duration
-	OwnerSetter   func(http.Handler) http.Handler
-	processedCh   chan Job
+	Resolver          graph.Resolver
+	MaxInFlight       uint64
+	MaxFlushSize      int
+	FlushInterval     time.Duration
+	PollInterval      time.Duration
+	ResetInterval     time.Duration
+	HeartbeatInterval time.Duration
+	WorkerID          string
+	Adaptive          *AdaptiveConfig
+	Backpressure      bool
+	FetchMode         model.FetchMode
+	LeaderInterval    time.Duration
+	LeaderOnly        bool
+	OnAnomaly         func(executor string, anomaly sqlc.ListAnomaliesRow)
+	OnEvent           func(Event)
+	MaxResets         int
+	OverdueAfter      time.Duration
+	StaleCronAfter    time.Duration
+	QuarantineAfter   int
+	RunRetention      time.Duration
+	Metrics           metrics.Recorder
+	Logger            *slog.Logger
+	OwnerSetter       func(http.Handler) http.Handler
+	WebsocketInit     transport.WebsocketInitFunc
+	processedCh       chan Job
 }
 
 type Config struct {
@@ -40,16 +72,93 @@ type Config struct {
 	FlushInterval time.Duration
 	PollInterval  time.Duration
 	ResetInterval time.Duration
-	OwnerSetter   func(http.Handler) http.Handler
+	// HeartbeatInterval is how often the client signals it is alive.
+	// Jobs held by a client missing a few heartbeats are released
+	HeartbeatInterval time.Duration
+	// WorkerID identifies the client as holder of the fetched jobs.
+	// Defaults to hostname, pid and a random suffix
+	WorkerID string
+	// Adaptive lets `MaxInFlight` follow handler throughput.
+	// The limit is fixed when nil
+	Adaptive *AdaptiveConfig
+	// Backpressure makes Fetch request only `MaxInFlight` minus the
+	// jobs delivered and not acknowledged yet, so handlers are never
+	// handed more than `MaxInFlight` jobs. Without it every poll
+	// requests up to `MaxInFlight` jobs
+	Backpressure bool
+	// FetchMode decides how due jobs of different owners are
+	// ordered. Defaults to FIFO
+	FetchMode model.FetchMode
+	// LeaderInterval is how often leadership is renewed
+	// or contended. Defaults to 5s
+	LeaderInterval time.Duration
+	// LeaderOnly makes Fetch poll jobs only while holding leadership
+	// of the executor, so a single replica is processing them
+	LeaderOnly bool
+	// OnAnomaly is called by the reset loop for every job newly
+	// detected as anomalous. Detection is disabled when nil
+	OnAnomaly func(executor string, anomaly sqlc.ListAnomaliesRow)
+	// OnEvent is called on every job transition observed by the
+	// client: fetches, flushes, resets and mutation methods.
+	// It is called synchronously and should not block
+	OnEvent func(Event)
+	// MaxResets is the amount of consecutive resets after which
+	// a job is reported. Defaults to 3
+	MaxResets int
+	// OverdueAfter is how late a one shot job can be before
+	// being reported. Defaults to 5m
+	OverdueAfter time.Duration
+	// StaleCronAfter is how late a cron job can be before
+	// being reported. Defaults to 1h
+	StaleCronAfter time.Duration
+	// QuarantineAfter is the amount of consecutive resets after
+	// which a job is moved to FAILURE instead of READY.
+	// Defaults to 5. Negative values disable quarantine
+	QuarantineAfter int
+	// RunRetention is how long finished runs are kept
+	// for statistics. Defaults to 24h
+	RunRetention time.Duration
+	// Metrics records fetch, flush and reset measurements.
+	// Defaults to a prometheus recorder served on `/metrics`
+	Metrics metrics.Recorder
+	// Logger receives the client records. Records about a
+	// job carry its id, executor and owner. Defaults to
+	// slog.Default()
+	Logger *slog.Logger
+	// OwnerSetter wraps `/graphql` only. The playground
+	// and `/metrics` are served without it
+	OwnerSetter func(http.Handler) http.Handler
+	// WebsocketInit is called with the connection_init payload
+	// of subscriptions, before any operation is run
+	WebsocketInit transport.WebsocketInitFunc
+	// Auth makes `/graphql` require an API key or a JWT, taking the
+	// owner from the credentials. Subscriptions may send the token as
+	// `authorization` in the connection_init payload instead of a
+	// header. OwnerSetter and WebsocketInit are ignored when set
+	Auth *auth.Config
 }
 
+// heartbeatMisses is the amount of heartbeats a worker
+// can miss before its jobs are released
+const heartbeatMisses = 3
+
 type JobEntity struct {
 	sqlc.TinyJob
 }
 
 func NewClient(db *pgxpool.Pool, cfg Config) (Client, error) {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
 	queries := sqlc.New(db)
-	resolver := graph.Resolver{Queries: queries, DB: db}
+	resolver := graph.Resolver{
+		Queries:    queries,
+		DB:         db,
+		Logger:     cfg.Logger,
+		Notifier:   graph.NewNotifier(db, cfg.Logger),
+		Authorizer: auth.NewAuthorizer(queries, cfg.Logger),
+	}
 
 	if cfg.MaxInFlight == 0 {
 		cfg.MaxInFlight = 100
@@ -60,6 +169,15 @@ func NewClient(db *pgxpool.Pool, cfg Config) (Client, error) {
 	if cfg.PollInterval == 0 {
 		cfg.PollInterval = 1 * time.Second
 	}
+	if cfg.Auth != nil {
+		authCfg := *cfg.Auth
+		if authCfg.Logger == nil {
+			authCfg.Logger = cfg.Logger
+		}
+		authenticator := auth.New(queries, authCfg)
+		cfg.OwnerSetter = authenticator.Middleware
+		cfg.WebsocketInit = authenticator.InitFunc
+	}
 	if cfg.OwnerSetter == nil {
 		cfg.OwnerSetter = tinyctx.ExecutorSetterMiddleware
 	}
@@ -69,19 +187,83 @@ func NewClient(db *pgxpool.Pool, cfg Config) (Client, error) {
 	if cfg.MaxFlushSize == 0 {
 		cfg.MaxFlushSize = 100
 	}
+	if cfg.HeartbeatInterval == 0 {
+		cfg.HeartbeatInterval = 5 * time.Second
+	}
+	if cfg.WorkerID == "" {
+		cfg.WorkerID = newWorkerID()
+	}
+	if cfg.FetchMode == "" {
+		cfg.FetchMode = model.FetchModeFifo
+	}
+	if cfg.LeaderInterval == 0 {
+		cfg.LeaderInterval = 5 * time.Second
+	}
+	if cfg.MaxResets == 0 {
+		cfg.MaxResets = 3
+	}
+	if cfg.OverdueAfter == 0 {
+		cfg.OverdueAfter = 5 * time.Minute
+	}
+	if cfg.StaleCronAfter == 0 {
+		cfg.StaleCronAfter = 1 * time.Hour
+	}
+	if cfg.QuarantineAfter == 0 {
+		cfg.QuarantineAfter = 5
+	}
+	if cfg.QuarantineAfter < 0 {
+		cfg.QuarantineAfter = 0
+	}
+	if cfg.RunRetention == 0 {
+		cfg.RunRetention = 24 * time.Hour
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = metrics.NewPrometheus(nil)
+	}
+	if cfg.Adaptive != nil {
+		adaptive := cfg.Adaptive.withDefaults(cfg.MaxInFlight)
+		cfg.Adaptive = &adaptive
+	}
 
 	return Client{
-		Resolver:      resolver,
-		MaxInFlight:   cfg.MaxInFlight,
-		FlushInterval: cfg.FlushInterval,
-		PollInterval:  cfg.PollInterval,
-		OwnerSetter:   cfg.OwnerSetter,
-		ResetInterval: cfg.ResetInterval,
-		MaxFlushSize:  cfg.MaxFlushSize,
-		processedCh:   make(chan Job),
+		Resolver:          resolver,
+		MaxInFlight:       cfg.MaxInFlight,
+		FlushInterval:     cfg.FlushInterval,
+		PollInterval:      cfg.PollInterval,
+		OwnerSetter:       cfg.OwnerSetter,
+		WebsocketInit:     cfg.WebsocketInit,
+		ResetInterval:     cfg.ResetInterval,
+		HeartbeatInterval: cfg.HeartbeatInterval,
+		WorkerID:          cfg.WorkerID,
+		Adaptive:          cfg.Adaptive,
+		Backpressure:      cfg.Backpressure,
+		FetchMode:         cfg.FetchMode,
+		LeaderInterval:    cfg.LeaderInterval,
+		LeaderOnly:        cfg.LeaderOnly,
+		OnAnomaly:         cfg.OnAnomaly,
+		OnEvent:           cfg.OnEvent,
+		MaxResets:         cfg.MaxResets,
+		OverdueAfter:      cfg.OverdueAfter,
+		StaleCronAfter:    cfg.StaleCronAfter,
+		QuarantineAfter:   cfg.QuarantineAfter,
+		RunRetention:      cfg.RunRetention,
+		Metrics:           cfg.Metrics,
+		Logger:            cfg.Logger,
+		MaxFlushSize:      cfg.MaxFlushSize,
+		processedCh:       make(chan Job),
 	}, nil
 }
 
+func newWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
+
 func (t *Client) IncreaseInFlight() {
 	atomic.AddUint64(&t.MaxInFlight, 1)
 }
@@ -92,27 +274,174 @@ func (t *Client) DecreaseInFlight() {
 
 func (t *Client) reset(ctx context.Context, executorName string) {
 	ticker := time.NewTicker(t.ResetInterval)
+	reported := map[anomalyKey]bool{}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			ids, err := t.Resolver.Queries.ResetTimeoutJobs(context.Background(), executorName)
+			ids, err := t.Resolver.Queries.ResetTimeoutJobs(context.Background(), sqlc.ResetTimeoutJobsParams{
+				Executor:  executorName,
+				MaxResets: int32(t.QuarantineAfter),
+			})
 			if len(ids) > 0 {
-				log.Println("[RESETTING]", ids)
+				t.Logger.Info("resetting timed out jobs", "executor", executorName, "ids", ids)
+			}
+			if err != nil {
+				t.Logger.Error("error while resetting timed out jobs", "executor", executorName, "error", err)
 			}
+			t.Metrics.JobsReset(executorName, len(ids))
+			for _, id := range ids {
+				t.emitJob(EventReset, executorName, sqlc.TinyJob{ID: id, Executor: executorName}, nil)
+			}
+			t.recordQueueDepth(executorName)
+			_, err = t.Resolver.Queries.PruneJobRuns(context.Background(), sqlc.PruneJobRunsParams{
+				Executor:  executorName,
+				Retention: int32(t.RunRetention.Seconds()),
+			})
 			if err != nil {
-				log.Println("error while resetting timed out jobs:", err)
+				t.Logger.Error("error while pruning job runs", "executor", executorName, "error", err)
+			}
+			if t.OnAnomaly != nil {
+				reported = t.detectAnomalies(executorName, reported)
 			}
 		}
 	}
 }
 
+var statuses = []sqlc.TinyStatus{
+	sqlc.TinyStatusREADY,
+	sqlc.TinyStatusPENDING,
+	sqlc.TinyStatusFAILURE,
+	sqlc.TinyStatusSUCCESS,
+	sqlc.TinyStatusPAUSED,
+	sqlc.TinyStatusCANCELLED,
+}
+
+func (t *Client) recordQueueDepth(executorName string) {
+	counts, err := t.Resolver.Queries.CountJobsByStatus(context.Background(), sqlc.CountJobsByStatusParams{
+		Executor: executorName,
+	})
+	if err != nil {
+		t.Logger.Error("error while counting jobs", "executor", executorName, "error", err)
+		return
+	}
+
+	depth := map[sqlc.TinyStatus]int64{}
+	for _, count := range counts {
+		depth[count.Status] = count.Count
+	}
+	// Statuses without jobs are reported too, so
+	// gauges drop to zero once a queue is drained
+	for _, status := range statuses {
+		t.Metrics.QueueDepth(executorName, string(status), depth[status])
+	}
+}
+
+type anomalyKey struct {
+	id   int64
+	kind string
+}
+
+// detectAnomalies reports anomalies that were not
+// reported on the previous run and returns the
+// ones currently detected
+func (t *Client) detectAnomalies(executorName string, reported map[anomalyKey]bool) map[anomalyKey]bool {
+	anomalies, err := t.Resolver.Queries.ListAnomalies(context.Background(), sqlc.ListAnomaliesParams{
+		Executor:       executorName,
+		MaxResets:      int32(t.MaxResets),
+		OverdueAfter:   int32(t.OverdueAfter.Seconds()),
+		StaleCronAfter: int32(t.StaleCronAfter.Seconds()),
+		Limit:          100,
+	})
+	if err != nil {
+		t.Logger.Error("error while detecting anomalies", "executor", executorName, "error", err)
+		return reported
+	}
+
+	detected := map[anomalyKey]bool{}
+	for _, anomaly := range anomalies {
+		key := anomalyKey{id: anomaly.ID, kind: anomaly.Kind}
+		detected[key] = true
+		if !reported[key] {
+			t.OnAnomaly(executorName, anomaly)
+		}
+	}
+	return detected
+}
+
+func (t *Client) heartbeat(ctx context.Context, executorName string, inflight *inFlight) {
+	ticker := time.NewTicker(t.HeartbeatInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.beat(executorName)
+			t.cancelRequested(executorName, inflight)
+		}
+	}
+}
+
+// cancelRequested cancels the context of jobs held by this
+// worker for which a cancel was requested
+func (t *Client) cancelRequested(executorName string, inflight *inFlight) {
+	ids, err := t.Resolver.Queries.ListCancelRequestedJobs(context.Background(), sqlc.ListCancelRequestedJobsParams{
+		Worker:   t.WorkerID,
+		Executor: executorName,
+	})
+	if err != nil {
+		t.Logger.Error("error while listing cancelled jobs", "executor", executorName, "error", err)
+	}
+	if cancelled := inflight.cancel(ids); len(cancelled) > 0 {
+		t.Logger.Info("cancelling jobs", "executor", executorName, "ids", cancelled)
+	}
+}
+
+// beat renews the worker registration and releases jobs
+// held by workers that stopped heartbeating
+func (t *Client) beat(executorName string) {
+	ttl := int32(math.Ceil((heartbeatMisses * t.HeartbeatInterval).Seconds()))
+	_, err := t.Resolver.Queries.HeartbeatWorker(context.Background(), sqlc.HeartbeatWorkerParams{
+		ID:       t.WorkerID,
+		Executor: executorName,
+		Ttl:      ttl,
+	})
+	if err != nil {
+		t.Logger.Error("error while sending heartbeat", "executor", executorName, "worker", t.WorkerID, "error", err)
+	}
+
+	ids, err := t.Resolver.Queries.ReleaseOrphanedJobs(context.Background(), sqlc.ReleaseOrphanedJobsParams{
+		Executor:  executorName,
+		MaxResets: int32(t.QuarantineAfter),
+	})
+	if len(ids) > 0 {
+		t.Logger.Info("releasing orphaned jobs", "executor", executorName, "ids", ids)
+	}
+	if err != nil {
+		t.Logger.Error("error while releasing orphaned jobs", "executor", executorName, "error", err)
+	}
+	t.Metrics.JobsReset(executorName, len(ids))
+	for _, id := range ids {
+		t.emitJob(EventReset, executorName, sqlc.TinyJob{ID: id, Executor: executorName}, nil)
+	}
+
+	_, err = t.Resolver.Queries.DeleteExpiredWorkers(context.Background(), executorName)
+	if err != nil {
+		t.Logger.Error("error while deleting expired workers", "executor", executorName, "error", err)
+	}
+}
+
 func (t *Client) flush(ctx context.Context, executorName string) {
+	// Processed jobs are still flushed once ctx is done
+	flushCtx := context.WithoutCancel(ctx)
+
 	var commitBatch []model.CommitArgs
 	var failBatch []model.CommitArgs
 	var retryBatch []model.CommitArgs
+	var commitJobs, failJobs, retryJobs []Job
 
 	for {
 		shouldFlush := false
@@ -125,7 +454,8 @@ func (t *Client) flush(ctx context.Context, executorName string) {
 			shouldFlush = true
 		case job := <-t.processedCh:
 			commit := model.CommitArgs{
-				ID: job.ID,
+				ID:    job.ID,
+				Lease: int(job.Lease),
 			}
 			if job.State != "" {
 				commit.State = &job.State
@@ -137,10 +467,13 @@ func (t *Client) flush(ctx context.Context, executorName string) {
 			switch job.Status {
 			case sqlc.TinyStatusSUCCESS:
 				commitBatch = append(commitBatch, commit)
+				commitJobs = append(commitJobs, job)
 			case sqlc.TinyStatusFAILURE:
 				failBatch = append(failBatch, commit)
+				failJobs = append(failJobs, job)
 			case sqlc.TinyStatusREADY:
 				retryBatch = append(retryBatch, commit)
+				retryJobs = append(retryJobs, job)
 			}
 			if len(commitBatch)+len(failBatch)+len(retryBatch) >= t.MaxFlushSize {
 				shouldFlush = true
@@ -151,31 +484,58 @@ func (t *Client) flush(ctx context.Context, executorName string) {
 			continue
 		}
 
-		if len(commitBatch)+len(failBatch)+len(retryBatch) > 0 {
-			log.Println("[FLUSHING]", len(commitBatch), "commit.", len(failBatch), "fail.", len(retryBatch), "retry.")
+		if size := len(commitBatch) + len(failBatch) + len(retryBatch); size > 0 {
+			t.Logger.Info("flushing jobs",
+				"executor", executorName,
+				"commit", len(commitBatch),
+				"fail", len(failBatch),
+				"retry", len(retryBatch),
+			)
+			t.Metrics.FlushSize(executorName, size)
 		}
 
 		// TODO: Handle failed commits + flush errors
 		if len(commitBatch) > 0 {
-			_, err := t.Resolver.Mutation().CommitJobs(ctx, executorName, commitBatch)
+			rejected, err := t.Resolver.Mutation().CommitJobs(flushCtx, executorName, commitBatch)
+			if len(rejected) > 0 {
+				t.Logger.Warn("rejected jobs", "executor", executorName, "ids", rejected)
+			}
 			if err != nil {
-				log.Println(err)
+				t.Logger.Error("error while committing jobs", "executor", executorName, "error", err)
+			} else {
+				t.Metrics.JobsCommitted(executorName, len(commitBatch)-len(rejected))
 			}
+			t.emitBatch(EventCommitted, executorName, commitJobs, rejected, err)
 			commitBatch = []model.CommitArgs{}
+			commitJobs = []Job{}
 		}
 		if len(failBatch) > 0 {
-			_, err := t.Resolver.Mutation().FailJobs(ctx, executorName, failBatch)
+			rejected, err := t.Resolver.Mutation().FailJobs(flushCtx, executorName, failBatch)
+			if len(rejected) > 0 {
+				t.Logger.Warn("rejected jobs", "executor", executorName, "ids", rejected)
+			}
 			if err != nil {
-				log.Println(err)
+				t.Logger.Error("error while failing jobs", "executor", executorName, "error", err)
+			} else {
+				t.Metrics.JobsFailed(executorName, len(failBatch)-len(rejected))
 			}
+			t.emitBatch(EventFailed, executorName, failJobs, rejected, err)
 			failBatch = []model.CommitArgs{}
+			failJobs = []Job{}
 		}
 		if len(retryBatch) > 0 {
-			_, err := t.Resolver.Mutation().RetryJobs(ctx, executorName, retryBatch)
+			rejected, err := t.Resolver.Mutation().RetryJobs(flushCtx, executorName, retryBatch)
+			if len(rejected) > 0 {
+				t.Logger.Warn("rejected jobs", "executor", executorName, "ids", rejected)
+			}
 			if err != nil {
-				log.Println(err)
+				t.Logger.Error("error while retrying jobs", "executor", executorName, "error", err)
+			} else {
+				t.Metrics.JobsRetried(executorName, len(retryBatch)-len(rejected))
 			}
+			t.emitBatch(EventRetried, executorName, retryJobs, rejected, err)
 			retryBatch = []model.CommitArgs{}
+			retryJobs = []Job{}
 		}
 	}
 }
@@ -188,8 +548,24 @@ func (c *Client) Fetch(ctx context.Context, executorName string) chan Job {
 	// ctx, cancel := context.WithCancel(ctx)
 	ch := make(chan Job)
 
+	// Registering before fetching prevents other workers
+	// from releasing jobs held by this client
+	c.beat(executorName)
+
+	var controller *aimd
+	if c.Adaptive != nil {
+		controller = newAimd(*c.Adaptive, &c.MaxInFlight)
+	}
+	inflight := newInFlight(controller)
+
+	var leader *Leader
+	if c.LeaderOnly {
+		leader = c.Leader(ctx, "executor:"+executorName)
+	}
+
 	go c.flush(ctx, executorName)
 	go c.reset(ctx, executorName)
+	go c.heartbeat(ctx, executorName, inflight)
 
 	go func() {
 		for {
@@ -199,18 +575,59 @@ func (c *Client) Fetch(ctx context.Context, executorName string) chan Job {
 				return
 			// TODO: replace with ticker!
 			case <-time.After(c.PollInterval):
+				if leader != nil && !leader.IsLeader() {
+					continue
+				}
+
+				limit := atomic.LoadUint64(&c.MaxInFlight)
+				outstanding := inflight.outstanding()
+				if c.Backpressure {
+					// Only request as many jobs as the handlers can take
+					if outstanding >= limit {
+						continue
+					}
+					limit -= outstanding
+				}
+
+				start := time.Now()
 				jobs, err := c.Resolver.
 					Mutation().
-					FetchForProcessing(ctx, executorName, int(c.MaxInFlight))
+					FetchForProcessing(ctx, executorName, int(limit), &c.WorkerID, c.FetchMode)
+				c.Metrics.FetchLatency(executorName, time.Since(start))
 				if len(jobs) > 0 {
-					log.Println("[FETCHING]", len(jobs), "jobs")
+					c.Logger.Info("fetching jobs", "executor", executorName, "amount", len(jobs))
 				}
 				if err != nil {
 					// TODO: how to handle err?
-					log.Println(err)
+					c.Logger.Error("error while fetching jobs", "executor", executorName, "error", err)
+					c.emit(Event{Kind: EventFetched, Executor: executorName, Err: err})
 				}
+				c.Metrics.JobsFetched(executorName, len(jobs))
 				for _, job := range jobs {
-					ch <- Job{TinyJob: job, ch: c.processedCh}
+					// Both timestamps come from the database
+					// so lag is not affected by clock drift
+					c.Metrics.Lag(executorName, job.LastRunAt.Time.Sub(job.RunAt.Time))
+					c.emitJob(EventFetched, executorName, job, nil)
+
+					// The span of the request creating the
+					// job is restored as parent
+					parent := tracing.Extract(context.Background(), job.Meta)
+					jobCtx := inflight.add(parent, job)
+					jobCtx, span := tracing.Tracer().Start(jobCtx, "qron.process", trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+						attribute.String("qron.executor", executorName),
+						attribute.Int64("qron.job.id", job.ID),
+						attribute.String("qron.job.name", job.Name),
+						attribute.String("qron.job.owner", job.Owner),
+					))
+					ch <- Job{
+						TinyJob:  job,
+						ch:       c.processedCh,
+						inflight: inflight,
+						ctx:      jobCtx,
+						span:     span,
+						logger:   c.Logger.With("job_id", job.ID, "executor", executorName, "owner", job.Owner),
+					}
+					c.emitJob(EventStarted, executorName, job, nil)
 				}
 			}
 		}
@@ -219,14 +636,49 @@ func (c *Client) Fetch(ctx context.Context, executorName string) chan Job {
 	return ch
 }
 
+// Leader campaigns for leadership of `name` until ctx is done.
+// Among the clients campaigning for the same name, at most one
+// is leading at any time. Leadership is retried every
+// `LeaderInterval` when another client holds it.
+func (c *Client) Leader(ctx context.Context, name string) *Leader {
+	leader := newLeader(c.Resolver.DB, name, c.LeaderInterval, c.Logger)
+	leader.campaign(ctx)
+	go leader.run(ctx)
+	return leader
+}
+
+// Handler serves the graphql api on `/graphql`, the playground on `/`
+// and, for prometheus recorders, metrics on `/metrics`. Only `/graphql`
+// goes through OwnerSetter, so the playground and metrics stay reachable
+// when auth is enabled. Scrapers should be restricted at the network level
 func (c *Client) Handler() http.Handler {
 	router := chi.NewRouter()
-	api := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{
+	api := handler.New(generated.NewExecutableSchema(generated.Config{
 		Resolvers: &c.Resolver,
 	}))
 
-	router.Use(c.OwnerSetter)
-	router.Handle("/graphql", api)
+	// Same as handler.NewDefaultServer, with
+	// subscriptions authenticated on init
+	api.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              c.WebsocketInit,
+	})
+	api.AddTransport(transport.Options{})
+	api.AddTransport(transport.GET{})
+	api.AddTransport(transport.POST{})
+	api.AddTransport(transport.MultipartForm{})
+	api.SetQueryCache(lru.New(1000))
+	api.Use(extension.Introspection{})
+	api.Use(extension.AutomaticPersistedQuery{Cache: lru.New(100)})
+
+	api.Use(tracing.Extension{})
+	api.Use(auth.Extension{Authorizer: c.Resolver.Authorizer})
+
+	router.Use(tracing.Middleware)
+	router.With(c.OwnerSetter).Handle("/graphql", api)
+	if exporter, ok := c.Metrics.(http.Handler); ok {
+		router.Handle("/metrics", exporter)
+	}
 	router.Handle("/", playground.Handler("GraphQL Playground", "/graphql"))
 
 	return router
@@ -249,14 +701,105 @@ func (c *Client) CreateJob(ctx context.Context, executorName string, args model.
 	)
 }
 
-func (c *Client) BatchCreateJobs(ctx context.Context, executorName string, args []model.CreateJobArgs) ([]int64, error) {
+// CreateOrUpdateJob creates a named job or resolves the conflict with an
+// existing job of the same name according to `mode`. Execution history
+// of an existing job is always preserved.
+func (c *Client) CreateOrUpdateJob(ctx context.Context, executorName string, args model.CreateJobArgs, mode model.UpsertMode) (sqlc.TinyJob, error) {
+	return c.Resolver.Mutation().CreateOrUpdateJob(
+		ctx,
+		executorName,
+		args,
+		mode,
+	)
+}
+
+// BatchCreateJobs creates multiple jobs in one go and reports the outcome
+// of each job. `mode` decides whether a single failure aborts the whole batch.
+func (c *Client) BatchCreateJobs(ctx context.Context, executorName string, args []model.CreateJobArgs, mode model.BatchMode) ([]model.BatchCreateJobResult, error) {
 	return c.Resolver.Mutation().BatchCreateJobs(
 		ctx,
 		executorName,
 		args,
+		mode,
 	)
 }
 
+// SetOwnerWeight configures the share of due jobs handed to `owner`
+// by fair fetches, relative to owners with the default weight of 1.
+func (c *Client) SetOwnerWeight(ctx context.Context, owner string, weight int) error {
+	if err := c.Resolver.Authorizer.Authorize(ctx, "", auth.OperationAdmin); err != nil {
+		return err
+	}
+	return c.Resolver.Queries.SetOwnerWeight(ctx, sqlc.SetOwnerWeightParams{
+		Owner:  owner,
+		Weight: int32(weight),
+	})
+}
+
+// SetRateLimit limits the jobs fetched from `executorName` to `rate` per
+// second, allowing bursts of `burst` jobs. The limit is shared by every
+// client and applies to a single owner when `owner` is not empty.
+func (c *Client) SetRateLimit(ctx context.Context, executorName, owner string, rate float64, burst int) error {
+	if err := c.Resolver.Authorizer.Authorize(ctx, executorName, auth.OperationManage); err != nil {
+		return err
+	}
+	return c.Resolver.Queries.SetRateLimit(ctx, sqlc.SetRateLimitParams{
+		Executor: executorName,
+		Owner:    owner,
+		Rate:     rate,
+		Burst:    int32(burst),
+	})
+}
+
+func (c *Client) DeleteRateLimit(ctx context.Context, executorName, owner string) error {
+	if err := c.Resolver.Authorizer.Authorize(ctx, executorName, auth.OperationManage); err != nil {
+		return err
+	}
+	return c.Resolver.Queries.DeleteRateLimit(ctx, sqlc.DeleteRateLimitParams{
+		Executor: executorName,
+		Owner:    owner,
+	})
+}
+
+// PauseExecutor stops every client from fetching jobs of `executorName`
+// until ResumeExecutor is called. Jobs are left READY. The executor is
+// paused for every owner, so callers need the admin scope.
+func (c *Client) PauseExecutor(ctx context.Context, executorName string) (sqlc.TinyExecutor, error) {
+	executor, err := c.Resolver.Mutation().PauseExecutor(ctx, executorName)
+	c.emit(Event{Kind: EventExecutorPaused, Executor: executorName, Err: err})
+	return executor, err
+}
+
+func (c *Client) ResumeExecutor(ctx context.Context, executorName string) (sqlc.TinyExecutor, error) {
+	executor, err := c.Resolver.Mutation().ResumeExecutor(ctx, executorName)
+	c.emit(Event{Kind: EventExecutorResumed, Executor: executorName, Err: err})
+	return executor, err
+}
+
+// PauseJobs pauses every READY job matching `filter`
+// and returns the amount of paused jobs.
+func (c *Client) PauseJobs(ctx context.Context, executorName string, filter model.JobsFilter) (int, error) {
+	count, err := c.Resolver.Mutation().PauseJobs(ctx, executorName, filter)
+	c.emit(Event{Kind: EventPaused, Executor: executorName, Count: count, Err: err})
+	return count, err
+}
+
+// ResumeJobs resumes every PAUSED job matching `filter`
+// and returns the amount of resumed jobs.
+func (c *Client) ResumeJobs(ctx context.Context, executorName string, filter model.JobsFilter) (int, error) {
+	count, err := c.Resolver.Mutation().ResumeJobs(ctx, executorName, filter)
+	c.emit(Event{Kind: EventResumed, Executor: executorName, Count: count, Err: err})
+	return count, err
+}
+
+// DeleteJobs deletes every job matching `filter`
+// and returns the amount of deleted jobs.
+func (c *Client) DeleteJobs(ctx context.Context, executorName string, filter model.JobsFilter) (int, error) {
+	count, err := c.Resolver.Mutation().DeleteJobs(ctx, executorName, filter)
+	c.emit(Event{Kind: EventDeleted, Executor: executorName, Count: count, Err: err})
+	return count, err
+}
+
 func (c *Client) UpdateJobByName(ctx context.Context, executorName, name string, args model.UpdateJobArgs) (sqlc.TinyJob, error) {
 	return c.Resolver.Mutation().UpdateJobByName(
 		ctx,
@@ -276,19 +819,23 @@ func (c *Client) UpdateJobByID(ctx context.Context, executorName string, id int6
 }
 
 func (c *Client) DeleteJobByName(ctx context.Context, executorName, name string) (sqlc.TinyJob, error) {
-	return c.Resolver.Mutation().DeleteJobByName(
+	job, err := c.Resolver.Mutation().DeleteJobByName(
 		ctx,
 		executorName,
 		name,
 	)
+	c.emitJob(EventDeleted, executorName, job, err)
+	return job, err
 }
 
 func (c *Client) DeleteJobByID(ctx context.Context, executorName string, id int64) (sqlc.TinyJob, error) {
-	return c.Resolver.Mutation().DeleteJobByID(
+	job, err := c.Resolver.Mutation().DeleteJobByID(
 		ctx,
 		executorName,
 		id,
 	)
+	c.emitJob(EventDeleted, executorName, job, err)
+	return job, err
 }
 
 func (c *Client) SearchJobs(ctx context.Context, executorName string, args model.QueryJobsArgs) ([]sqlc.TinyJob, error) {
@@ -299,6 +846,20 @@ func (c *Client) SearchJobs(ctx context.Context, executorName string, args model
 	)
 }
 
+// Jobs returns a page of `first` jobs matching `filter`. The next
+// page starts after the `EndCursor` of the previous one.
+func (c *Client) Jobs(ctx context.Context, executorName string, filter *model.JobsQuery, first int, after *string, sort model.JobSort, direction model.SortDirection) (model.JobConnection, error) {
+	return c.Resolver.Query().Jobs(
+		ctx,
+		executorName,
+		filter,
+		first,
+		after,
+		sort,
+		direction,
+	)
+}
+
 func (c *Client) QueryJobByName(ctx context.Context, executorName, name string) (sqlc.TinyJob, error) {
 	return c.Resolver.Query().QueryJobByName(
 		ctx,
@@ -316,19 +877,117 @@ func (c *Client) QueryJobByID(ctx context.Context, executorName string, id int64
 }
 
 func (c *Client) StopJob(ctx context.Context, executorName string, id int64) (sqlc.TinyJob, error) {
-	return c.Resolver.Mutation().StopJob(
+	job, err := c.Resolver.Mutation().StopJob(
 		ctx,
 		executorName,
 		id,
 	)
+	c.emitJob(EventPaused, executorName, job, err)
+	return job, err
 }
 
+// CancelJob cancels a job. Running jobs are cancelled cooperatively:
+// the context of the job is cancelled on the client holding it and
+// the job ends up CANCELLED as soon as it is acknowledged.
+// Cancelled jobs are kept until deleted, RestartJob moves them
+// back to READY.
+func (c *Client) CancelJob(ctx context.Context, executorName string, id int64) (sqlc.TinyJob, error) {
+	job, err := c.Resolver.Mutation().CancelJob(
+		ctx,
+		executorName,
+		id,
+	)
+	c.emitJob(EventCancelled, executorName, job, err)
+	return job, err
+}
+
+// Stats returns the amount of jobs by status, how backed up the
+// queue is and durations and throughput of the recent runs.
+func (c *Client) Stats(ctx context.Context, executorName string) (sqlc.ListExecutorStatsRow, error) {
+	return c.Resolver.Query().ExecutorStats(ctx, executorName)
+}
+
+// IssueApiKey creates an API key for the owner in `ctx`.
+// The returned key is not stored and can't be retrieved again
+func (c *Client) IssueApiKey(ctx context.Context, name string, scopes []auth.Scope, executors, roles []string, expiresAt *time.Time) (model.IssuedAPIKey, error) {
+	return c.Resolver.Mutation().IssueAPIKey(
+		ctx,
+		name,
+		scopes,
+		executors,
+		roles,
+		expiresAt,
+	)
+}
+
+func (c *Client) RevokeApiKey(ctx context.Context, id int64) (sqlc.TinyApiKey, error) {
+	return c.Resolver.Mutation().RevokeAPIKey(ctx, id)
+}
+
+func (c *Client) ApiKeys(ctx context.Context) ([]sqlc.TinyApiKey, error) {
+	return c.Resolver.Query().APIKeys(ctx)
+}
+
+// GrantPolicy allows `role` to perform `operation` on `executorName`.
+// "*" matches any executor and a nil operation matches any operation
+func (c *Client) GrantPolicy(ctx context.Context, role, executorName string, operation *auth.Operation) (sqlc.TinyPolicy, error) {
+	return c.Resolver.Mutation().GrantPolicy(
+		ctx,
+		role,
+		executorName,
+		operation,
+	)
+}
+
+func (c *Client) RevokePolicy(ctx context.Context, id int64) (sqlc.TinyPolicy, error) {
+	return c.Resolver.Mutation().RevokePolicy(ctx, id)
+}
+
+func (c *Client) Policies(ctx context.Context) ([]sqlc.TinyPolicy, error) {
+	return c.Resolver.Query().Policies(ctx)
+}
+
+// AuditLog lists the latest denied operations,
+// of every executor when `executorName` is nil
+func (c *Client) AuditLog(ctx context.Context, executorName *string, limit int) ([]sqlc.TinyAuditLog, error) {
+	return c.Resolver.Query().AuditLog(ctx, executorName, limit)
+}
+
+// QuarantinedJobs lists jobs moved to FAILURE after
+// being reset `QuarantineAfter` times in a row.
+func (c *Client) QuarantinedJobs(ctx context.Context, executorName string, limit, offset int) ([]sqlc.TinyJob, error) {
+	return c.Resolver.Query().QuarantinedJobs(
+		ctx,
+		executorName,
+		limit,
+		offset,
+	)
+}
+
+// RequeueJob moves a quarantined job back to READY. It fails with
+// an error satisfying IsDuplicated when an active job took its
+// deduplication key in the meantime.
+func (c *Client) RequeueJob(ctx context.Context, executorName string, id int64) (sqlc.TinyJob, error) {
+	job, err := c.Resolver.Mutation().RequeueJob(
+		ctx,
+		executorName,
+		id,
+	)
+	c.emitJob(EventResumed, executorName, job, err)
+	return job, err
+}
+
+// RestartJob moves a paused or cancelled job back to READY.
+// A running job whose cancel was requested keeps running and
+// the request is withdrawn.
 func (c *Client) RestartJob(ctx context.Context, executorName string, id int64) (sqlc.TinyJob, error) {
-	return c.Resolver.Mutation().RestartJob(
+	job, err := c.Resolver.Mutation().RestartJob(
 		ctx,
 		executorName,
 		id,
 	)
+	c.emitJob(EventResumed, executorName, job, err)
+	return job, err
 }
 
 func (c *Client) Migrate() error {
@@ -358,7 +1017,46 @@ func (c *Client) Migrate() error {
 type Job struct {
 	// TODO: RENAME TO QRON
 	sqlc.TinyJob
-	ch chan<- Job
+	ch       chan<- Job
+	inflight *inFlight
+	failed   bool
+	ctx      context.Context
+	span     trace.Span
+	logger   *slog.Logger
+}
+
+// Logger returns the client logger with the
+// job id, executor and owner as attributes.
+func (j Job) Logger() *slog.Logger {
+	if j.logger == nil {
+		return slog.Default().With("job_id", j.ID, "executor", j.Executor, "owner", j.Owner)
+	}
+	return j.logger
+}
+
+// Context is cancelled when a cancel is requested for the job,
+// or when the job expired and was fetched again.
+// Handlers should stop and acknowledge the job when it is done.
+// It carries the span tracing the job processing.
+func (j Job) Context() context.Context {
+	if j.ctx == nil {
+		return context.Background()
+	}
+	return j.ctx
+}
+
+func (j Job) ack() {
+	if j.inflight != nil {
+		j.inflight.done(j)
+	}
+	if j.span != nil {
+		j.span.SetAttributes(attribute.String("qron.job.status", string(j.Status)))
+		if j.failed {
+			j.span.SetStatus(codes.Error, "job failed")
+		}
+		j.span.End()
+	}
+	j.ch <- j
 }
 
 func (j Job) isOneShot() bool {
@@ -372,22 +1070,28 @@ func (j Job) Commit() {
 		// Else is cron. Should be ready to be picked up again
 		j.Status = sqlc.TinyStatusREADY
 	}
-	j.ch <- j
+	j.ack()
 }
 
 func (j Job) Fail() {
 	j.Status = sqlc.TinyStatusFAILURE
-	j.ch <- j
+	j.failed = true
+	j.ack()
 }
 
 func (j Job) Retry() {
 	j.Status = sqlc.TinyStatusREADY
-	j.ch <- j
+	j.failed = true
+	j.ack()
 }
 
+// IsDuplicated reports whether err is caused by another job already
+// holding the same deduplication key, e.g. when upserting or requeueing
+// a job. `CreateJob` returns the existing job instead of failing.
 func IsDuplicated(err error) bool {
 	if err == nil {
 		return false
 	}
-	return strings.Contains(err.Error(), "job_deduplication_key_constraint")
+	return errors.Is(err, graph.ErrDuplicated) ||
+		strings.Contains(err.Error(), "job_deduplication_key_constraint")
 }
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lucagez/qron"
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// importJobs implements `qron import`. It reads jobs from a JSONL or CSV
+// file (or stdin) and bulk loads them in an existing qron database.
+func importJobs(args []string) {
+	cmd := flag.NewFlagSet("import", flag.ExitOnError)
+	executorName := cmd.String("executor", "", "executor the jobs are imported in")
+	format := cmd.String("format", "", "input format, jsonl or csv. inferred from file extension by default")
+	owner := cmd.String("owner", "default", "owner of the imported jobs")
+	databaseUrl := cmd.String("database-url", os.Getenv("DATABASE_URL"), "postgres connection string")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: qron import -executor <name> [flags] <file|->")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+
+	if *executorName == "" || *databaseUrl == "" || cmd.NArg() != 1 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+
+	input := os.Stdin
+	filename := cmd.Arg(0)
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			log.Fatal("failed to open input:", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(path.Ext(filename), ".")
+	}
+
+	var iter qron.JobIterator
+	switch *format {
+	case "jsonl", "json", "ndjson":
+		iter = newJsonlIterator(input)
+	case "csv":
+		iter = newCsvIterator(input)
+	default:
+		log.Fatalf("unsupported import format: %s", *format)
+	}
+
+	db, err := pgxpool.New(context.Background(), *databaseUrl)
+	if err != nil {
+		log.Fatal("failed to connect to postgres:", err)
+	}
+
+	client, err := qron.NewClient(db, qron.Config{})
+	if err != nil {
+		log.Fatal("failed to create qron client:", err)
+	}
+	defer client.Close()
+
+	t0 := time.Now()
+	ctx := sqlc.NewCtx(context.Background(), *owner)
+	imported, err := client.ImportJobs(ctx, *executorName, iter)
+	elapsed := time.Since(t0)
+	log.Println("imported", imported, "jobs in", elapsed, fmt.Sprintf("(%.0f jobs/s)", float64(imported)/elapsed.Seconds()))
+	if err != nil {
+		log.Fatal("import failed:", err)
+	}
+}
+
+// importLine accepts `state` and `meta` both
+// as JSON values and as strings
+type importLine struct {
+	model.CreateJobArgs
+	State json.RawMessage `json:"state,omitempty"`
+	Meta  json.RawMessage `json:"meta,omitempty"`
+}
+
+// rawString returns strings unquoted and other JSON values as they are
+func rawString(raw json.RawMessage) string {
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		return str
+	}
+	return string(raw)
+}
+
+type jsonlIterator struct {
+	scanner *bufio.Scanner
+	line    int
+	err     error
+}
+
+func newJsonlIterator(r io.Reader) *jsonlIterator {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &jsonlIterator{scanner: scanner}
+}
+
+func (j *jsonlIterator) Next() bool {
+	for j.scanner.Scan() {
+		j.line++
+		if len(j.scanner.Bytes()) > 0 {
+			return true
+		}
+	}
+	j.err = j.scanner.Err()
+	return false
+}
+
+func (j *jsonlIterator) Job() (model.CreateJobArgs, error) {
+	var line importLine
+	err := json.Unmarshal(j.scanner.Bytes(), &line)
+	if err != nil {
+		return model.CreateJobArgs{}, fmt.Errorf("line %d: %w", j.line, err)
+	}
+
+	args := line.CreateJobArgs
+	if len(line.State) > 0 {
+		args.State = rawString(line.State)
+	}
+	if len(line.Meta) > 0 {
+		meta := rawString(line.Meta)
+		args.Meta = &meta
+	}
+	return args, nil
+}
+
+func (j *jsonlIterator) Err() error {
+	return j.err
+}
+
+type csvIterator struct {
+	reader  *csv.Reader
+	header  map[string]int
+	record  []string
+	line    int
+	err     error
+	started bool
+}
+
+func newCsvIterator(r io.Reader) *csvIterator {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	return &csvIterator{reader: reader}
+}
+
+func (c *csvIterator) Next() bool {
+	if !c.started {
+		c.started = true
+		header, err := c.reader.Read()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				c.err = err
+			}
+			return false
+		}
+		c.line++
+		c.header = map[string]int{}
+		for i, column := range header {
+			c.header[column] = i
+		}
+		if _, ok := c.header["expr"]; !ok {
+			c.err = errors.New("csv header must contain an `expr` column")
+			return false
+		}
+	}
+
+	record, err := c.reader.Read()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			c.err = err
+		}
+		return false
+	}
+	c.line++
+	c.record = record
+	return true
+}
+
+func (c *csvIterator) column(name string) (string, bool) {
+	i, ok := c.header[name]
+	if !ok || c.record[i] == "" {
+		return "", false
+	}
+	return c.record[i], true
+}
+
+func (c *csvIterator) Job() (model.CreateJobArgs, error) {
+	var args model.CreateJobArgs
+	args.Expr, _ = c.column("expr")
+	args.Name, _ = c.column("name")
+	args.State, _ = c.column("state")
+
+	if v, ok := c.column("timeout"); ok {
+		timeout, err := strconv.Atoi(v)
+		if err != nil {
+			return args, fmt.Errorf("line %d: invalid timeout: %w", c.line, err)
+		}
+		args.Timeout = &timeout
+	}
+	if v, ok := c.column("retries"); ok {
+		retries, err := strconv.Atoi(v)
+		if err != nil {
+			return args, fmt.Errorf("line %d: invalid retries: %w", c.line, err)
+		}
+		args.Retries = &retries
+	}
+	if v, ok := c.column("start_at"); ok {
+		startAt, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return args, fmt.Errorf("line %d: invalid start_at: %w", c.line, err)
+		}
+		args.StartAt = &startAt
+	}
+	if v, ok := c.column("meta"); ok {
+		args.Meta = &v
+	}
+	if v, ok := c.column("deduplication_key"); ok {
+		args.DeduplicationKey = &v
+	}
+	if v, ok := c.column("concurrency_key"); ok {
+		args.ConcurrencyKey = &v
+	}
+	if v, ok := c.column("concurrency_limit"); ok {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return args, fmt.Errorf("line %d: invalid concurrency_limit: %w", c.line, err)
+		}
+		args.ConcurrencyLimit = &limit
+	}
+
+	return args, nil
+}
+
+func (c *csvIterator) Err() error {
+	return c.err
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonlIterator(t *testing.T) {
+	iter := newJsonlIterator(strings.NewReader(`{"expr":"@after 1 hour","state":{"step":1},"meta":{"url":"a"}}
+
+{"expr":"@after 1 hour","state":"encrypted","meta":"{\"url\":\"b\"}"}
+`))
+
+	assert.True(t, iter.Next())
+	job, err := iter.Job()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"step":1}`, job.State)
+	assert.Equal(t, `{"url":"a"}`, *job.Meta)
+
+	assert.True(t, iter.Next())
+	job, err = iter.Job()
+	assert.Nil(t, err)
+	assert.Equal(t, "encrypted", job.State)
+	assert.Equal(t, `{"url":"b"}`, *job.Meta)
+
+	assert.False(t, iter.Next())
+	assert.Nil(t, iter.Err())
+}
@@ -40,6 +40,11 @@ func freePort() int {
 func main() {
 	godotenv.Load()
 
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		importJobs(os.Args[2:])
+		return
+	}
+
 	httpPort := flag.Int("port", 9876, "port to listen on")
 	flag.Parse()
 
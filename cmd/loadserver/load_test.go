@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/lucagez/qron"
 	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
 	"github.com/lucagez/qron/testutil"
 )
 
@@ -46,17 +49,17 @@ func BenchmarkFetch(b *testing.B) {
 			})
 		}
 
-		_, err = client.BatchCreateJobs(context.Background(), "benchmark-1", batch)
+		_, err = client.BatchCreateJobs(context.Background(), "benchmark-1", batch, model.BatchModeAtomic)
 		if err != nil {
 			b.Fatal(err)
 		}
 
-		_, err = client.BatchCreateJobs(context.Background(), "benchmark-2", batch)
+		_, err = client.BatchCreateJobs(context.Background(), "benchmark-2", batch, model.BatchModeAtomic)
 		if err != nil {
 			b.Fatal(err)
 		}
 
-		_, err = client.BatchCreateJobs(context.Background(), "benchmark-3", batch)
+		_, err = client.BatchCreateJobs(context.Background(), "benchmark-3", batch, model.BatchModeAtomic)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -100,3 +103,134 @@ loop:
 	fmt.Println("processed", counter, "jobs in", time.Since(t0))
 	fmt.Println("average throughput per second:", float64(counter)/time.Since(t0).Seconds())
 }
+
+func BenchmarkImport(b *testing.B) {
+	b.StopTimer()
+
+	testutil.PG = testutil.NewPgFactory()
+	defer testutil.PG.Teardown()
+
+	pool, cleanup := testutil.PG.CreateDb("bench_import")
+	defer cleanup()
+
+	client, err := qron.NewClient(pool, qron.Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer client.Close()
+
+	const total = 1000000
+	var jobs []model.CreateJobArgs
+	for i := 0; i < total; i++ {
+		jobs = append(jobs, model.CreateJobArgs{
+			Expr: "@after 1 hour",
+		})
+	}
+
+	b.ResetTimer()
+
+	t0 := time.Now()
+	for i := 0; i < total; i += 10000 {
+		_, err = client.BatchCreateJobs(context.Background(), "batch", jobs[i:i+10000], model.BatchModeAtomic)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	fmt.Println("batch created", total, "jobs in", time.Since(t0))
+	fmt.Println("batch create throughput per second:", float64(total)/time.Since(t0).Seconds())
+
+	t0 = time.Now()
+	imported, err := client.ImportJobs(context.Background(), "import", qron.JobsFromSlice(jobs))
+	if err != nil {
+		b.Fatal(err)
+	}
+	fmt.Println("imported", imported, "jobs in", time.Since(t0))
+	fmt.Println("import throughput per second:", float64(imported)/time.Since(t0).Seconds())
+}
+
+func BenchmarkFairFetch(b *testing.B) {
+	b.StopTimer()
+
+	testutil.PG = testutil.NewPgFactory()
+	defer testutil.PG.Teardown()
+
+	for _, mode := range []model.FetchMode{model.FetchModeFifo, model.FetchModeFair} {
+		benchmarkTenantLatency(b, mode)
+	}
+}
+
+// benchmarkTenantLatency measures how long jobs of small tenants wait
+// to be delivered while a large tenant holds a backlog on the same executor
+func benchmarkTenantLatency(b *testing.B, mode model.FetchMode) {
+	pool, cleanup := testutil.PG.CreateDb(fmt.Sprintf("bench_fair_%s", strings.ToLower(mode.String())))
+	defer cleanup()
+
+	client, err := qron.NewClient(pool, qron.Config{
+		PollInterval:  3 * time.Millisecond,
+		FlushInterval: 6 * time.Millisecond,
+		ResetInterval: 10 * time.Minute,
+		MaxInFlight:   1000,
+		MaxFlushSize:  1000,
+		FetchMode:     mode,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer client.Close()
+
+	const executor = "tenants"
+	const bigTenantJobs = 500000
+	const smallTenants = 20
+	const smallTenantJobs = 10
+
+	var backlog []model.CreateJobArgs
+	for i := 0; i < bigTenantJobs; i++ {
+		backlog = append(backlog, model.CreateJobArgs{Expr: "@after 1ms"})
+	}
+	_, err = client.ImportJobs(sqlc.NewCtx(context.Background(), "big-tenant"), executor, qron.JobsFromSlice(backlog))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < smallTenants; i++ {
+		var jobs []model.CreateJobArgs
+		for j := 0; j < smallTenantJobs; j++ {
+			jobs = append(jobs, model.CreateJobArgs{Expr: "@after 1ms"})
+		}
+		owner := sqlc.NewCtx(context.Background(), fmt.Sprintf("small-tenant-%d", i))
+		_, err = client.ImportJobs(owner, executor, qron.JobsFromSlice(jobs))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	ctx, stop := context.WithTimeout(context.Background(), 60*time.Second)
+	defer stop()
+
+	b.ResetTimer()
+	t0 := time.Now()
+
+	var latencies []time.Duration
+	for job := range client.Fetch(ctx, executor) {
+		if job.Owner != "big-tenant" {
+			latencies = append(latencies, time.Since(job.RunAt.Time))
+		}
+		job.Commit()
+
+		if len(latencies) == smallTenants*smallTenantJobs {
+			stop()
+		}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	if len(latencies) == 0 {
+		b.Fatal("no small tenant job was delivered")
+	}
+
+	fmt.Println("mode:", mode, "delivered", len(latencies), "small tenant jobs in", time.Since(t0))
+	fmt.Println("small tenant p50 latency:", latencies[len(latencies)/2])
+	fmt.Println("small tenant p99 latency:", latencies[len(latencies)*99/100])
+	fmt.Println("small tenant max latency:", latencies[len(latencies)-1])
+}
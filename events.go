@@ -0,0 +1,96 @@
+package qron
+
+import (
+	"errors"
+
+	"github.com/lucagez/qron/sqlc"
+)
+
+type EventKind string
+
+// Every status transition performed by the client emits an event:
+// fetches, flushes, resets of timed out jobs and of jobs held by
+// expired workers, and the job mutation methods pausing, resuming,
+// cancelling or deleting jobs. Pausing and resuming an executor emit
+// an event too. Creating and updating jobs emit no event.
+// Transitions performed by other clients or through the API are
+// only observed by the hooks of those clients.
+const (
+	EventFetched         EventKind = "FETCHED"
+	EventStarted         EventKind = "STARTED"
+	EventCommitted       EventKind = "COMMITTED"
+	EventFailed          EventKind = "FAILED"
+	EventRetried         EventKind = "RETRIED"
+	EventReset           EventKind = "RESET"
+	EventPaused          EventKind = "PAUSED"
+	EventResumed         EventKind = "RESUMED"
+	EventCancelled       EventKind = "CANCELLED"
+	EventDeleted         EventKind = "DELETED"
+	EventExecutorPaused  EventKind = "EXECUTOR_PAUSED"
+	EventExecutorResumed EventKind = "EXECUTOR_RESUMED"
+)
+
+// ErrRejected is the error of events about jobs the
+// server refused to update, e.g. after their lease expired
+var ErrRejected = errors.New("job rejected")
+
+// Event describes a job transition observed by the client.
+type Event struct {
+	Kind     EventKind
+	Executor string
+	// Job is the snapshot of the job at the time of the event.
+	// Reset events only carry the job id, while events of
+	// operations by filter or on executors carry no job at all
+	Job sqlc.TinyJob
+	// Count is the amount of jobs affected by the event.
+	// It is one for events about a single job
+	Count int
+	Err   error
+}
+
+// emit calls the OnEvent hook when configured.
+// Hooks are called synchronously, so they should not block
+func (t *Client) emit(event Event) {
+	if t.OnEvent == nil {
+		return
+	}
+	t.OnEvent(event)
+}
+
+// emitJob emits an event about a single job
+// returned by a mutation method
+func (t *Client) emitJob(kind EventKind, executorName string, job sqlc.TinyJob, err error) {
+	t.emit(Event{
+		Kind:     kind,
+		Executor: executorName,
+		Job:      job,
+		Count:    1,
+		Err:      err,
+	})
+}
+
+// emitBatch emits an event for every job of a flushed batch.
+// Rejected jobs carry ErrRejected. Committed cron jobs are
+// flushed with the retried ones, as both are READY again
+func (t *Client) emitBatch(kind EventKind, executorName string, jobs []Job, rejected []int64, err error) {
+	if t.OnEvent == nil {
+		return
+	}
+
+	isRejected := map[int64]bool{}
+	for _, id := range rejected {
+		isRejected[id] = true
+	}
+
+	for _, job := range jobs {
+		jobErr := err
+		if jobErr == nil && isRejected[job.ID] {
+			jobErr = ErrRejected
+		}
+		jobKind := kind
+		if kind == EventRetried && !job.failed {
+			jobKind = EventCommitted
+		}
+		t.emitJob(jobKind, executorName, job.TinyJob, jobErr)
+	}
+}
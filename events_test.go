@@ -0,0 +1,137 @@
+package qron
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientEvents(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("events")
+	defer cleanup()
+
+	var mu sync.Mutex
+	events := map[EventKind][]Event{}
+
+	client, err := NewClient(pool, Config{
+		PollInterval:  10 * time.Millisecond,
+		FlushInterval: 10 * time.Millisecond,
+		OnEvent: func(event Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events[event.Kind] = append(events[event.Kind], event)
+		},
+	})
+	assert.Nil(t, err)
+
+	count := func(kind EventKind) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events[kind])
+	}
+
+	t.Run("Should emit processing events", func(t *testing.T) {
+		for _, name := range []string{"commit", "fail", "retry"} {
+			_, err := client.CreateJob(context.Background(), "events", model.CreateJobArgs{
+				Expr: "@after 10ms",
+				Name: name,
+			})
+			assert.Nil(t, err)
+		}
+
+		ctx, stop := context.WithCancel(context.Background())
+		jobs := client.Fetch(ctx, "events")
+
+		go func() {
+			<-time.After(300 * time.Millisecond)
+			stop()
+		}()
+
+		for job := range jobs {
+			switch job.Name {
+			case "commit":
+				job.Commit()
+			case "fail":
+				job.Fail()
+			case "retry":
+				job.Retry()
+			}
+		}
+
+		assert.Equal(t, 3, count(EventFetched))
+		assert.Equal(t, 3, count(EventStarted))
+		assert.Equal(t, 1, count(EventCommitted))
+		assert.Equal(t, 1, count(EventFailed))
+		assert.GreaterOrEqual(t, count(EventRetried), 1)
+
+		mu.Lock()
+		committed := events[EventCommitted][0]
+		mu.Unlock()
+		assert.Equal(t, "commit", committed.Job.Name)
+		assert.Equal(t, "events", committed.Executor)
+		assert.Nil(t, committed.Err)
+	})
+
+	t.Run("Should emit mutation events", func(t *testing.T) {
+		job, err := client.CreateJob(context.Background(), "mutations", model.CreateJobArgs{
+			Expr: "@every 1 hour",
+			Name: "mutated",
+		})
+		assert.Nil(t, err)
+
+		_, err = client.StopJob(context.Background(), "mutations", job.ID)
+		assert.Nil(t, err)
+		_, err = client.DeleteJobByID(context.Background(), "mutations", job.ID)
+		assert.Nil(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Len(t, events[EventPaused], 1)
+		assert.Equal(t, job.ID, events[EventPaused][0].Job.ID)
+		assert.Len(t, events[EventDeleted], 1)
+		assert.Equal(t, job.ID, events[EventDeleted][0].Job.ID)
+	})
+
+	t.Run("Should emit cancel and resume events", func(t *testing.T) {
+		job, err := client.CreateJob(context.Background(), "cancellations", model.CreateJobArgs{
+			Expr: "@every 1 hour",
+			Name: "cancelled",
+		})
+		assert.Nil(t, err)
+
+		_, err = client.CancelJob(context.Background(), "cancellations", job.ID)
+		assert.Nil(t, err)
+		_, err = client.RestartJob(context.Background(), "cancellations", job.ID)
+		assert.Nil(t, err)
+		_, err = client.ResumeJobs(context.Background(), "cancellations", model.JobsFilter{})
+		assert.Nil(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Len(t, events[EventCancelled], 1)
+		assert.Equal(t, job.ID, events[EventCancelled][0].Job.ID)
+		assert.Equal(t, sqlc.TinyStatusCANCELLED, events[EventCancelled][0].Job.Status)
+		assert.Len(t, events[EventResumed], 2)
+		assert.Equal(t, job.ID, events[EventResumed][0].Job.ID)
+		assert.Equal(t, 0, events[EventResumed][1].Count)
+	})
+
+	t.Run("Should emit executor events", func(t *testing.T) {
+		_, err := client.PauseExecutor(context.Background(), "paused-executor")
+		assert.Nil(t, err)
+		_, err = client.ResumeExecutor(context.Background(), "paused-executor")
+		assert.Nil(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Len(t, events[EventExecutorPaused], 1)
+		assert.Equal(t, "paused-executor", events[EventExecutorPaused][0].Executor)
+		assert.Len(t, events[EventExecutorResumed], 1)
+	})
+}
@@ -3,12 +3,15 @@ package executor
 import (
 	"bytes"
 	"encoding/json"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/lucagez/qron"
 	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type HttpExecutor struct {
@@ -54,19 +57,26 @@ func (h HttpExecutor) Run(job qron.Job) {
 		return
 	}
 
+	ctx, span := tracing.Tracer().Start(job.Context(), "qron.http", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("http.method", config.Method),
+		attribute.String("http.url", config.Url),
+	))
+	defer span.End()
+
 	payload, _ := json.Marshal(job)
-	req, err := http.NewRequest(config.Method, config.Url, bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, config.Method, config.Url, bytes.NewReader(payload))
 	if err != nil {
-		log.Println("request creation error:", err)
+		job.Logger().Error("request creation error", "error", err)
 		job.Fail()
 		return
 	}
 
 	req.Header.Add("content-type", "application/json")
+	tracing.InjectHeaders(ctx, req.Header)
 
 	err = h.Signer(job, req)
 	if err != nil {
-		log.Println("signer error:", err)
+		job.Logger().Error("signer error", "error", err)
 		job.Fail()
 		return
 	}
@@ -75,11 +85,14 @@ func (h HttpExecutor) Run(job qron.Job) {
 
 	res, err := h.client.Do(req)
 	if err != nil {
-		log.Println("http error:", err)
+		job.Logger().Error("http error", "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		job.Fail()
 		return
 	}
 	defer res.Body.Close()
+	span.SetAttributes(attribute.Int("http.status_code", res.StatusCode))
 
 	<-h.limiter
 
@@ -88,7 +101,7 @@ func (h HttpExecutor) Run(job qron.Job) {
 	if err != nil {
 		// TODO: In case body arrives but it's null
 		// it should just not update job and NOT retrun an error
-		log.Println("invalid response payload:", err)
+		job.Logger().Error("invalid response payload", "error", err)
 
 		// TODO: Handle errors and automatic retries
 		job.Fail()
@@ -0,0 +1,52 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.34
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/graph/generated"
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// Kind is the resolver for the kind field.
+func (r *anomalyResolver) Kind(ctx context.Context, obj *sqlc.ListAnomaliesRow) (model.AnomalyKind, error) {
+	return model.AnomalyKind(obj.Kind), nil
+}
+
+// RunAt is the resolver for the run_at field.
+func (r *anomalyResolver) RunAt(ctx context.Context, obj *sqlc.ListAnomaliesRow) (time.Time, error) {
+	return obj.RunAt.Time, nil
+}
+
+// LastRunAt is the resolver for the last_run_at field.
+func (r *anomalyResolver) LastRunAt(ctx context.Context, obj *sqlc.ListAnomaliesRow) (*time.Time, error) {
+	if !obj.LastRunAt.Valid {
+		return nil, nil
+	}
+	return &obj.LastRunAt.Time, nil
+}
+
+// Anomalies is the resolver for the anomalies field.
+func (r *queryResolver) Anomalies(ctx context.Context, executor string, maxResets int, overdueAfter int, staleCronAfter int, limit int) ([]sqlc.ListAnomaliesRow, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return nil, err
+	}
+	return r.Queries.ListAnomalies(ctx, sqlc.ListAnomaliesParams{
+		Executor:       executor,
+		MaxResets:      int32(maxResets),
+		OverdueAfter:   int32(overdueAfter),
+		StaleCronAfter: int32(staleCronAfter),
+		Limit:          int32(limit),
+	})
+}
+
+// Anomaly returns generated.AnomalyResolver implementation.
+func (r *Resolver) Anomaly() generated.AnomalyResolver { return &anomalyResolver{r} }
+
+type anomalyResolver struct{ *Resolver }
@@ -0,0 +1,107 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnomalies(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("anomalies")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+
+	t.Run("Should detect overdue and stale jobs", func(t *testing.T) {
+		executor := "overdue"
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 second",
+			Name:  "one-shot",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+		_, err = resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@every 1 second",
+			Name:  "cron",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+		_, err = resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 hour",
+			Name:  "on-time",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		anomalies, err := resolver.Query().Anomalies(ctx, executor, 3, 0, 0, 100)
+		assert.Nil(t, err)
+		assert.Len(t, anomalies, 0)
+
+		time.Sleep(2 * time.Second)
+
+		anomalies, err = resolver.Query().Anomalies(ctx, executor, 3, 0, 0, 100)
+		assert.Nil(t, err)
+		assert.Len(t, anomalies, 2)
+
+		kinds := map[string]string{}
+		for _, anomaly := range anomalies {
+			kinds[anomaly.Name] = anomaly.Kind
+		}
+		assert.Equal(t, "OVERDUE", kinds["one-shot"])
+		assert.Equal(t, "STALE_CRON", kinds["cron"])
+
+		// Within thresholds
+		anomalies, err = resolver.Query().Anomalies(ctx, executor, 3, 300, 3600, 100)
+		assert.Nil(t, err)
+		assert.Len(t, anomalies, 0)
+	})
+
+	t.Run("Should detect jobs reset repeatedly", func(t *testing.T) {
+		executor := "resets"
+		timeout := 1
+		job, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:    "@after 1 second",
+			Name:    "poison",
+			State:   "{}",
+			Timeout: &timeout,
+		})
+		assert.Nil(t, err)
+
+		for i := 0; i < 2; i++ {
+			time.Sleep(1 * time.Second)
+			jobs, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
+			assert.Nil(t, err)
+			assert.Len(t, jobs, 1)
+
+			time.Sleep(2 * time.Second)
+			ids, err := queries.ResetTimeoutJobs(ctx, sqlc.ResetTimeoutJobsParams{Executor: executor})
+			assert.Nil(t, err)
+			assert.Equal(t, []int64{job.ID}, ids)
+		}
+
+		anomalies, err := resolver.Query().Anomalies(ctx, executor, 2, 300, 3600, 100)
+		assert.Nil(t, err)
+		assert.Len(t, anomalies, 1)
+		assert.Equal(t, "REPEATED_RESETS", anomalies[0].Kind)
+		assert.Equal(t, int32(2), anomalies[0].ResetCount)
+
+		// Acknowledging the job clears the counter
+		jobs, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, jobs, 1)
+		failed, err := resolver.Mutation().RetryJobs(ctx, executor, []model.CommitArgs{{ID: job.ID, Lease: int(jobs[0].Lease)}})
+		assert.Nil(t, err)
+		assert.Len(t, failed, 0)
+
+		job, err = resolver.Query().QueryJobByID(ctx, executor, job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int32(0), job.ResetCount)
+	})
+}
@@ -0,0 +1,129 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.34
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/graph/generated"
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// Scopes is the resolver for the scopes field.
+func (r *apiKeyResolver) Scopes(ctx context.Context, obj *sqlc.TinyApiKey) ([]auth.Scope, error) {
+	scopes := make([]auth.Scope, len(obj.Scopes))
+	for i, scope := range obj.Scopes {
+		scopes[i] = auth.Scope(scope)
+	}
+	return scopes, nil
+}
+
+// CreatedAt is the resolver for the created_at field.
+func (r *apiKeyResolver) CreatedAt(ctx context.Context, obj *sqlc.TinyApiKey) (time.Time, error) {
+	return obj.CreatedAt.Time, nil
+}
+
+// ExpiresAt is the resolver for the expires_at field.
+func (r *apiKeyResolver) ExpiresAt(ctx context.Context, obj *sqlc.TinyApiKey) (*time.Time, error) {
+	if !obj.ExpiresAt.Valid {
+		return nil, nil
+	}
+	return &obj.ExpiresAt.Time, nil
+}
+
+// RevokedAt is the resolver for the revoked_at field.
+func (r *apiKeyResolver) RevokedAt(ctx context.Context, obj *sqlc.TinyApiKey) (*time.Time, error) {
+	if !obj.RevokedAt.Valid {
+		return nil, nil
+	}
+	return &obj.RevokedAt.Time, nil
+}
+
+// IssueAPIKey is the resolver for the issueApiKey field.
+func (r *mutationResolver) IssueAPIKey(ctx context.Context, name string, scopes []auth.Scope, executors []string, roles []string, expiresAt *time.Time) (model.IssuedAPIKey, error) {
+	if err := r.authorize(ctx, "", auth.OperationAdmin); err != nil {
+		return model.IssuedAPIKey{}, err
+	}
+	if len(scopes) == 0 {
+		return model.IssuedAPIKey{}, validationError(ctx, "scopes", "is required")
+	}
+
+	// Keys can't be granted executors the issuer has no access
+	// to, and inherit the restrictions of the issuer by default
+	if principal, ok := auth.FromCtx(ctx); ok {
+		if len(roles) == 0 {
+			roles = principal.Roles
+		}
+		if len(executors) == 0 {
+			executors = principal.Executors
+		}
+		for _, executor := range executors {
+			if !principal.CanAccess(executor) {
+				return model.IssuedAPIKey{}, fmt.Errorf("%w: no access to executor %s", auth.ErrForbidden, executor)
+			}
+		}
+	}
+	if executors == nil {
+		executors = []string{}
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+
+	key, prefix, hash, err := auth.NewKey()
+	if err != nil {
+		return model.IssuedAPIKey{}, err
+	}
+
+	params := sqlc.CreateApiKeyParams{
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Owner:     sqlc.FromCtx(ctx),
+		Executors: executors,
+		Roles:     roles,
+	}
+	for _, scope := range scopes {
+		params.Scopes = append(params.Scopes, string(scope))
+	}
+	if expiresAt != nil {
+		params.ExpiresAt = pgtype.Timestamptz{Time: *expiresAt, Valid: true}
+	}
+
+	apiKey, err := r.Queries.CreateApiKey(ctx, params)
+	if err != nil {
+		return model.IssuedAPIKey{}, err
+	}
+	return model.IssuedAPIKey{Key: key, APIKey: apiKey}, nil
+}
+
+// RevokeAPIKey is the resolver for the revokeApiKey field.
+func (r *mutationResolver) RevokeAPIKey(ctx context.Context, id int64) (sqlc.TinyApiKey, error) {
+	if err := r.authorize(ctx, "", auth.OperationAdmin); err != nil {
+		return sqlc.TinyApiKey{}, err
+	}
+	return r.Queries.RevokeApiKey(ctx, sqlc.RevokeApiKeyParams{
+		ID:    id,
+		Owner: sqlc.FromCtx(ctx),
+	})
+}
+
+// APIKeys is the resolver for the apiKeys field.
+func (r *queryResolver) APIKeys(ctx context.Context) ([]sqlc.TinyApiKey, error) {
+	if err := r.authorize(ctx, "", auth.OperationAdmin); err != nil {
+		return nil, err
+	}
+	return r.Queries.ListApiKeys(ctx, sqlc.FromCtx(ctx))
+}
+
+// ApiKey returns generated.ApiKeyResolver implementation.
+func (r *Resolver) ApiKey() generated.ApiKeyResolver { return &apiKeyResolver{r} }
+
+type apiKeyResolver struct{ *Resolver }
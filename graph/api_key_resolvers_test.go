@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiKey(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("api_key")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	authenticator := auth.New(queries, auth.Config{})
+	ctx := sqlc.NewCtx(context.Background(), "acme")
+
+	t.Run("Should authenticate issued key", func(t *testing.T) {
+		issued, err := resolver.Mutation().IssueAPIKey(ctx, "deploy", []auth.Scope{auth.ScopeRead, auth.ScopeWrite}, []string{"email"}, nil, nil)
+		assert.Nil(t, err)
+		assert.Contains(t, issued.Key, issued.APIKey.Prefix)
+		assert.NotContains(t, issued.APIKey.Hash, issued.Key)
+		assert.Equal(t, "acme", issued.APIKey.Owner)
+
+		principal, err := authenticator.Authenticate(context.Background(), issued.Key)
+		assert.Nil(t, err)
+		assert.Equal(t, "acme", principal.Owner)
+		assert.Equal(t, []auth.Scope{auth.ScopeRead, auth.ScopeWrite}, principal.Scopes)
+		assert.Equal(t, []string{"email"}, principal.Executors)
+
+		_, err = authenticator.Authenticate(context.Background(), issued.Key+"x")
+		assert.ErrorIs(t, err, auth.ErrUnauthenticated)
+	})
+
+	t.Run("Should reject revoked and expired keys", func(t *testing.T) {
+		issued, err := resolver.Mutation().IssueAPIKey(ctx, "revoked", []auth.Scope{auth.ScopeRead}, nil, nil, nil)
+		assert.Nil(t, err)
+
+		revoked, err := resolver.Mutation().RevokeAPIKey(ctx, issued.APIKey.ID)
+		assert.Nil(t, err)
+		assert.True(t, revoked.RevokedAt.Valid)
+
+		_, err = authenticator.Authenticate(context.Background(), issued.Key)
+		assert.ErrorIs(t, err, auth.ErrUnauthenticated)
+
+		past := time.Now().Add(-time.Minute)
+		expired, err := resolver.Mutation().IssueAPIKey(ctx, "expired", []auth.Scope{auth.ScopeRead}, nil, nil, &past)
+		assert.Nil(t, err)
+
+		_, err = authenticator.Authenticate(context.Background(), expired.Key)
+		assert.ErrorIs(t, err, auth.ErrUnauthenticated)
+	})
+
+	t.Run("Should scope keys to owner", func(t *testing.T) {
+		other := sqlc.NewCtx(context.Background(), "other")
+		issued, err := resolver.Mutation().IssueAPIKey(other, "other", []auth.Scope{auth.ScopeAdmin}, nil, nil, nil)
+		assert.Nil(t, err)
+
+		keys, err := resolver.Query().APIKeys(ctx)
+		assert.Nil(t, err)
+		for _, key := range keys {
+			assert.Equal(t, "acme", key.Owner)
+		}
+
+		_, err = resolver.Mutation().RevokeAPIKey(ctx, issued.APIKey.ID)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should not grant executors out of reach of the issuer", func(t *testing.T) {
+		issuer := auth.NewCtx(ctx, auth.Principal{
+			Owner:     "acme",
+			Scopes:    []auth.Scope{auth.ScopeAdmin},
+			Executors: []string{"email"},
+		})
+
+		_, err := resolver.Mutation().IssueAPIKey(issuer, "sms", []auth.Scope{auth.ScopeRead}, []string{"sms"}, nil, nil)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+
+		issued, err := resolver.Mutation().IssueAPIKey(issuer, "inherited", []auth.Scope{auth.ScopeRead}, nil, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"email"}, issued.APIKey.Executors)
+
+		_, err = resolver.Mutation().IssueAPIKey(ctx, "empty", nil, nil, nil, nil)
+		assert.NotNil(t, err)
+	})
+}
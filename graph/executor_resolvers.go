@@ -0,0 +1,48 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.34
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/graph/generated"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// PauseExecutor is the resolver for the pauseExecutor field.
+func (r *mutationResolver) PauseExecutor(ctx context.Context, executor string) (sqlc.TinyExecutor, error) {
+	if err := r.authorize(ctx, executor, auth.OperationManage); err != nil {
+		return sqlc.TinyExecutor{}, err
+	}
+	return r.Queries.PauseExecutor(ctx, executor)
+}
+
+// ResumeExecutor is the resolver for the resumeExecutor field.
+func (r *mutationResolver) ResumeExecutor(ctx context.Context, executor string) (sqlc.TinyExecutor, error) {
+	if err := r.authorize(ctx, executor, auth.OperationManage); err != nil {
+		return sqlc.TinyExecutor{}, err
+	}
+	return r.Queries.ResumeExecutor(ctx, executor)
+}
+
+// PausedAt is the resolver for the paused_at field.
+func (r *tinyExecutorResolver) PausedAt(ctx context.Context, obj *sqlc.TinyExecutor) (*time.Time, error) {
+	if !obj.PausedAt.Valid {
+		return nil, nil
+	}
+	return &obj.PausedAt.Time, nil
+}
+
+// UpdatedAt is the resolver for the updated_at field.
+func (r *tinyExecutorResolver) UpdatedAt(ctx context.Context, obj *sqlc.TinyExecutor) (time.Time, error) {
+	return obj.UpdatedAt.Time, nil
+}
+
+// TinyExecutor returns generated.TinyExecutorResolver implementation.
+func (r *Resolver) TinyExecutor() generated.TinyExecutorResolver { return &tinyExecutorResolver{r} }
+
+type tinyExecutorResolver struct{ *Resolver }
@@ -0,0 +1,87 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPauseExecutor(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("pause_executor")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+	executor := "http"
+
+	t.Run("Should not fetch jobs of paused executor", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+				Expr:  "@after 1 second",
+				Name:  fmt.Sprintf("paused-%d", i),
+				State: "{}",
+			})
+			assert.Nil(t, err)
+		}
+		time.Sleep(1 * time.Second)
+
+		paused, err := resolver.Mutation().PauseExecutor(ctx, executor)
+		assert.Nil(t, err)
+		assert.True(t, paused.Paused)
+		assert.True(t, paused.PausedAt.Valid)
+
+		for _, mode := range []model.FetchMode{model.FetchModeFifo, model.FetchModeFair} {
+			fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 10, nil, mode)
+			assert.Nil(t, err)
+			assert.Len(t, fetch, 0)
+		}
+
+		resumed, err := resolver.Mutation().ResumeExecutor(ctx, executor)
+		assert.Nil(t, err)
+		assert.False(t, resumed.Paused)
+		assert.False(t, resumed.PausedAt.Valid)
+
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 5)
+	})
+
+	t.Run("Should not let tenants pause jobs of other owners", func(t *testing.T) {
+		bob := sqlc.NewCtx(context.Background(), "bob")
+		_, err := resolver.Mutation().CreateJob(bob, "shared", model.CreateJobArgs{
+			Expr:  "@after 1 second",
+			Name:  "bob-job",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+		time.Sleep(1 * time.Second)
+
+		alice := auth.NewCtx(sqlc.NewCtx(context.Background(), "alice"), auth.Principal{
+			Subject: "alice",
+			Owner:   "alice",
+			Scopes:  []auth.Scope{auth.ScopeRead, auth.ScopeWrite},
+		})
+		_, err = resolver.Mutation().PauseExecutor(alice, "shared")
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+		_, err = resolver.Mutation().ResumeExecutor(alice, "shared")
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+
+		fetch, err := resolver.Mutation().FetchForProcessing(bob, "shared", 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 1)
+	})
+
+	t.Run("Should resume executor never paused", func(t *testing.T) {
+		resumed, err := resolver.Mutation().ResumeExecutor(ctx, "never-paused")
+		assert.Nil(t, err)
+		assert.False(t, resumed.Paused)
+	})
+}
@@ -0,0 +1,46 @@
+package graph
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// filterParams maps a JobsFilter to the arguments shared by
+// every bulk query. Missing filters are left null
+func filterParams(executor string, filter model.JobsFilter) sqlc.PauseJobsByFilterParams {
+	params := sqlc.PauseJobsByFilterParams{
+		Executor: executor,
+	}
+	if filter.Query != nil {
+		params.Query = pgtype.Text{String: *filter.Query, Valid: true}
+	}
+	if filter.Statuses != nil {
+		params.Statuses = pgtype.Text{String: joinStatuses(filter.Statuses), Valid: true}
+	}
+	if filter.From != nil {
+		params.From = pgtype.Timestamptz{Time: *filter.From, Valid: true}
+	}
+	if filter.To != nil {
+		params.To = pgtype.Timestamptz{Time: *filter.To, Valid: true}
+	}
+	if filter.Name != nil {
+		params.Name = pgtype.Text{String: *filter.Name, Valid: true}
+	}
+	if filter.IsOneShot != nil {
+		params.IsOneShot = pgtype.Bool{Bool: *filter.IsOneShot, Valid: true}
+	}
+	return params
+}
+
+// joinStatuses serializes statuses for queries
+// filtering with `string_to_array`
+func joinStatuses(statuses []sqlc.TinyStatus) string {
+	values := make([]string, len(statuses))
+	for i, status := range statuses {
+		values[i] = string(status)
+	}
+	return strings.Join(values, ",")
+}
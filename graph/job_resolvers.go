@@ -7,15 +7,18 @@ package graph
 import (
 	"context"
 	"errors"
-	"log"
-	"strings"
+	"fmt"
 	"time"
 
 	pgx "github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/lucagez/qron/auth"
 	"github.com/lucagez/qron/graph/generated"
 	"github.com/lucagez/qron/graph/model"
 	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ValidateExprFormat is the resolver for the validateExprFormat field.
@@ -25,6 +28,13 @@ func (r *mutationResolver) ValidateExprFormat(ctx context.Context, expr string)
 
 // CreateJob is the resolver for the createJob field.
 func (r *mutationResolver) CreateJob(ctx context.Context, executor string, args model.CreateJobArgs) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationCreate); err != nil {
+		return sqlc.TinyJob{}, err
+	}
+	if err := validateCreateJobArgs(ctx, "args", args); err != nil {
+		return sqlc.TinyJob{}, err
+	}
+
 	var timeout int32
 	if args.Timeout != nil {
 		timeout = int32(*args.Timeout)
@@ -39,6 +49,8 @@ func (r *mutationResolver) CreateJob(ctx context.Context, executor string, args
 	if args.Meta != nil {
 		meta = []byte(*args.Meta)
 	}
+	// Handlers continue the trace of the request creating the job
+	meta = tracing.Inject(ctx, meta)
 
 	var retries int32
 	if args.Retries != nil {
@@ -61,24 +73,143 @@ func (r *mutationResolver) CreateJob(ctx context.Context, executor string, args
 		hash.Scan(*args.DeduplicationKey)
 		params.DeduplicationKey = hash
 	}
+	if args.DeduplicationWindow != nil {
+		params.DeduplicationWindow = pgtype.Int4{Int32: int32(*args.DeduplicationWindow), Valid: true}
+	}
+	if args.ConcurrencyKey != nil {
+		params.ConcurrencyKey = pgtype.Text{String: *args.ConcurrencyKey, Valid: true}
+	}
+	if args.ConcurrencyLimit != nil {
+		params.ConcurrencyLimit = int32(*args.ConcurrencyLimit)
+	}
 
-	return r.Queries.CreateJob(ctx, params)
+	job, err := r.Queries.CreateJob(ctx, params)
+	if errors.Is(err, pgx.ErrNoRows) && params.DeduplicationKey.Valid {
+		// Nothing was inserted as the deduplication key is taken.
+		// The existing job is returned in place of the new one
+		return r.Queries.GetDuplicatedJob(ctx, sqlc.GetDuplicatedJobParams{
+			DeduplicationKey:    params.DeduplicationKey.String,
+			Owner:               params.Owner,
+			Executor:            executor,
+			DeduplicationWindow: params.DeduplicationWindow,
+		})
+	}
+	if err != nil {
+		return job, constraintError(ctx, "args", err)
+	}
+
+	return job, nil
+}
+
+// CreateOrUpdateJob is the resolver for the createOrUpdateJob field.
+func (r *mutationResolver) CreateOrUpdateJob(ctx context.Context, executor string, args model.CreateJobArgs, mode model.UpsertMode) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationCreate); err != nil {
+		return sqlc.TinyJob{}, err
+	}
+	// Keeping the existing job never modifies it
+	if mode != model.UpsertModeKeepExisting {
+		if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+			return sqlc.TinyJob{}, err
+		}
+	}
+	if args.Name == "" {
+		return sqlc.TinyJob{}, validationError(ctx, "args.name", "is required to create or update a job")
+	}
+	if err := validateCreateJobArgs(ctx, "args", args); err != nil {
+		return sqlc.TinyJob{}, err
+	}
+	if !mode.IsValid() {
+		return sqlc.TinyJob{}, fmt.Errorf("invalid upsert mode: %s", mode)
+	}
+
+	var timeout int32
+	if args.Timeout != nil {
+		timeout = int32(*args.Timeout)
+	}
+
+	startAt := time.Now()
+	if args.StartAt != nil {
+		startAt = *args.StartAt
+	}
+
+	meta := []byte("{}")
+	if args.Meta != nil {
+		meta = []byte(*args.Meta)
+	}
+	meta = tracing.Inject(ctx, meta)
+
+	var retries int32
+	if args.Retries != nil {
+		retries = int32(*args.Retries)
+	}
+
+	params := sqlc.CreateOrUpdateJobParams{
+		Expr:     args.Expr,
+		Name:     args.Name,
+		State:    args.State,
+		Executor: executor,
+		Timeout:  timeout,
+		StartAt:  pgtype.Timestamptz{Time: startAt, Valid: true},
+		Meta:     meta,
+		Owner:    sqlc.FromCtx(ctx),
+		Retries:  retries,
+		Mode:     mode.String(),
+	}
+	if args.DeduplicationKey != nil {
+		var hash pgtype.Text
+		hash.Scan(*args.DeduplicationKey)
+		params.DeduplicationKey = hash
+	}
+	if args.DeduplicationWindow != nil {
+		params.DeduplicationWindow = pgtype.Int4{Int32: int32(*args.DeduplicationWindow), Valid: true}
+	}
+	if args.ConcurrencyKey != nil {
+		params.ConcurrencyKey = pgtype.Text{String: *args.ConcurrencyKey, Valid: true}
+	}
+	if args.ConcurrencyLimit != nil {
+		params.ConcurrencyLimit = int32(*args.ConcurrencyLimit)
+	}
+
+	job, err := r.Queries.CreateOrUpdateJob(ctx, params)
+	if errors.Is(err, pgx.ErrNoRows) && params.DeduplicationKey.Valid {
+		// Nothing was inserted either because the key is still
+		// held within the window, or because of the name
+		_, dupErr := r.Queries.GetDuplicatedJob(ctx, sqlc.GetDuplicatedJobParams{
+			DeduplicationKey:    params.DeduplicationKey.String,
+			Owner:               params.Owner,
+			Executor:            executor,
+			DeduplicationWindow: params.DeduplicationWindow,
+		})
+		if dupErr == nil {
+			return job, fmt.Errorf("%w: %s", ErrDuplicated, params.DeduplicationKey.String)
+		}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return job, fmt.Errorf("job name %s is already used by another executor", args.Name)
+	}
+	if err != nil {
+		return job, constraintError(ctx, "args", err)
+	}
+	return job, nil
 }
 
 // BatchCreateJobs is the resolver for the batchCreateJobs field.
-func (r *mutationResolver) BatchCreateJobs(ctx context.Context, executor string, args []model.CreateJobArgs) ([]int64, error) {
+func (r *mutationResolver) BatchCreateJobs(ctx context.Context, executor string, args []model.CreateJobArgs, mode model.BatchMode) ([]model.BatchCreateJobResult, error) {
+	if err := r.authorize(ctx, executor, auth.OperationCreate); err != nil {
+		return nil, err
+	}
 	tx, err := r.DB.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return nil, err
 	}
 
-	queries := r.Queries.WithTx(tx)
-
 	var batch []sqlc.BatchCreateJobsParams
-	var batchErr error
-	var ids []int64
+	for i, arg := range args {
+		if err := validateCreateJobArgs(ctx, fmt.Sprintf("args.%d", i), arg); err != nil {
+			tx.Rollback(ctx)
+			return nil, err
+		}
 
-	for _, arg := range args {
 		var timeout int32
 		if arg.Timeout != nil {
 			timeout = int32(*arg.Timeout)
@@ -91,6 +222,7 @@ func (r *mutationResolver) BatchCreateJobs(ctx context.Context, executor string,
 		if arg.Meta != nil {
 			meta = []byte(*arg.Meta)
 		}
+		meta = tracing.Inject(ctx, meta)
 		var retries int32
 		if arg.Retries != nil {
 			retries = int32(*arg.Retries)
@@ -112,27 +244,112 @@ func (r *mutationResolver) BatchCreateJobs(ctx context.Context, executor string,
 			hash.Scan(*arg.DeduplicationKey)
 			params.DeduplicationKey = hash
 		}
+		if arg.DeduplicationWindow != nil {
+			params.DeduplicationWindow = pgtype.Int4{Int32: int32(*arg.DeduplicationWindow), Valid: true}
+		}
+		if arg.ConcurrencyKey != nil {
+			params.ConcurrencyKey = pgtype.Text{String: *arg.ConcurrencyKey, Valid: true}
+		}
+		if arg.ConcurrencyLimit != nil {
+			params.ConcurrencyLimit = int32(*arg.ConcurrencyLimit)
+		}
 
 		batch = append(batch, params)
 	}
 
-	queries.BatchCreateJobs(ctx, batch).Exec(func(i int, err error) {
+	results := make([]model.BatchCreateJobResult, len(batch))
+	duplicated := map[int]bool{}
+
+	// Every attempt runs in a savepoint. In best effort mode a failing
+	// job is discarded and the remaining jobs are attempted again
+	pending := make([]int, len(batch))
+	for i := range batch {
+		pending[i] = i
+	}
+	for len(pending) > 0 {
+		savepoint, err := tx.Begin(ctx)
 		if err != nil {
-			batchErr = err
+			tx.Rollback(ctx)
+			return nil, err
 		}
-		ids = append(ids, int64(i))
-	})
 
-	if batchErr != nil {
-		tx.Rollback(ctx)
-		return nil, batchErr
+		var attempt []sqlc.BatchCreateJobsParams
+		for _, index := range pending {
+			attempt = append(attempt, batch[index])
+		}
+
+		failed := -1
+		r.Queries.WithTx(savepoint).BatchCreateJobs(ctx, attempt).QueryRow(func(i int, job sqlc.TinyJob, err error) {
+			index := pending[i]
+			switch {
+			case failed >= 0:
+				// Following jobs are aborted together with the failed one
+			case errors.Is(err, pgx.ErrNoRows):
+				duplicated[index] = true
+			case err != nil:
+				failed = i
+				message := constraintError(ctx, fmt.Sprintf("args.%d", index), err).Error()
+				results[index] = model.BatchCreateJobResult{Error: &message}
+			default:
+				results[index] = model.BatchCreateJobResult{Job: &job}
+			}
+		})
+
+		if failed < 0 {
+			if err := savepoint.Commit(ctx); err != nil {
+				tx.Rollback(ctx)
+				return nil, err
+			}
+			break
+		}
+
+		savepoint.Rollback(ctx)
+		for _, index := range pending {
+			if index == pending[failed] {
+				continue
+			}
+			results[index] = model.BatchCreateJobResult{}
+			delete(duplicated, index)
+		}
+
+		if mode == model.BatchModeAtomic {
+			tx.Rollback(ctx)
+			for i := range results {
+				if results[i].Error == nil {
+					message := "not created: another job in the batch failed"
+					results[i] = model.BatchCreateJobResult{Error: &message}
+				}
+			}
+			return results, nil
+		}
+
+		pending = append(pending[:failed], pending[failed+1:]...)
 	}
 
-	return ids, tx.Commit(ctx)
+	queries := r.Queries.WithTx(tx)
+	for index := range duplicated {
+		existing, err := queries.GetDuplicatedJob(ctx, sqlc.GetDuplicatedJobParams{
+			DeduplicationKey:    batch[index].DeduplicationKey.String,
+			Owner:               batch[index].Owner,
+			Executor:            executor,
+			DeduplicationWindow: batch[index].DeduplicationWindow,
+		})
+		if err != nil {
+			message := err.Error()
+			results[index] = model.BatchCreateJobResult{Error: &message}
+			continue
+		}
+		results[index] = model.BatchCreateJobResult{DuplicateOf: &existing.ID}
+	}
+
+	return results, tx.Commit(ctx)
 }
 
 // UpdateJobByName is the resolver for the updateJobByName field.
 func (r *mutationResolver) UpdateJobByName(ctx context.Context, executor string, name string, args model.UpdateJobArgs) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+		return sqlc.TinyJob{}, err
+	}
 	params := sqlc.UpdateJobByNameParams{
 		Name:     name,
 		Executor: executor,
@@ -146,11 +363,18 @@ func (r *mutationResolver) UpdateJobByName(ctx context.Context, executor string,
 	if args.Timeout != nil {
 		params.Timeout = args.Timeout
 	}
-	return r.Queries.UpdateJobByName(ctx, params)
+	job, err := r.Queries.UpdateJobByName(ctx, params)
+	if err != nil {
+		return job, constraintError(ctx, "args", err)
+	}
+	return job, nil
 }
 
 // UpdateJobByID is the resolver for the updateJobById field.
 func (r *mutationResolver) UpdateJobByID(ctx context.Context, executor string, id int64, args model.UpdateJobArgs) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+		return sqlc.TinyJob{}, err
+	}
 	params := sqlc.UpdateJobByIDParams{
 		ID:       id,
 		Executor: executor,
@@ -164,11 +388,18 @@ func (r *mutationResolver) UpdateJobByID(ctx context.Context, executor string, i
 	if args.Timeout != nil {
 		params.Timeout = args.Timeout
 	}
-	return r.Queries.UpdateJobByID(ctx, params)
+	job, err := r.Queries.UpdateJobByID(ctx, params)
+	if err != nil {
+		return job, constraintError(ctx, "args", err)
+	}
+	return job, nil
 }
 
 // UpdateStateByID is the resolver for the updateStateByID field.
 func (r *mutationResolver) UpdateStateByID(ctx context.Context, executor string, id int64, state string) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+		return sqlc.TinyJob{}, err
+	}
 	return r.Queries.UpdateStateByID(ctx, sqlc.UpdateStateByIDParams{
 		ID:       id,
 		Executor: executor,
@@ -178,6 +409,9 @@ func (r *mutationResolver) UpdateStateByID(ctx context.Context, executor string,
 
 // UpdateExprByID is the resolver for the updateExprByID field.
 func (r *mutationResolver) UpdateExprByID(ctx context.Context, executor string, id int64, expr string) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+		return sqlc.TinyJob{}, err
+	}
 	return r.Queries.UpdateExprByID(ctx, sqlc.UpdateExprByIDParams{
 		ID:       id,
 		Executor: executor,
@@ -187,6 +421,9 @@ func (r *mutationResolver) UpdateExprByID(ctx context.Context, executor string,
 
 // DeleteJobByName is the resolver for the deleteJobByName field.
 func (r *mutationResolver) DeleteJobByName(ctx context.Context, executor string, name string) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationDelete); err != nil {
+		return sqlc.TinyJob{}, err
+	}
 	return r.Queries.DeleteJobByName(ctx, sqlc.DeleteJobByNameParams{
 		Name:     name,
 		Executor: executor,
@@ -195,6 +432,9 @@ func (r *mutationResolver) DeleteJobByName(ctx context.Context, executor string,
 
 // DeleteJobByID is the resolver for the deleteJobByID field.
 func (r *mutationResolver) DeleteJobByID(ctx context.Context, executor string, id int64) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationDelete); err != nil {
+		return sqlc.TinyJob{}, err
+	}
 	return r.Queries.DeleteJobByID(ctx, sqlc.DeleteJobByIDParams{
 		ID:       id,
 		Executor: executor,
@@ -203,6 +443,9 @@ func (r *mutationResolver) DeleteJobByID(ctx context.Context, executor string, i
 
 // StopJob is the resolver for the stopJob field.
 func (r *mutationResolver) StopJob(ctx context.Context, executor string, id int64) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+		return sqlc.TinyJob{}, err
+	}
 	return r.Queries.StopJob(ctx, sqlc.StopJobParams{
 		ID:       id,
 		Executor: executor,
@@ -211,38 +454,150 @@ func (r *mutationResolver) StopJob(ctx context.Context, executor string, id int6
 
 // RestartJob is the resolver for the restartJob field.
 func (r *mutationResolver) RestartJob(ctx context.Context, executor string, id int64) (sqlc.TinyJob, error) {
-	return r.Queries.RestartJob(ctx, sqlc.RestartJobParams{
+	if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+		return sqlc.TinyJob{}, err
+	}
+	job, err := r.Queries.RestartJob(ctx, sqlc.RestartJobParams{
+		ID:       id,
+		Executor: executor,
+	})
+	// A job holding the same key was created
+	// while this one was cancelled
+	return job, duplicatedError(err)
+}
+
+// CancelJob is the resolver for the cancelJob field.
+func (r *mutationResolver) CancelJob(ctx context.Context, executor string, id int64) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+		return sqlc.TinyJob{}, err
+	}
+	return r.Queries.CancelJob(ctx, sqlc.CancelJobParams{
+		ID:       id,
+		Executor: executor,
+	})
+}
+
+// RequeueJob is the resolver for the requeueJob field.
+func (r *mutationResolver) RequeueJob(ctx context.Context, executor string, id int64) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+		return sqlc.TinyJob{}, err
+	}
+	job, err := r.Queries.RequeueJob(ctx, sqlc.RequeueJobParams{
 		ID:       id,
 		Executor: executor,
 	})
+	// A job holding the same key was created
+	// while this one was quarantined
+	return job, duplicatedError(err)
 }
 
 // FetchForProcessing is the resolver for the fetchForProcessing field.
-func (r *mutationResolver) FetchForProcessing(ctx context.Context, executor string, limit int) ([]sqlc.TinyJob, error) {
+func (r *mutationResolver) FetchForProcessing(ctx context.Context, executor string, limit int, worker *string, mode model.FetchMode) ([]sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationProcess); err != nil {
+		return nil, err
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "qron.fetch", trace.WithAttributes(
+		attribute.String("qron.executor", executor),
+		attribute.Int("qron.limit", limit),
+	))
+	defer span.End()
+
 	tx, err := r.DB.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return nil, err
 	}
 
+	var holder pgtype.Text
+	if worker != nil {
+		holder = pgtype.Text{String: *worker, Valid: true}
+	}
+
 	q := r.Queries.WithTx(tx)
-	jobs, err := q.FetchDueJobs(ctx, sqlc.FetchDueJobsParams{
-		Limit:    int32(limit),
-		Executor: executor,
-	})
+
+	// Buckets stay locked until commit, so concurrent fetches
+	// of the same executor cannot spend the same tokens
+	limits, err := q.RefillRateLimits(ctx, executor)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	for _, l := range limits {
+		if l.Owner == "" && int(l.Tokens) < limit {
+			limit = int(l.Tokens)
+		}
+	}
+	if limit <= 0 {
+		return nil, tx.Commit(ctx)
+	}
+
+	// Concurrency keys stay locked until commit, so concurrent
+	// fetches cannot exceed the limit of the same key
+	locked, err := q.LockConcurrencyKeys(ctx, executor)
 	if err != nil {
 		tx.Rollback(ctx)
 		return nil, err
 	}
 
-	if tx.Commit(ctx) != nil {
+	var jobs []sqlc.TinyJob
+	switch mode {
+	case model.FetchModeFair:
+		jobs, err = q.FetchDueJobsFair(ctx, sqlc.FetchDueJobsFairParams{
+			Limit:    int32(limit),
+			Executor: executor,
+			Locked:   locked,
+			Worker:   holder,
+		})
+	default:
+		jobs, err = q.FetchDueJobs(ctx, sqlc.FetchDueJobsParams{
+			Limit:    int32(limit),
+			Executor: executor,
+			Locked:   locked,
+			Worker:   holder,
+		})
+	}
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+
+	fetched := map[string]int{"": len(jobs)}
+	for _, job := range jobs {
+		fetched[job.Owner] += 1
+	}
+	for _, l := range limits {
+		if fetched[l.Owner] == 0 {
+			continue
+		}
+		err = q.ConsumeRateLimit(ctx, sqlc.ConsumeRateLimitParams{
+			Amount:   int32(fetched[l.Owner]),
+			Executor: executor,
+			Owner:    l.Owner,
+		})
+		if err != nil {
+			tx.Rollback(ctx)
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
 		return nil, err
 	}
 
+	span.SetAttributes(attribute.Int("qron.fetched", len(jobs)))
 	return jobs, nil
 }
 
 // CommitJobs is the resolver for the commitJobs field.
 func (r *mutationResolver) CommitJobs(ctx context.Context, executor string, commits []model.CommitArgs) ([]int64, error) {
+	if err := r.authorize(ctx, executor, auth.OperationProcess); err != nil {
+		return nil, err
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "qron.commit", trace.WithAttributes(
+		attribute.String("qron.executor", executor),
+		attribute.Int("qron.commits", len(commits)),
+	))
+	defer span.End()
+
 	var batch []sqlc.BatchUpdateJobsParams
 	for _, commit := range commits {
 		var state string
@@ -261,22 +616,41 @@ func (r *mutationResolver) CommitJobs(ctx context.Context, executor string, comm
 			Expr:     expr,
 			Status:   sqlc.TinyStatusSUCCESS,
 			Executor: executor,
+			Lease:    int32(commit.Lease),
 		})
 	}
 
-	// TODO: this does not ensure a job exists
+	// Jobs not existing anymore or holding a different
+	// lease are not updated and reported as failed.
+	// Database errors are returned along with them
 	var failed []int64
-	r.Queries.BatchUpdateJobs(context.Background(), batch).Exec(func(i int, err error) {
-		if err != nil {
-			failed = append(failed, batch[i].ID)
+	var batchErr error
+	r.Queries.BatchUpdateJobs(ctx, batch).QueryRow(func(i int, _ int64, err error) {
+		if err == nil {
+			return
+		}
+		failed = append(failed, batch[i].ID)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			r.logger().Error("error while committing jobs", "executor", executor, "job_id", batch[i].ID, "error", err)
+			batchErr = err
 		}
 	})
 
-	return failed, nil
+	span.SetAttributes(attribute.Int("qron.rejected", len(failed)))
+	return failed, batchErr
 }
 
 // FailJobs is the resolver for the failJobs field.
 func (r *mutationResolver) FailJobs(ctx context.Context, executor string, commits []model.CommitArgs) ([]int64, error) {
+	if err := r.authorize(ctx, executor, auth.OperationProcess); err != nil {
+		return nil, err
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "qron.fail", trace.WithAttributes(
+		attribute.String("qron.executor", executor),
+		attribute.Int("qron.commits", len(commits)),
+	))
+	defer span.End()
+
 	var batch []sqlc.BatchUpdateFailedJobsParams
 	for _, commit := range commits {
 		var state string
@@ -294,23 +668,41 @@ func (r *mutationResolver) FailJobs(ctx context.Context, executor string, commit
 			State:    state,
 			Expr:     expr,
 			Executor: executor,
+			Lease:    int32(commit.Lease),
 		})
 	}
 
-	// TODO: this does not ensure a job exists
+	// Jobs not existing anymore or holding a different
+	// lease are not updated and reported as failed.
+	// Database errors are returned along with them
 	var failed []int64
-	r.Queries.BatchUpdateFailedJobs(context.Background(), batch).Exec(func(i int, err error) {
-		if err != nil {
-			log.Println("error while updating failed jobs:", err)
-			failed = append(failed, batch[i].ID)
+	var batchErr error
+	r.Queries.BatchUpdateFailedJobs(ctx, batch).QueryRow(func(i int, _ int64, err error) {
+		if err == nil {
+			return
+		}
+		failed = append(failed, batch[i].ID)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			r.logger().Error("error while failing jobs", "executor", executor, "job_id", batch[i].ID, "error", err)
+			batchErr = err
 		}
 	})
 
-	return failed, nil
+	span.SetAttributes(attribute.Int("qron.rejected", len(failed)))
+	return failed, batchErr
 }
 
 // RetryJobs is the resolver for the retryJobs field.
 func (r *mutationResolver) RetryJobs(ctx context.Context, executor string, commits []model.CommitArgs) ([]int64, error) {
+	if err := r.authorize(ctx, executor, auth.OperationProcess); err != nil {
+		return nil, err
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "qron.retry", trace.WithAttributes(
+		attribute.String("qron.executor", executor),
+		attribute.Int("qron.commits", len(commits)),
+	))
+	defer span.End()
+
 	var batch []sqlc.BatchUpdateJobsParams
 	for _, commit := range commits {
 		var state string
@@ -329,22 +721,62 @@ func (r *mutationResolver) RetryJobs(ctx context.Context, executor string, commi
 			Expr:     expr,
 			Status:   sqlc.TinyStatusREADY,
 			Executor: executor,
+			Lease:    int32(commit.Lease),
 		})
 	}
 
-	// TODO: this does not ensure a job exists
+	// Jobs not existing anymore or holding a different
+	// lease are not updated and reported as failed.
+	// Database errors are returned along with them
 	var failed []int64
-	r.Queries.BatchUpdateJobs(ctx, batch).Exec(func(i int, err error) {
-		if err != nil {
-			failed = append(failed, batch[i].ID)
+	var batchErr error
+	r.Queries.BatchUpdateJobs(ctx, batch).QueryRow(func(i int, _ int64, err error) {
+		if err == nil {
+			return
+		}
+		failed = append(failed, batch[i].ID)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			r.logger().Error("error while retrying jobs", "executor", executor, "job_id", batch[i].ID, "error", err)
+			batchErr = err
 		}
 	})
 
-	return failed, nil
+	span.SetAttributes(attribute.Int("qron.rejected", len(failed)))
+	return failed, batchErr
+}
+
+// PauseJobs is the resolver for the pauseJobs field.
+func (r *mutationResolver) PauseJobs(ctx context.Context, executor string, filter model.JobsFilter) (int, error) {
+	if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+		return 0, err
+	}
+	affected, err := r.Queries.PauseJobsByFilter(ctx, filterParams(executor, filter))
+	return int(affected), err
+}
+
+// ResumeJobs is the resolver for the resumeJobs field.
+func (r *mutationResolver) ResumeJobs(ctx context.Context, executor string, filter model.JobsFilter) (int, error) {
+	if err := r.authorize(ctx, executor, auth.OperationUpdate); err != nil {
+		return 0, err
+	}
+	affected, err := r.Queries.ResumeJobsByFilter(ctx, sqlc.ResumeJobsByFilterParams(filterParams(executor, filter)))
+	return int(affected), err
+}
+
+// DeleteJobs is the resolver for the deleteJobs field.
+func (r *mutationResolver) DeleteJobs(ctx context.Context, executor string, filter model.JobsFilter) (int, error) {
+	if err := r.authorize(ctx, executor, auth.OperationDelete); err != nil {
+		return 0, err
+	}
+	affected, err := r.Queries.DeleteJobsByFilter(ctx, sqlc.DeleteJobsByFilterParams(filterParams(executor, filter)))
+	return int(affected), err
 }
 
 // SearchJobs is the resolver for the searchJobs field.
 func (r *queryResolver) SearchJobs(ctx context.Context, executor string, args model.QueryJobsArgs) ([]sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return nil, err
+	}
 	if args.Limit > 1000 {
 		return nil, errors.New("requesting too many jobs")
 	}
@@ -359,7 +791,10 @@ func (r *queryResolver) SearchJobs(ctx context.Context, executor string, args mo
 
 // SearchJobsByMeta is the resolver for the searchJobsByMeta field.
 func (r *queryResolver) SearchJobsByMeta(ctx context.Context, executor string, args model.QueryJobsMetaArgs) (model.SearchJobsByMetaResult, error) {
-	statuses := strings.Join(args.Statuses, ",")
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return model.SearchJobsByMetaResult{}, err
+	}
+	statuses := joinStatuses(args.Statuses)
 
 	var name string
 	if args.Name != nil {
@@ -421,6 +856,9 @@ func (r *queryResolver) SearchJobsByMeta(ctx context.Context, executor string, a
 
 // QueryJobByName is the resolver for the queryJobByName field.
 func (r *queryResolver) QueryJobByName(ctx context.Context, executor string, name string) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return sqlc.TinyJob{}, err
+	}
 	return r.Queries.GetJobByName(ctx, sqlc.GetJobByNameParams{
 		Name:     name,
 		Executor: executor,
@@ -429,6 +867,9 @@ func (r *queryResolver) QueryJobByName(ctx context.Context, executor string, nam
 
 // QueryJobByID is the resolver for the queryJobByID field.
 func (r *queryResolver) QueryJobByID(ctx context.Context, executor string, id int64) (sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return sqlc.TinyJob{}, err
+	}
 	return r.Queries.GetJobByID(ctx, sqlc.GetJobByIDParams{
 		ID:       id,
 		Executor: executor,
@@ -437,10 +878,70 @@ func (r *queryResolver) QueryJobByID(ctx context.Context, executor string, id in
 
 // LastUpdate is the resolver for the lastUpdate field.
 func (r *queryResolver) LastUpdate(ctx context.Context, executor string) (*time.Time, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return nil, err
+	}
 	t, err := r.Queries.LastUpdate(ctx, executor)
 	return &t.Time, err
 }
 
+// QuarantinedJobs is the resolver for the quarantinedJobs field.
+func (r *queryResolver) QuarantinedJobs(ctx context.Context, executor string, limit int, offset int) ([]sqlc.TinyJob, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return nil, err
+	}
+	return r.Queries.ListQuarantinedJobs(ctx, sqlc.ListQuarantinedJobsParams{
+		Executor: executor,
+		Limit:    int32(limit),
+		Offset:   int32(offset),
+	})
+}
+
+// Jobs is the resolver for the jobs field.
+func (r *queryResolver) Jobs(ctx context.Context, executor string, filter *model.JobsQuery, first int, after *string, sort model.JobSort, direction model.SortDirection) (model.JobConnection, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return model.JobConnection{}, err
+	}
+	if first > 1000 {
+		return model.JobConnection{}, errors.New("requesting too many jobs")
+	}
+
+	// One more job is fetched to know whether there is a next page
+	params := jobsParams(executor, filter, first+1)
+	if after != nil {
+		cursor, err := decodeCursor(*after)
+		if err != nil {
+			return model.JobConnection{}, err
+		}
+		params.AfterAt = pgtype.Timestamptz{Time: cursor.at, Valid: true}
+		params.AfterID = pgtype.Int8{Int64: cursor.id, Valid: true}
+	}
+
+	jobs, err := r.listJobs(ctx, params, sort, direction)
+	if err != nil {
+		return model.JobConnection{}, err
+	}
+
+	connection := model.JobConnection{
+		Edges: []model.JobEdge{},
+	}
+	if len(jobs) > first {
+		jobs = jobs[:first]
+		connection.PageInfo.HasNextPage = true
+	}
+	for _, job := range jobs {
+		connection.Edges = append(connection.Edges, model.JobEdge{
+			Cursor: encodeCursor(job, sort),
+			Node:   job,
+		})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+
+	return connection, nil
+}
+
 // RunAt is the resolver for the run_at field.
 func (r *tinyJobResolver) RunAt(ctx context.Context, obj *sqlc.TinyJob) (time.Time, error) {
 	return obj.RunAt.Time, nil
@@ -466,16 +967,59 @@ func (r *tinyJobResolver) UpdatedAt(ctx context.Context, obj *sqlc.TinyJob) (tim
 	return obj.UpdatedAt.Time, nil
 }
 
-// Status is the resolver for the status field.
-func (r *tinyJobResolver) Status(ctx context.Context, obj *sqlc.TinyJob) (string, error) {
-	return string(obj.Status), nil
-}
-
 // Meta is the resolver for the meta field.
 func (r *tinyJobResolver) Meta(ctx context.Context, obj *sqlc.TinyJob) (string, error) {
 	return string(obj.Meta), nil
 }
 
+// DeduplicationKey is the resolver for the deduplication_key field.
+func (r *tinyJobResolver) DeduplicationKey(ctx context.Context, obj *sqlc.TinyJob) (*string, error) {
+	if !obj.DeduplicationKey.Valid {
+		return nil, nil
+	}
+	return &obj.DeduplicationKey.String, nil
+}
+
+// ConcurrencyKey is the resolver for the concurrency_key field.
+func (r *tinyJobResolver) ConcurrencyKey(ctx context.Context, obj *sqlc.TinyJob) (*string, error) {
+	if !obj.ConcurrencyKey.Valid {
+		return nil, nil
+	}
+	return &obj.ConcurrencyKey.String, nil
+}
+
+// LockedBy is the resolver for the locked_by field.
+func (r *tinyJobResolver) LockedBy(ctx context.Context, obj *sqlc.TinyJob) (*string, error) {
+	if !obj.LockedBy.Valid {
+		return nil, nil
+	}
+	return &obj.LockedBy.String, nil
+}
+
+// LockedAt is the resolver for the locked_at field.
+func (r *tinyJobResolver) LockedAt(ctx context.Context, obj *sqlc.TinyJob) (*time.Time, error) {
+	if !obj.LockedAt.Valid {
+		return nil, nil
+	}
+	return &obj.LockedAt.Time, nil
+}
+
+// CancelRequestedAt is the resolver for the cancel_requested_at field.
+func (r *tinyJobResolver) CancelRequestedAt(ctx context.Context, obj *sqlc.TinyJob) (*time.Time, error) {
+	if !obj.CancelRequestedAt.Valid {
+		return nil, nil
+	}
+	return &obj.CancelRequestedAt.Time, nil
+}
+
+// QuarantinedAt is the resolver for the quarantined_at field.
+func (r *tinyJobResolver) QuarantinedAt(ctx context.Context, obj *sqlc.TinyJob) (*time.Time, error) {
+	if !obj.QuarantinedAt.Valid {
+		return nil, nil
+	}
+	return &obj.QuarantinedAt.Time, nil
+}
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
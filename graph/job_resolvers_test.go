@@ -2,6 +2,7 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -15,6 +16,7 @@ import (
 	"github.com/lucagez/qron/sqlc"
 	"github.com/lucagez/qron/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 func countJobs(db *pgxpool.Pool, name string) int {
@@ -29,6 +31,23 @@ func countJobs(db *pgxpool.Pool, name string) int {
 	return count
 }
 
+// holdJob marks a job as fetched regardless of its
+// schedule and returns the lease acknowledging it
+func holdJob(db *pgxpool.Pool, id int64) int {
+	rows, err := db.Query(context.Background(), `
+		update tiny.job
+		set status = 'PENDING', lease = lease + 1
+		where id = $1
+		returning lease
+	`, id)
+	if err != nil {
+		log.Fatalln("failed to hold job", err)
+	}
+	var lease int
+	pgxscan.ScanOne(&lease, rows)
+	return lease
+}
+
 func ptrstring(x string) *string {
 	return &x
 }
@@ -144,9 +163,13 @@ func TestJobResolvers(t *testing.T) {
 			})
 		}
 
-		ids, err := resolver.Mutation().BatchCreateJobs(ctx, "batch-test", jobs)
+		results, err := resolver.Mutation().BatchCreateJobs(ctx, "batch-test", jobs, model.BatchModeAtomic)
 		assert.Nil(t, err)
-		assert.Len(t, ids, 10)
+		assert.Len(t, results, 10)
+		for i, result := range results {
+			assert.Nil(t, result.Error)
+			assert.Equal(t, fmt.Sprintf("batch-%d", i), result.Job.Name)
+		}
 
 		createdJobs, err := resolver.Query().SearchJobs(ctx, "batch-test", model.QueryJobsArgs{
 			Limit:  100,
@@ -167,6 +190,53 @@ func TestJobResolvers(t *testing.T) {
 		}
 	})
 
+	t.Run("Should report duplicates in a batch of jobs", func(t *testing.T) {
+		deduplicationKey := "batch-duplicated"
+		results, err := resolver.Mutation().BatchCreateJobs(ctx, "batch-duplicates", []model.CreateJobArgs{
+			{Expr: "@weekly", Name: "batch-dup-0", DeduplicationKey: &deduplicationKey},
+			{Expr: "@weekly", Name: "batch-dup-1", DeduplicationKey: &deduplicationKey},
+		}, model.BatchModeAtomic)
+		assert.Nil(t, err)
+		assert.Len(t, results, 2)
+		assert.NotNil(t, results[0].Job)
+		assert.Nil(t, results[1].Job)
+		assert.Equal(t, results[0].Job.ID, *results[1].DuplicateOf)
+	})
+
+	t.Run("Should not create any job in atomic batch with invalid jobs", func(t *testing.T) {
+		results, err := resolver.Mutation().BatchCreateJobs(ctx, "batch-atomic", []model.CreateJobArgs{
+			{Expr: "@weekly", Name: "batch-atomic-0"},
+			{Expr: "@lmao", Name: "batch-atomic-1"},
+			{Expr: "@weekly", Name: "batch-atomic-2"},
+		}, model.BatchModeAtomic)
+		assert.Nil(t, err)
+		assert.Len(t, results, 3)
+		for _, result := range results {
+			assert.Nil(t, result.Job)
+			assert.NotNil(t, result.Error)
+		}
+		assert.Equal(t, 0, countJobs(pool, "batch-atomic-0"))
+		assert.Equal(t, 0, countJobs(pool, "batch-atomic-2"))
+	})
+
+	t.Run("Should create valid jobs in best effort batch", func(t *testing.T) {
+		results, err := resolver.Mutation().BatchCreateJobs(ctx, "batch-best-effort", []model.CreateJobArgs{
+			{Expr: "@weekly", Name: "batch-best-effort-0"},
+			{Expr: "@lmao", Name: "batch-best-effort-1"},
+			{Expr: "@weekly", Name: "batch-best-effort-2"},
+			{Expr: "@every lmao", Name: "batch-best-effort-3"},
+		}, model.BatchModeBestEffort)
+		assert.Nil(t, err)
+		assert.Len(t, results, 4)
+		assert.NotNil(t, results[0].Job)
+		assert.NotNil(t, results[1].Error)
+		assert.NotNil(t, results[2].Job)
+		assert.NotNil(t, results[3].Error)
+		assert.Equal(t, 1, countJobs(pool, "batch-best-effort-0"))
+		assert.Equal(t, 0, countJobs(pool, "batch-best-effort-1"))
+		assert.Equal(t, 1, countJobs(pool, "batch-best-effort-2"))
+	})
+
 	t.Run("Should conditionally update job config by name", func(t *testing.T) {
 		job, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
 			Expr:  "@weekly",
@@ -322,7 +392,7 @@ func TestJobResolvers(t *testing.T) {
 
 			for i := 0; i < 5; i++ {
 				_, err := resolver.Mutation().FailJobs(context.Background(), executor, []model.CommitArgs{
-					{ID: job.ID},
+					{ID: job.ID, Lease: holdJob(pool, job.ID)},
 				})
 				assert.Nil(t, err)
 
@@ -354,7 +424,7 @@ func TestJobResolvers(t *testing.T) {
 		var lastDelay time.Duration
 		for i := 0; i < 20; i++ {
 			_, err := resolver.Mutation().FailJobs(context.Background(), executor, []model.CommitArgs{
-				{ID: job.ID},
+				{ID: job.ID, Lease: holdJob(pool, job.ID)},
 			})
 			assert.Nil(t, err)
 
@@ -379,7 +449,7 @@ func TestJobResolvers(t *testing.T) {
 
 		for i := 0; i < 20; i++ {
 			_, err := resolver.Mutation().FailJobs(context.Background(), executor, []model.CommitArgs{
-				{ID: job.ID},
+				{ID: job.ID, Lease: holdJob(pool, job.ID)},
 			})
 			assert.Nil(t, err)
 
@@ -427,7 +497,7 @@ func TestJobResolvers(t *testing.T) {
 			// A lot more executions that retries
 			for i := 0; i < 10; i++ {
 				_, err := resolver.Mutation().FailJobs(context.Background(), executor, []model.CommitArgs{
-					{ID: job.ID},
+					{ID: job.ID, Lease: holdJob(pool, job.ID)},
 				})
 				assert.Nil(t, err)
 
@@ -483,7 +553,7 @@ func TestProcessing(t *testing.T) {
 		assert.Equal(t, 0, pending)
 		assert.Equal(t, 50, ready)
 
-		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 20)
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 20, nil, model.FetchModeFifo)
 		assert.Nil(t, err)
 		assert.Len(t, fetch, 20)
 
@@ -543,7 +613,7 @@ func TestConcurrentProcessing(t *testing.T) {
 			go func() {
 				defer wg.Done()
 
-				fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5)
+				fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
 				assert.Nil(t, err)
 				assert.Len(t, fetch, 5)
 
@@ -599,14 +669,15 @@ func TestCommit(t *testing.T) {
 
 		time.Sleep(1 * time.Second)
 
-		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5)
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
 		assert.Nil(t, err)
 		assert.Len(t, fetch, 5)
 
 		var commits []model.CommitArgs
 		for _, job := range fetch {
 			commits = append(commits, model.CommitArgs{
-				ID: job.ID,
+				ID:    job.ID,
+				Lease: int(job.Lease),
 			})
 		}
 
@@ -665,14 +736,15 @@ func TestFailure(t *testing.T) {
 
 		time.Sleep(1 * time.Second)
 
-		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5)
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
 		assert.Nil(t, err)
 		assert.Len(t, fetch, 5)
 
 		var commits []model.CommitArgs
 		for _, job := range fetch {
 			commits = append(commits, model.CommitArgs{
-				ID: job.ID,
+				ID:    job.ID,
+				Lease: int(job.Lease),
 			})
 		}
 
@@ -734,14 +806,15 @@ func TestRetry(t *testing.T) {
 
 		time.Sleep(1 * time.Second)
 
-		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5)
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
 		assert.Nil(t, err)
 		assert.Len(t, fetch, 5)
 
 		var commits []model.CommitArgs
 		for _, job := range fetch {
 			commits = append(commits, model.CommitArgs{
-				ID: job.ID,
+				ID:    job.ID,
+				Lease: int(job.Lease),
 			})
 		}
 
@@ -791,23 +864,1131 @@ func TestDuplicate(t *testing.T) {
 	ctx := context.Background()
 	executor := "test-executor"
 
-	t.Run("Should retry commit after processing", func(t *testing.T) {
-		var errs []error
+	t.Run("Should return existing job when duplicated", func(t *testing.T) {
+		var jobs []sqlc.TinyJob
 		for i := 0; i < 50; i++ {
 			deduplicationKey := "duplicated"
-			_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			job, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
 				Expr:             "@after 1 second",
 				Name:             fmt.Sprintf("search-%d", i),
 				State:            "{}",
 				DeduplicationKey: &deduplicationKey,
 			})
-			errs = append(errs, err)
+			assert.Nil(t, err)
+			jobs = append(jobs, job)
 		}
 
-		assert.Len(t, errs, 50)
-		assert.Nil(t, errs[0])
+		assert.Len(t, jobs, 50)
+		assert.Equal(t, 1, countJobs(pool, "search-0"))
 		for i := 1; i < 50; i++ {
-			assert.Contains(t, errs[i].Error(), "duplicate key value violates unique constraint \"job_deduplication_key_constraint\"")
+			assert.Equal(t, jobs[0].ID, jobs[i].ID)
+			assert.Equal(t, 0, countJobs(pool, fmt.Sprintf("search-%d", i)))
+		}
+	})
+
+	t.Run("Should scope deduplication key by owner and executor", func(t *testing.T) {
+		deduplicationKey := "scoped"
+		a, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@after 1 hour",
+			State:            "{}",
+			DeduplicationKey: &deduplicationKey,
+		})
+		assert.Nil(t, err)
+
+		b, err := resolver.Mutation().CreateJob(ctx, "other-executor", model.CreateJobArgs{
+			Expr:             "@after 1 hour",
+			State:            "{}",
+			DeduplicationKey: &deduplicationKey,
+		})
+		assert.Nil(t, err)
+
+		c, err := resolver.Mutation().CreateJob(sqlc.NewCtx(ctx, "bobby"), executor, model.CreateJobArgs{
+			Expr:             "@after 1 hour",
+			State:            "{}",
+			DeduplicationKey: &deduplicationKey,
+		})
+		assert.Nil(t, err)
+
+		assert.NotEqual(t, a.ID, b.ID)
+		assert.NotEqual(t, a.ID, c.ID)
+		assert.NotEqual(t, b.ID, c.ID)
+	})
+
+	t.Run("Should reuse deduplication key after job finished", func(t *testing.T) {
+		deduplicationKey := "reused"
+		a, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@after 1 hour",
+			State:            "{}",
+			DeduplicationKey: &deduplicationKey,
+		})
+		assert.Nil(t, err)
+
+		_, err = pool.Exec(ctx, "update tiny.job set status = 'SUCCESS' where id = $1", a.ID)
+		assert.Nil(t, err)
+
+		b, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@after 1 hour",
+			State:            "{}",
+			DeduplicationKey: &deduplicationKey,
+		})
+		assert.Nil(t, err)
+		assert.NotEqual(t, a.ID, b.ID)
+	})
+
+	t.Run("Should deduplicate finished jobs within window", func(t *testing.T) {
+		deduplicationKey := "windowed"
+		window := 1
+		a, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:                "@after 1 hour",
+			State:               "{}",
+			DeduplicationKey:    &deduplicationKey,
+			DeduplicationWindow: &window,
+		})
+		assert.Nil(t, err)
+
+		_, err = pool.Exec(ctx, "update tiny.job set status = 'SUCCESS', updated_at = now() where id = $1", a.ID)
+		assert.Nil(t, err)
+
+		b, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:                "@after 1 hour",
+			State:               "{}",
+			DeduplicationKey:    &deduplicationKey,
+			DeduplicationWindow: &window,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, a.ID, b.ID)
+
+		_, err = pool.Exec(ctx, "update tiny.job set updated_at = now() - interval '2 minutes' where id = $1", a.ID)
+		assert.Nil(t, err)
+
+		c, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:                "@after 1 hour",
+			State:               "{}",
+			DeduplicationKey:    &deduplicationKey,
+			DeduplicationWindow: &window,
+		})
+		assert.Nil(t, err)
+		assert.NotEqual(t, a.ID, c.ID)
+	})
+
+	t.Run("Should report duplicated keys on upsert", func(t *testing.T) {
+		deduplicationKey := "upserted"
+		window := 60
+		a, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@after 1 hour",
+			Name:             "upsert-dup-a",
+			State:            "{}",
+			DeduplicationKey: &deduplicationKey,
+		}, model.UpsertModeReplace)
+		assert.Nil(t, err)
+
+		// Upserting the job holding the key is not a duplicate
+		same, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:                "@after 1 hour",
+			Name:                "upsert-dup-a",
+			State:               "{}",
+			DeduplicationKey:    &deduplicationKey,
+			DeduplicationWindow: &window,
+		}, model.UpsertModeReplace)
+		assert.Nil(t, err)
+		assert.Equal(t, a.ID, same.ID)
+
+		_, err = resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@after 1 hour",
+			Name:             "upsert-dup-b",
+			State:            "{}",
+			DeduplicationKey: &deduplicationKey,
+		}, model.UpsertModeReplace)
+		assert.ErrorIs(t, err, ErrDuplicated)
+
+		_, err = pool.Exec(ctx, "update tiny.job set status = 'SUCCESS', updated_at = now() where id = $1", a.ID)
+		assert.Nil(t, err)
+
+		_, err = resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:                "@after 1 hour",
+			Name:                "upsert-dup-c",
+			State:               "{}",
+			DeduplicationKey:    &deduplicationKey,
+			DeduplicationWindow: &window,
+		}, model.UpsertModeReplace)
+		assert.ErrorIs(t, err, ErrDuplicated)
+
+		c, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@after 1 hour",
+			Name:             "upsert-dup-c",
+			State:            "{}",
+			DeduplicationKey: &deduplicationKey,
+		}, model.UpsertModeReplace)
+		assert.Nil(t, err)
+		assert.NotEqual(t, a.ID, c.ID)
+	})
+}
+
+func TestUpsert(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("upsert")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+	executor := "test-executor"
+
+	t.Run("Should keep existing job on conflict", func(t *testing.T) {
+		job, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@weekly",
+			Name:  "upsert-keep",
+			State: `{"v":1}`,
+		}, model.UpsertModeKeepExisting)
+		assert.Nil(t, err)
+
+		upserted, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@daily",
+			Name:  "upsert-keep",
+			State: `{"v":2}`,
+		}, model.UpsertModeKeepExisting)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, countJobs(pool, "upsert-keep"))
+		assert.Equal(t, job.ID, upserted.ID)
+		assert.Equal(t, "@weekly", upserted.Expr)
+		assert.Equal(t, `{"v":1}`, upserted.State)
+		assert.Equal(t, job.RunAt.Time, upserted.RunAt.Time)
+	})
+
+	t.Run("Should replace expr, meta and state on conflict", func(t *testing.T) {
+		job, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@weekly",
+			Name:  "upsert-replace",
+			State: `{"v":1}`,
+			Meta:  ptrstring(`{"url":"a"}`),
+		}, model.UpsertModeReplace)
+		assert.Nil(t, err)
+
+		upserted, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@daily",
+			Name:  "upsert-replace",
+			State: `{"v":2}`,
+			Meta:  ptrstring(`{"url":"b"}`),
+		}, model.UpsertModeReplace)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, countJobs(pool, "upsert-replace"))
+		assert.Equal(t, job.ID, upserted.ID)
+		assert.Equal(t, "@daily", upserted.Expr)
+		assert.Equal(t, `{"v":2}`, upserted.State)
+		assert.Equal(t, `{"url":"b"}`, string(upserted.Meta))
+		assert.Less(t, time.Until(upserted.RunAt.Time), 25*time.Hour)
+	})
+
+	t.Run("Should replace only schedule on conflict", func(t *testing.T) {
+		job, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@weekly",
+			Name:  "upsert-schedule",
+			State: `{"v":1}`,
+		}, model.UpsertModeReplaceSchedule)
+		assert.Nil(t, err)
+
+		same, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@weekly",
+			Name:  "upsert-schedule",
+			State: `{"v":2}`,
+		}, model.UpsertModeReplaceSchedule)
+		assert.Nil(t, err)
+		assert.Equal(t, job.RunAt.Time, same.RunAt.Time)
+
+		upserted, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@daily",
+			Name:  "upsert-schedule",
+			State: `{"v":3}`,
+		}, model.UpsertModeReplaceSchedule)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, countJobs(pool, "upsert-schedule"))
+		assert.Equal(t, job.ID, upserted.ID)
+		assert.Equal(t, "@daily", upserted.Expr)
+		assert.Equal(t, `{"v":1}`, upserted.State)
+	})
+
+	t.Run("Should run finished jobs again on replace", func(t *testing.T) {
+		job, err := resolver.Mutation().CreateOrUpdateJob(ctx, "upsert-finished", model.CreateJobArgs{
+			Expr:  "@after 1 second",
+			Name:  "upsert-finished",
+			State: "{}",
+		}, model.UpsertModeReplace)
+		assert.Nil(t, err)
+		time.Sleep(1100 * time.Millisecond)
+
+		fetched, err := resolver.Mutation().FetchForProcessing(ctx, "upsert-finished", 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetched, 1)
+		failed, err := resolver.Mutation().CommitJobs(ctx, "upsert-finished", []model.CommitArgs{{
+			ID:    job.ID,
+			Lease: int(fetched[0].Lease),
+		}})
+		assert.Nil(t, err)
+		assert.Len(t, failed, 0)
+
+		timeout, retries := 30, 2
+		upserted, err := resolver.Mutation().CreateOrUpdateJob(ctx, "upsert-finished", model.CreateJobArgs{
+			Expr:    "@after 1 second",
+			Name:    "upsert-finished",
+			State:   "{}",
+			Timeout: &timeout,
+			Retries: &retries,
+		}, model.UpsertModeReplace)
+		assert.Nil(t, err)
+		assert.Equal(t, job.ID, upserted.ID)
+		assert.Equal(t, sqlc.TinyStatusREADY, upserted.Status)
+		assert.Equal(t, int32(30), upserted.Timeout)
+		assert.Equal(t, int32(2), upserted.Retries)
+		time.Sleep(1100 * time.Millisecond)
+
+		fetched, err = resolver.Mutation().FetchForProcessing(ctx, "upsert-finished", 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetched, 1)
+		assert.Equal(t, job.ID, fetched[0].ID)
+	})
+
+	t.Run("Should not take over jobs of another executor", func(t *testing.T) {
+		_, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@weekly",
+			Name:  "upsert-other-executor",
+			State: "{}",
+		}, model.UpsertModeReplace)
+		assert.Nil(t, err)
+
+		_, err = resolver.Mutation().CreateOrUpdateJob(ctx, "other-executor", model.CreateJobArgs{
+			Expr:  "@daily",
+			Name:  "upsert-other-executor",
+			State: "{}",
+		}, model.UpsertModeReplace)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestConcurrencyKey(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("concurrency_key")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+	executor := "test-executor"
+
+	t.Run("Should not fetch more than limit jobs sharing a key", func(t *testing.T) {
+		key := "customer-1"
+		limit := 2
+		for i := 0; i < 5; i++ {
+			_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+				Expr:             "@after 1 second",
+				Name:             fmt.Sprintf("sync-%d", i),
+				State:            "{}",
+				ConcurrencyKey:   &key,
+				ConcurrencyLimit: &limit,
+			})
+			assert.Nil(t, err)
+		}
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 second",
+			Name:  "unkeyed",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(1 * time.Second)
+
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 3)
+
+		// Limit is already reached while the fetched jobs are pending
+		fetch, err = resolver.Mutation().FetchForProcessing(ctx, executor, 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 0)
+	})
+
+	t.Run("Should fetch next job sharing a key after commit", func(t *testing.T) {
+		key := "customer-2"
+		for i := 0; i < 3; i++ {
+			_, err := resolver.Mutation().CreateJob(ctx, "other-executor", model.CreateJobArgs{
+				Expr:           "@after 1 second",
+				Name:           fmt.Sprintf("other-sync-%d", i),
+				State:          "{}",
+				ConcurrencyKey: &key,
+			})
+			assert.Nil(t, err)
 		}
+
+		time.Sleep(1 * time.Second)
+
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, "other-executor", 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 1)
+
+		fetch2, err := resolver.Mutation().FetchForProcessing(ctx, "other-executor", 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch2, 0)
+
+		_, err = resolver.Mutation().CommitJobs(ctx, "other-executor", []model.CommitArgs{{ID: fetch[0].ID, Lease: int(fetch[0].Lease)}})
+		assert.Nil(t, err)
+
+		fetch, err = resolver.Mutation().FetchForProcessing(ctx, "other-executor", 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 1)
+	})
+
+	t.Run("Should not exceed limit with concurrent fetchers", func(t *testing.T) {
+		key := "customer-3"
+		limit := 3
+		for i := 0; i < 10; i++ {
+			_, err := resolver.Mutation().CreateJob(ctx, "concurrent-executor", model.CreateJobArgs{
+				Expr:             "@after 1 second",
+				Name:             fmt.Sprintf("concurrent-sync-%d", i),
+				State:            "{}",
+				ConcurrencyKey:   &key,
+				ConcurrencyLimit: &limit,
+			})
+			assert.Nil(t, err)
+		}
+
+		time.Sleep(1 * time.Second)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		fetched := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(mode model.FetchMode) {
+				defer wg.Done()
+				fetch, err := resolver.Mutation().FetchForProcessing(ctx, "concurrent-executor", 1, nil, mode)
+				assert.Nil(t, err)
+				mu.Lock()
+				fetched += len(fetch)
+				mu.Unlock()
+			}([]model.FetchMode{model.FetchModeFifo, model.FetchModeFair}[i%2])
+		}
+		wg.Wait()
+
+		assert.Equal(t, limit, fetched)
+	})
+
+	t.Run("Should not count due jobs of other executors", func(t *testing.T) {
+		key := "customer-4"
+		_, err := resolver.Mutation().CreateJob(ctx, "idle-executor", model.CreateJobArgs{
+			Expr:           "@after 1 second",
+			Name:           "idle-sync",
+			State:          "{}",
+			ConcurrencyKey: &key,
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(10 * time.Millisecond)
+
+		_, err = resolver.Mutation().CreateJob(ctx, "busy-executor", model.CreateJobArgs{
+			Expr:           "@after 1 second",
+			Name:           "busy-sync",
+			State:          "{}",
+			ConcurrencyKey: &key,
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(1 * time.Second)
+
+		// The older job of the idle executor is never fetched
+		// by this executor, so it does not hold the key
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, "busy-executor", 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 1)
+	})
+
+	t.Run("Should keep concurrency key on upsert", func(t *testing.T) {
+		key := "customer-5"
+		limit := 2
+		job, err := resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@every 1 hour",
+			Name:             "upserted-sync",
+			State:            "{}",
+			ConcurrencyKey:   &key,
+			ConcurrencyLimit: &limit,
+		}, model.UpsertModeReplaceSchedule)
+		assert.Nil(t, err)
+		assert.Equal(t, key, job.ConcurrencyKey.String)
+		assert.Equal(t, int32(limit), job.ConcurrencyLimit)
+
+		limit = 4
+		job, err = resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@every 1 hour",
+			Name:             "upserted-sync",
+			State:            "{}",
+			ConcurrencyKey:   &key,
+			ConcurrencyLimit: &limit,
+		}, model.UpsertModeKeepExisting)
+		assert.Nil(t, err)
+		assert.Equal(t, int32(2), job.ConcurrencyLimit)
+
+		job, err = resolver.Mutation().CreateOrUpdateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@every 1 hour",
+			Name:             "upserted-sync",
+			State:            "{}",
+			ConcurrencyKey:   &key,
+			ConcurrencyLimit: &limit,
+		}, model.UpsertModeReplace)
+		assert.Nil(t, err)
+		assert.Equal(t, key, job.ConcurrencyKey.String)
+		assert.Equal(t, int32(limit), job.ConcurrencyLimit)
+	})
+}
+
+func TestLease(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("lease")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+	executor := "test-executor"
+
+	t.Run("Should reject commits with stale lease", func(t *testing.T) {
+		timeout := 1
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:    "@after 1 second",
+			Name:    "leased",
+			State:   "{}",
+			Timeout: &timeout,
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(1 * time.Second)
+
+		stale, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, stale, 1)
+		assert.Equal(t, int32(1), stale[0].Lease)
+
+		// Timed out job is taken back and handed to another worker
+		time.Sleep(2 * time.Second)
+		ids, err := queries.ResetTimeoutJobs(ctx, sqlc.ResetTimeoutJobsParams{Executor: executor})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{stale[0].ID}, ids)
+
+		fresh, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fresh, 1)
+		assert.Equal(t, int32(3), fresh[0].Lease)
+
+		staleLease := int(stale[0].Lease)
+		failed, err := resolver.Mutation().CommitJobs(ctx, executor, []model.CommitArgs{{
+			ID:    stale[0].ID,
+			Lease: staleLease,
+		}})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{stale[0].ID}, failed)
+
+		failed, err = resolver.Mutation().FailJobs(ctx, executor, []model.CommitArgs{{
+			ID:    stale[0].ID,
+			Lease: staleLease,
+		}})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{stale[0].ID}, failed)
+
+		freshLease := int(fresh[0].Lease)
+		failed, err = resolver.Mutation().CommitJobs(ctx, executor, []model.CommitArgs{{
+			ID:    fresh[0].ID,
+			Lease: freshLease,
+		}})
+		assert.Nil(t, err)
+		assert.Len(t, failed, 0)
+
+		job, err := resolver.Query().SearchJobs(ctx, executor, model.QueryJobsArgs{
+			Limit:  1,
+			Filter: "leased",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusSUCCESS, job[0].Status)
+
+		// The lease is still the same, but the job is not held anymore
+		failed, err = resolver.Mutation().FailJobs(ctx, executor, []model.CommitArgs{{
+			ID:    fresh[0].ID,
+			Lease: freshLease,
+		}})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{fresh[0].ID}, failed)
+	})
+
+	t.Run("Should reject commits of jobs never fetched", func(t *testing.T) {
+		job, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 hour",
+			Name:  "never-fetched",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		failed, err := resolver.Mutation().CommitJobs(ctx, executor, []model.CommitArgs{{
+			ID:    job.ID,
+			Lease: int(job.Lease),
+		}})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{job.ID}, failed)
+
+		job, err = resolver.Query().QueryJobByID(ctx, executor, job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusREADY, job.Status)
+	})
+
+	t.Run("Should report missing jobs as failed", func(t *testing.T) {
+		failed, err := resolver.Mutation().CommitJobs(ctx, executor, []model.CommitArgs{{ID: 999999}})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{999999}, failed)
+	})
+
+	t.Run("Should tell database errors apart from lost leases", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		commits := []model.CommitArgs{{ID: 999999}}
+		_, err := resolver.Mutation().CommitJobs(cancelled, executor, commits)
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = resolver.Mutation().FailJobs(cancelled, executor, commits)
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = resolver.Mutation().RetryJobs(cancelled, executor, commits)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestCancelJob(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("cancel_job")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+	executor := "test-executor"
+
+	t.Run("Should cancel ready jobs right away", func(t *testing.T) {
+		job, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@every 1 hour",
+			Name:  "ready",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		cancelled, err := resolver.Mutation().CancelJob(ctx, executor, job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusCANCELLED, cancelled.Status)
+		assert.True(t, cancelled.CancelRequestedAt.Valid)
+
+		_, err = resolver.Mutation().CancelJob(ctx, executor, job.ID)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should notify the worker of running jobs", func(t *testing.T) {
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 second",
+			Name:  "running",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(1 * time.Second)
+
+		jobs, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, ptrstring("worker-a"), model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, jobs, 1)
+
+		job, err := resolver.Mutation().CancelJob(ctx, executor, jobs[0].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusPENDING, job.Status)
+		assert.True(t, job.CancelRequestedAt.Valid)
+
+		ids, err := queries.ListCancelRequestedJobs(ctx, sqlc.ListCancelRequestedJobsParams{
+			Worker:   "worker-b",
+			Executor: executor,
+		})
+		assert.Nil(t, err)
+		assert.Len(t, ids, 0)
+
+		ids, err = queries.ListCancelRequestedJobs(ctx, sqlc.ListCancelRequestedJobsParams{
+			Worker:   "worker-a",
+			Executor: executor,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{job.ID}, ids)
+
+		// Handler gives up after noticing the cancellation
+		failed, err := resolver.Mutation().FailJobs(ctx, executor, []model.CommitArgs{{ID: job.ID, Lease: int(jobs[0].Lease)}})
+		assert.Nil(t, err)
+		assert.Len(t, failed, 0)
+
+		job, err = resolver.Query().QueryJobByID(ctx, executor, job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusCANCELLED, job.Status)
+	})
+
+	t.Run("Should cancel running jobs taken back on timeout", func(t *testing.T) {
+		timeout := 1
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:    "@after 1 second",
+			Name:    "timed-out",
+			State:   "{}",
+			Timeout: &timeout,
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(1 * time.Second)
+
+		jobs, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, jobs, 1)
+
+		_, err = resolver.Mutation().CancelJob(ctx, executor, jobs[0].ID)
+		assert.Nil(t, err)
+
+		time.Sleep(2 * time.Second)
+		ids, err := queries.ResetTimeoutJobs(ctx, sqlc.ResetTimeoutJobsParams{Executor: executor})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{jobs[0].ID}, ids)
+
+		job, err := resolver.Query().QueryJobByID(ctx, executor, jobs[0].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusCANCELLED, job.Status)
+	})
+
+	t.Run("Should restart cancelled jobs", func(t *testing.T) {
+		job, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@every 1 hour",
+			Name:  "restarted",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		_, err = resolver.Mutation().CancelJob(ctx, executor, job.ID)
+		assert.Nil(t, err)
+
+		job, err = resolver.Mutation().RestartJob(ctx, executor, job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusREADY, job.Status)
+		assert.False(t, job.CancelRequestedAt.Valid)
+
+		// Only cancelled or paused jobs can be restarted
+		_, err = resolver.Mutation().RestartJob(ctx, executor, job.ID)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should withdraw the cancel of running jobs", func(t *testing.T) {
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 second",
+			Name:  "withdrawn",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(1 * time.Second)
+
+		jobs, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, ptrstring("worker-c"), model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, jobs, 1)
+
+		_, err = resolver.Mutation().CancelJob(ctx, executor, jobs[0].ID)
+		assert.Nil(t, err)
+
+		job, err := resolver.Mutation().RestartJob(ctx, executor, jobs[0].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusPENDING, job.Status)
+		assert.False(t, job.CancelRequestedAt.Valid)
+
+		ids, err := queries.ListCancelRequestedJobs(ctx, sqlc.ListCancelRequestedJobsParams{
+			Worker:   "worker-c",
+			Executor: executor,
+		})
+		assert.Nil(t, err)
+		assert.Len(t, ids, 0)
+
+		failed, err := resolver.Mutation().CommitJobs(ctx, executor, []model.CommitArgs{{ID: job.ID, Lease: int(jobs[0].Lease)}})
+		assert.Nil(t, err)
+		assert.Len(t, failed, 0)
+
+		job, err = resolver.Query().QueryJobByID(ctx, executor, job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusSUCCESS, job.Status)
+	})
+}
+
+func TestQuarantine(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("quarantine")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+	executor := "test-executor"
+
+	t.Run("Should quarantine jobs reset too many times", func(t *testing.T) {
+		timeout := 1
+		job, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:    "@after 1 second",
+			Name:    "poison",
+			State:   "{}",
+			Timeout: &timeout,
+		})
+		assert.Nil(t, err)
+
+		for i := 0; i < 2; i++ {
+			time.Sleep(1 * time.Second)
+			jobs, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
+			assert.Nil(t, err)
+			assert.Len(t, jobs, 1)
+
+			time.Sleep(2 * time.Second)
+			ids, err := queries.ResetTimeoutJobs(ctx, sqlc.ResetTimeoutJobsParams{
+				Executor:  executor,
+				MaxResets: 2,
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, []int64{job.ID}, ids)
+		}
+
+		job, err = resolver.Query().QueryJobByID(ctx, executor, job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusFAILURE, job.Status)
+		assert.True(t, job.QuarantinedAt.Valid)
+
+		quarantined, err := resolver.Query().QuarantinedJobs(ctx, executor, 10, 0)
+		assert.Nil(t, err)
+		assert.Len(t, quarantined, 1)
+		assert.Equal(t, job.ID, quarantined[0].ID)
+
+		jobs, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, jobs, 0)
+	})
+
+	t.Run("Should requeue quarantined jobs", func(t *testing.T) {
+		quarantined, err := resolver.Query().QuarantinedJobs(ctx, executor, 10, 0)
+		assert.Nil(t, err)
+		assert.Len(t, quarantined, 1)
+
+		job, err := resolver.Mutation().RequeueJob(ctx, executor, quarantined[0].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusREADY, job.Status)
+		assert.Equal(t, int32(0), job.ResetCount)
+		assert.False(t, job.QuarantinedAt.Valid)
+
+		_, err = resolver.Mutation().RequeueJob(ctx, executor, job.ID)
+		assert.NotNil(t, err)
+
+		jobs, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, jobs, 1)
+	})
+
+	t.Run("Should report duplicated keys on requeue", func(t *testing.T) {
+		key := "quarantined-key"
+		job, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@after 1 hour",
+			Name:             "quarantined-duplicate",
+			State:            "{}",
+			DeduplicationKey: &key,
+		})
+		assert.Nil(t, err)
+
+		_, err = pool.Exec(ctx, `
+			update tiny.job
+			set status = 'FAILURE', quarantined_at = now()
+			where id = $1
+		`, job.ID)
+		assert.Nil(t, err)
+
+		// The key is free again while the job is quarantined
+		_, err = resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:             "@after 1 hour",
+			Name:             "active-duplicate",
+			State:            "{}",
+			DeduplicationKey: &key,
+		})
+		assert.Nil(t, err)
+
+		_, err = resolver.Mutation().RequeueJob(ctx, executor, job.ID)
+		assert.ErrorIs(t, err, ErrDuplicated)
+
+		job, err = resolver.Query().QueryJobByID(ctx, executor, job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusFAILURE, job.Status)
+	})
+}
+
+func TestFairFetch(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("fair_fetch")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+
+	createJobs := func(executor, owner string, amount int) {
+		for i := 0; i < amount; i++ {
+			_, err := resolver.Mutation().CreateJob(sqlc.NewCtx(ctx, owner), executor, model.CreateJobArgs{
+				Expr:  "@after 1 second",
+				Name:  fmt.Sprintf("%s-%d", owner, i),
+				State: "{}",
+			})
+			assert.Nil(t, err)
+		}
+	}
+	countOwners := func(jobs []sqlc.TinyJob) map[string]int {
+		owners := map[string]int{}
+		for _, job := range jobs {
+			owners[job.Owner] += 1
+		}
+		return owners
+	}
+
+	t.Run("Should not let a large owner starve others", func(t *testing.T) {
+		createJobs("fair", "big", 20)
+		createJobs("fair", "small-a", 2)
+		createJobs("fair", "small-b", 2)
+
+		time.Sleep(1 * time.Second)
+
+		fifo, err := resolver.Mutation().FetchForProcessing(ctx, "fair", 6, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int{"big": 6}, countOwners(fifo))
+
+		fair, err := resolver.Mutation().FetchForProcessing(ctx, "fair", 6, nil, model.FetchModeFair)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int{"big": 2, "small-a": 2, "small-b": 2}, countOwners(fair))
+	})
+
+	t.Run("Should share fetch according to owner weights", func(t *testing.T) {
+		err := queries.SetOwnerWeight(ctx, sqlc.SetOwnerWeightParams{
+			Owner:  "heavy",
+			Weight: 3,
+		})
+		assert.Nil(t, err)
+
+		createJobs("weighted", "heavy", 10)
+		createJobs("weighted", "light", 10)
+
+		time.Sleep(1 * time.Second)
+
+		fair, err := resolver.Mutation().FetchForProcessing(ctx, "weighted", 8, nil, model.FetchModeFair)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int{"heavy": 6, "light": 2}, countOwners(fair))
+	})
+}
+
+func TestBulkOperations(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("bulk_operations")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+	executor := "test-executor"
+
+	for i := 0; i < 10; i++ {
+		meta := `{"tenant": "a"}`
+		if i%2 == 0 {
+			meta = `{"tenant": "b"}`
+		}
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 hour",
+			Name:  fmt.Sprintf("bulk-%d", i),
+			State: "{}",
+			Meta:  &meta,
+		})
+		assert.Nil(t, err)
+	}
+
+	t.Run("Should pause jobs matching filter", func(t *testing.T) {
+		paused, err := resolver.Mutation().PauseJobs(ctx, executor, model.JobsFilter{
+			Query: ptrstring(`{"tenant": "a"}`),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 5, paused)
+
+		// Already paused jobs are not counted
+		paused, err = resolver.Mutation().PauseJobs(ctx, executor, model.JobsFilter{
+			Query: ptrstring(`{"tenant": "a"}`),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, paused)
+	})
+
+	t.Run("Should resume jobs matching filter", func(t *testing.T) {
+		resumed, err := resolver.Mutation().ResumeJobs(ctx, executor, model.JobsFilter{
+			Name: ptrstring("bulk-1"),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, resumed)
+
+		resumed, err = resolver.Mutation().ResumeJobs(ctx, executor, model.JobsFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, 4, resumed)
+	})
+
+	t.Run("Should delete jobs matching filter", func(t *testing.T) {
+		deleted, err := resolver.Mutation().DeleteJobs(ctx, executor, model.JobsFilter{
+			Query:    ptrstring(`{"tenant": "b"}`),
+			Statuses: []sqlc.TinyStatus{sqlc.TinyStatusREADY},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 5, deleted)
+
+		deleted, err = resolver.Mutation().DeleteJobs(ctx, "other-executor", model.JobsFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, deleted)
+
+		assert.Equal(t, 1, countJobs(pool, "bulk-0")+countJobs(pool, "bulk-1"))
+	})
+}
+
+func TestJobsPagination(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("jobs_pagination")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+	executor := "test-executor"
+
+	for i := 0; i < 25; i++ {
+		meta := fmt.Sprintf(`{"priority": %d}`, i%5)
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  fmt.Sprintf("@after %d minutes", 25-i),
+			Name:  fmt.Sprintf("page-%d", i),
+			State: "{}",
+			Meta:  &meta,
+		})
+		assert.Nil(t, err)
+	}
+
+	collect := func(filter *model.JobsQuery, first int, sort model.JobSort, direction model.SortDirection) []string {
+		var names []string
+		var after *string
+		for {
+			page, err := resolver.Query().Jobs(ctx, executor, filter, first, after, sort, direction)
+			assert.Nil(t, err)
+			assert.LessOrEqual(t, len(page.Edges), first)
+			for _, edge := range page.Edges {
+				names = append(names, edge.Node.Name)
+			}
+			if !page.PageInfo.HasNextPage {
+				return names
+			}
+			after = page.PageInfo.EndCursor
+		}
+	}
+
+	t.Run("Should page through every job", func(t *testing.T) {
+		names := collect(nil, 10, model.JobSortID, model.SortDirectionAsc)
+		assert.Len(t, names, 25)
+		assert.Equal(t, "page-0", names[0])
+		assert.Equal(t, "page-24", names[24])
+
+		names = collect(nil, 7, model.JobSortID, model.SortDirectionDesc)
+		assert.Len(t, names, 25)
+		assert.Equal(t, "page-24", names[0])
+	})
+
+	t.Run("Should sort by run_at", func(t *testing.T) {
+		names := collect(nil, 4, model.JobSortRunAt, model.SortDirectionAsc)
+		assert.Len(t, names, 25)
+		assert.Equal(t, "page-24", names[0])
+		assert.Equal(t, "page-0", names[24])
+
+		names = collect(nil, 4, model.JobSortRunAt, model.SortDirectionDesc)
+		assert.Len(t, names, 25)
+		assert.Equal(t, "page-0", names[0])
+	})
+
+	t.Run("Should filter jobs", func(t *testing.T) {
+		path := `$.priority ? (@ >= 3)`
+		names := collect(&model.JobsQuery{MetaPath: &path}, 3, model.JobSortUpdatedAt, model.SortDirectionAsc)
+		assert.Len(t, names, 10)
+
+		meta := `{"priority": 0}`
+		names = collect(&model.JobsQuery{Meta: &meta}, 3, model.JobSortID, model.SortDirectionAsc)
+		assert.Equal(t, []string{"page-0", "page-5", "page-10", "page-15", "page-20"}, names)
+
+		owner := "someone-else"
+		page, err := resolver.Query().Jobs(ctx, executor, &model.JobsQuery{Owner: &owner}, 10, nil, model.JobSortID, model.SortDirectionAsc)
+		assert.Nil(t, err)
+		assert.Len(t, page.Edges, 0)
+		assert.False(t, page.PageInfo.HasNextPage)
+		assert.Nil(t, page.PageInfo.EndCursor)
+	})
+
+	t.Run("Should reject invalid cursors", func(t *testing.T) {
+		cursor := "not-a-cursor"
+		_, err := resolver.Query().Jobs(ctx, executor, nil, 10, &cursor, model.JobSortID, model.SortDirectionAsc)
+		assert.ErrorIs(t, err, errInvalidCursor)
+	})
+}
+
+func TestCreateJobValidation(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("create_job_validation")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+	executor := "test-executor"
+
+	field := func(err error) interface{} {
+		var gqlErr *gqlerror.Error
+		if !errors.As(err, &gqlErr) {
+			return nil
+		}
+		return gqlErr.Extensions["field"]
+	}
+
+	t.Run("Should reject invalid arguments", func(t *testing.T) {
+		timeout := -1
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:    "@after 1 hour",
+			Name:    "negative-timeout",
+			State:   "{}",
+			Timeout: &timeout,
+		})
+		assert.Equal(t, "args.timeout", field(err))
+
+		meta := `[1, 2]`
+		_, err = resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 hour",
+			Name:  "array-meta",
+			State: "{}",
+			Meta:  &meta,
+		})
+		assert.Equal(t, "args.meta", field(err))
+	})
+
+	t.Run("Should map constraint violations to arguments", func(t *testing.T) {
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@sometimes",
+			Name:  "invalid-expr",
+			State: "{}",
+		})
+		assert.Equal(t, "args.expr", field(err))
+
+		retries := 100
+		_, err = resolver.Mutation().BatchCreateJobs(ctx, executor, []model.CreateJobArgs{
+			{Expr: "@after 1 hour", Name: "valid", State: "{}"},
+			{Expr: "@after 1 hour", Name: "too-many-retries", State: "{}", Retries: &retries},
+		}, model.BatchModeAtomic)
+		assert.Nil(t, err)
+
+		results, err := resolver.Mutation().BatchCreateJobs(ctx, executor, []model.CreateJobArgs{
+			{Expr: "@after 1 hour", Name: "valid", State: "{}"},
+			{Expr: "@after 1 hour", Name: "too-many-retries", State: "{}", Retries: &retries},
+		}, model.BatchModeBestEffort)
+		assert.Nil(t, err)
+		assert.NotNil(t, results[0].Job)
+		assert.Contains(t, *results[1].Error, "args.1.retries")
+	})
+
+	t.Run("Should reject invalid batch arguments", func(t *testing.T) {
+		limit := 0
+		_, err := resolver.Mutation().BatchCreateJobs(ctx, executor, []model.CreateJobArgs{
+			{Expr: "@after 1 hour", Name: "valid", State: "{}"},
+			{Expr: "@after 1 hour", Name: "zero-limit", State: "{}", ConcurrencyKey: &executor, ConcurrencyLimit: &limit},
+		}, model.BatchModeBestEffort)
+		assert.Equal(t, "args.1.concurrency_limit", field(err))
 	})
 }
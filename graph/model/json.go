@@ -0,0 +1,34 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MarshalJSON writes serialized JSON as is. Values that are
+// not valid JSON, e.g. encrypted state, are written as strings
+func MarshalJSON(raw string) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		if json.Valid([]byte(raw)) {
+			io.WriteString(w, raw)
+			return
+		}
+		graphql.MarshalString(raw).MarshalGQL(w)
+	})
+}
+
+// UnmarshalJSON serializes any JSON value. Strings are
+// kept as they are, as they are already serialized
+func UnmarshalJSON(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSON(t *testing.T) {
+	t.Run("Should write valid JSON as is", func(t *testing.T) {
+		var buf bytes.Buffer
+		MarshalJSON(`{"a":1}`).MarshalGQL(&buf)
+		assert.Equal(t, `{"a":1}`, buf.String())
+	})
+
+	t.Run("Should write invalid JSON as string", func(t *testing.T) {
+		var buf bytes.Buffer
+		MarshalJSON(`encrypted:abc`).MarshalGQL(&buf)
+		assert.Equal(t, `"encrypted:abc"`, buf.String())
+	})
+
+	t.Run("Should serialize JSON values", func(t *testing.T) {
+		raw, err := UnmarshalJSON(map[string]interface{}{"a": []interface{}{1, "b"}})
+		assert.Nil(t, err)
+		assert.Equal(t, `{"a":[1,"b"]}`, raw)
+
+		raw, err = UnmarshalJSON(`{"a":1}`)
+		assert.Nil(t, err)
+		assert.Equal(t, `{"a":1}`, raw)
+	})
+}
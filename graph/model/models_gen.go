@@ -3,26 +3,88 @@
 package model
 
 import (
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 
 	"github.com/lucagez/qron/sqlc"
 )
 
+type BatchCreateJobResult struct {
+	Job         *sqlc.TinyJob `json:"job,omitempty"`
+	DuplicateOf *int64        `json:"duplicate_of,omitempty"`
+	Error       *string       `json:"error,omitempty"`
+}
+
 type CommitArgs struct {
 	ID    int64   `json:"id"`
 	Expr  *string `json:"expr,omitempty"`
 	State *string `json:"state,omitempty"`
+	Lease int     `json:"lease"`
 }
 
 type CreateJobArgs struct {
-	Expr             string     `json:"expr"`
-	Name             string     `json:"name"`
-	State            string     `json:"state"`
-	Timeout          *int       `json:"timeout,omitempty"`
-	StartAt          *time.Time `json:"start_at,omitempty"`
-	Meta             *string    `json:"meta,omitempty"`
-	Retries          *int       `json:"retries,omitempty"`
-	DeduplicationKey *string    `json:"deduplication_key,omitempty"`
+	Expr                string     `json:"expr"`
+	Name                string     `json:"name"`
+	State               string     `json:"state"`
+	Timeout             *int       `json:"timeout,omitempty"`
+	StartAt             *time.Time `json:"start_at,omitempty"`
+	Meta                *string    `json:"meta,omitempty"`
+	Retries             *int       `json:"retries,omitempty"`
+	DeduplicationKey    *string    `json:"deduplication_key,omitempty"`
+	DeduplicationWindow *int       `json:"deduplication_window,omitempty"`
+	ConcurrencyKey      *string    `json:"concurrency_key,omitempty"`
+	ConcurrencyLimit    *int       `json:"concurrency_limit,omitempty"`
+}
+
+type IssuedAPIKey struct {
+	Key    string          `json:"key"`
+	APIKey sqlc.TinyApiKey `json:"api_key"`
+}
+
+type JobConnection struct {
+	Edges    []JobEdge `json:"edges"`
+	PageInfo PageInfo  `json:"pageInfo"`
+}
+
+type JobEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   sqlc.TinyJob `json:"node"`
+}
+
+type JobUpdate struct {
+	ID        int64         `json:"id"`
+	Executor  string        `json:"executor"`
+	Operation JobOperation  `json:"operation"`
+	Job       *sqlc.TinyJob `json:"job,omitempty"`
+}
+
+type JobsFilter struct {
+	IsOneShot *bool             `json:"isOneShot,omitempty"`
+	Name      *string           `json:"name,omitempty"`
+	From      *time.Time        `json:"from,omitempty"`
+	To        *time.Time        `json:"to,omitempty"`
+	Statuses  []sqlc.TinyStatus `json:"statuses,omitempty"`
+	Query     *string           `json:"query,omitempty"`
+}
+
+type JobsQuery struct {
+	Statuses      []sqlc.TinyStatus `json:"statuses,omitempty"`
+	IsOneShot     *bool             `json:"isOneShot,omitempty"`
+	Name          *string           `json:"name,omitempty"`
+	Owner         *string           `json:"owner,omitempty"`
+	Meta          *string           `json:"meta,omitempty"`
+	MetaPath      *string           `json:"metaPath,omitempty"`
+	RunAfter      *time.Time        `json:"runAfter,omitempty"`
+	RunBefore     *time.Time        `json:"runBefore,omitempty"`
+	UpdatedAfter  *time.Time        `json:"updatedAfter,omitempty"`
+	UpdatedBefore *time.Time        `json:"updatedBefore,omitempty"`
+}
+
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
 }
 
 type QueryJobsArgs struct {
@@ -32,14 +94,14 @@ type QueryJobsArgs struct {
 }
 
 type QueryJobsMetaArgs struct {
-	Limit     int       `json:"limit"`
-	Skip      int       `json:"skip"`
-	IsOneShot bool      `json:"isOneShot"`
-	Name      *string   `json:"name,omitempty"`
-	From      time.Time `json:"from"`
-	To        time.Time `json:"to"`
-	Statuses  []string  `json:"statuses"`
-	Query     *string   `json:"query,omitempty"`
+	Limit     int               `json:"limit"`
+	Skip      int               `json:"skip"`
+	IsOneShot bool              `json:"isOneShot"`
+	Name      *string           `json:"name,omitempty"`
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Statuses  []sqlc.TinyStatus `json:"statuses"`
+	Query     *string           `json:"query,omitempty"`
 }
 
 type SearchJobsByMetaResult struct {
@@ -52,3 +114,298 @@ type UpdateJobArgs struct {
 	State   *string `json:"state,omitempty"`
 	Timeout *int    `json:"timeout,omitempty"`
 }
+
+type AnomalyKind string
+
+const (
+	AnomalyKindRepeatedResets AnomalyKind = "REPEATED_RESETS"
+	AnomalyKindOverdue        AnomalyKind = "OVERDUE"
+	AnomalyKindStaleCron      AnomalyKind = "STALE_CRON"
+)
+
+var AllAnomalyKind = []AnomalyKind{
+	AnomalyKindRepeatedResets,
+	AnomalyKindOverdue,
+	AnomalyKindStaleCron,
+}
+
+func (e AnomalyKind) IsValid() bool {
+	switch e {
+	case AnomalyKindRepeatedResets, AnomalyKindOverdue, AnomalyKindStaleCron:
+		return true
+	}
+	return false
+}
+
+func (e AnomalyKind) String() string {
+	return string(e)
+}
+
+func (e *AnomalyKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AnomalyKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AnomalyKind", str)
+	}
+	return nil
+}
+
+func (e AnomalyKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type BatchMode string
+
+const (
+	BatchModeAtomic     BatchMode = "ATOMIC"
+	BatchModeBestEffort BatchMode = "BEST_EFFORT"
+)
+
+var AllBatchMode = []BatchMode{
+	BatchModeAtomic,
+	BatchModeBestEffort,
+}
+
+func (e BatchMode) IsValid() bool {
+	switch e {
+	case BatchModeAtomic, BatchModeBestEffort:
+		return true
+	}
+	return false
+}
+
+func (e BatchMode) String() string {
+	return string(e)
+}
+
+func (e *BatchMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = BatchMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid BatchMode", str)
+	}
+	return nil
+}
+
+func (e BatchMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type FetchMode string
+
+const (
+	FetchModeFifo FetchMode = "FIFO"
+	FetchModeFair FetchMode = "FAIR"
+)
+
+var AllFetchMode = []FetchMode{
+	FetchModeFifo,
+	FetchModeFair,
+}
+
+func (e FetchMode) IsValid() bool {
+	switch e {
+	case FetchModeFifo, FetchModeFair:
+		return true
+	}
+	return false
+}
+
+func (e FetchMode) String() string {
+	return string(e)
+}
+
+func (e *FetchMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FetchMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FetchMode", str)
+	}
+	return nil
+}
+
+func (e FetchMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type JobOperation string
+
+const (
+	JobOperationInsert JobOperation = "INSERT"
+	JobOperationUpdate JobOperation = "UPDATE"
+	JobOperationDelete JobOperation = "DELETE"
+)
+
+var AllJobOperation = []JobOperation{
+	JobOperationInsert,
+	JobOperationUpdate,
+	JobOperationDelete,
+}
+
+func (e JobOperation) IsValid() bool {
+	switch e {
+	case JobOperationInsert, JobOperationUpdate, JobOperationDelete:
+		return true
+	}
+	return false
+}
+
+func (e JobOperation) String() string {
+	return string(e)
+}
+
+func (e *JobOperation) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = JobOperation(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid JobOperation", str)
+	}
+	return nil
+}
+
+func (e JobOperation) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type JobSort string
+
+const (
+	JobSortID        JobSort = "ID"
+	JobSortRunAt     JobSort = "RUN_AT"
+	JobSortUpdatedAt JobSort = "UPDATED_AT"
+)
+
+var AllJobSort = []JobSort{
+	JobSortID,
+	JobSortRunAt,
+	JobSortUpdatedAt,
+}
+
+func (e JobSort) IsValid() bool {
+	switch e {
+	case JobSortID, JobSortRunAt, JobSortUpdatedAt:
+		return true
+	}
+	return false
+}
+
+func (e JobSort) String() string {
+	return string(e)
+}
+
+func (e *JobSort) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = JobSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid JobSort", str)
+	}
+	return nil
+}
+
+func (e JobSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type SortDirection string
+
+const (
+	SortDirectionAsc  SortDirection = "ASC"
+	SortDirectionDesc SortDirection = "DESC"
+)
+
+var AllSortDirection = []SortDirection{
+	SortDirectionAsc,
+	SortDirectionDesc,
+}
+
+func (e SortDirection) IsValid() bool {
+	switch e {
+	case SortDirectionAsc, SortDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e SortDirection) String() string {
+	return string(e)
+}
+
+func (e *SortDirection) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortDirection", str)
+	}
+	return nil
+}
+
+func (e SortDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type UpsertMode string
+
+const (
+	UpsertModeKeepExisting    UpsertMode = "KEEP_EXISTING"
+	UpsertModeReplace         UpsertMode = "REPLACE"
+	UpsertModeReplaceSchedule UpsertMode = "REPLACE_SCHEDULE"
+)
+
+var AllUpsertMode = []UpsertMode{
+	UpsertModeKeepExisting,
+	UpsertModeReplace,
+	UpsertModeReplaceSchedule,
+}
+
+func (e UpsertMode) IsValid() bool {
+	switch e {
+	case UpsertModeKeepExisting, UpsertModeReplace, UpsertModeReplaceSchedule:
+		return true
+	}
+	return false
+}
+
+func (e UpsertMode) String() string {
+	return string(e)
+}
+
+func (e *UpsertMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UpsertMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UpsertMode", str)
+	}
+	return nil
+}
+
+func (e UpsertMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
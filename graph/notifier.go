@@ -0,0 +1,166 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// jobChannel is the channel notified by the
+// `tiny.notify_job` trigger on every job change
+const jobChannel = "qron_job"
+
+// JobNotification is the payload of a job change
+type JobNotification struct {
+	ID        int64  `json:"id"`
+	Executor  string `json:"executor"`
+	Owner     string `json:"owner"`
+	Operation string `json:"op"`
+}
+
+// Notifier fans out job changes to subscribers. A single
+// dedicated connection listens as long as there are
+// subscribers, so subscriptions don't hold pooled connections
+type Notifier struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+	mu     sync.Mutex
+	subs   map[chan JobNotification]struct{}
+	stop   context.CancelFunc
+}
+
+func NewNotifier(db *pgxpool.Pool, logger *slog.Logger) *Notifier {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Notifier{
+		db:     db,
+		logger: logger,
+		subs:   map[chan JobNotification]struct{}{},
+	}
+}
+
+// Subscribe returns a channel receiving every job change until ctx
+// is done. Notifications are dropped for subscribers falling behind
+func (n *Notifier) Subscribe(ctx context.Context) <-chan JobNotification {
+	ch := make(chan JobNotification, 64)
+
+	n.mu.Lock()
+	n.subs[ch] = struct{}{}
+	if n.stop == nil {
+		listenCtx, stop := context.WithCancel(context.Background())
+		n.stop = stop
+		go n.listen(listenCtx)
+	}
+	n.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		n.mu.Lock()
+		delete(n.subs, ch)
+		if len(n.subs) == 0 && n.stop != nil {
+			n.stop()
+			n.stop = nil
+		}
+		n.mu.Unlock()
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (n *Notifier) listen(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := n.wait(ctx)
+		if err != nil && ctx.Err() == nil {
+			n.logger.Error("error while listening for job changes", "error", err)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+func (n *Notifier) wait(ctx context.Context) error {
+	conn, err := pgx.ConnectConfig(ctx, n.db.Config().ConnConfig.Copy())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "listen "+jobChannel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var job JobNotification
+		err = json.Unmarshal([]byte(notification.Payload), &job)
+		if err != nil {
+			n.logger.Error("invalid job notification", "payload", notification.Payload, "error", err)
+			continue
+		}
+
+		n.publish(job)
+	}
+}
+
+func (n *Notifier) publish(job JobNotification) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for ch := range n.subs {
+		select {
+		case ch <- job:
+		default:
+			n.logger.Warn("dropping job notification", "job_id", job.ID, "executor", job.Executor)
+		}
+	}
+}
+
+// subscribe returns the changes to jobs of `executor`. Changes to
+// jobs of other owners are filtered out when an owner is in ctx
+func (r *Resolver) subscribe(ctx context.Context, executor string) (<-chan JobNotification, error) {
+	if r.Notifier == nil {
+		return nil, errors.New("subscriptions are not configured")
+	}
+
+	owner := sqlc.FromCtx(ctx)
+	notifications := r.Notifier.Subscribe(ctx)
+
+	ch := make(chan JobNotification)
+	go func() {
+		defer close(ch)
+
+		for notification := range notifications {
+			if notification.Executor != executor {
+				continue
+			}
+			if owner != "" && notification.Owner != owner {
+				continue
+			}
+
+			select {
+			case ch <- notification:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,138 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// jobCursor is the position of a job in a page.
+// `at` is the value of the sorted timestamp
+type jobCursor struct {
+	at time.Time
+	id int64
+}
+
+func encodeCursor(job sqlc.TinyJob, sort model.JobSort) string {
+	var at time.Time
+	switch sort {
+	case model.JobSortRunAt:
+		at = job.RunAt.Time
+	case model.JobSortUpdatedAt:
+		at = job.UpdatedAt.Time
+	}
+	raw := fmt.Sprintf("%d:%d", at.UnixMicro(), job.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (jobCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return jobCursor{}, errInvalidCursor
+	}
+	at, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return jobCursor{}, errInvalidCursor
+	}
+	micros, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return jobCursor{}, errInvalidCursor
+	}
+	jobID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return jobCursor{}, errInvalidCursor
+	}
+	return jobCursor{at: time.UnixMicro(micros), id: jobID}, nil
+}
+
+// jobsParams maps a JobsQuery to the arguments shared by every
+// paginated query. Missing filters are left null
+func jobsParams(executor string, filter *model.JobsQuery, limit int) sqlc.ListJobsByRunAtAscParams {
+	params := sqlc.ListJobsByRunAtAscParams{
+		Executor: executor,
+		Limit:    int32(limit),
+	}
+	if filter == nil {
+		return params
+	}
+	if filter.Statuses != nil {
+		params.Statuses = pgtype.Text{String: joinStatuses(filter.Statuses), Valid: true}
+	}
+	if filter.IsOneShot != nil {
+		params.IsOneShot = pgtype.Bool{Bool: *filter.IsOneShot, Valid: true}
+	}
+	if filter.Name != nil {
+		params.Name = pgtype.Text{String: *filter.Name, Valid: true}
+	}
+	if filter.Owner != nil {
+		params.Owner = pgtype.Text{String: *filter.Owner, Valid: true}
+	}
+	if filter.Meta != nil {
+		params.Meta = pgtype.Text{String: *filter.Meta, Valid: true}
+	}
+	if filter.MetaPath != nil {
+		params.MetaPath = pgtype.Text{String: *filter.MetaPath, Valid: true}
+	}
+	if filter.RunAfter != nil {
+		params.RunAfter = pgtype.Timestamptz{Time: *filter.RunAfter, Valid: true}
+	}
+	if filter.RunBefore != nil {
+		params.RunBefore = pgtype.Timestamptz{Time: *filter.RunBefore, Valid: true}
+	}
+	if filter.UpdatedAfter != nil {
+		params.UpdatedAfter = pgtype.Timestamptz{Time: *filter.UpdatedAfter, Valid: true}
+	}
+	if filter.UpdatedBefore != nil {
+		params.UpdatedBefore = pgtype.Timestamptz{Time: *filter.UpdatedBefore, Valid: true}
+	}
+	return params
+}
+
+// listJobs runs the query matching the sort option.
+// Every sort has its own query so it can be served by an index
+func (r *Resolver) listJobs(ctx context.Context, params sqlc.ListJobsByRunAtAscParams, sort model.JobSort, direction model.SortDirection) ([]sqlc.TinyJob, error) {
+	desc := direction == model.SortDirectionDesc
+
+	switch sort {
+	case model.JobSortRunAt:
+		if desc {
+			return r.Queries.ListJobsByRunAtDesc(ctx, sqlc.ListJobsByRunAtDescParams(params))
+		}
+		return r.Queries.ListJobsByRunAtAsc(ctx, params)
+	case model.JobSortUpdatedAt:
+		if desc {
+			return r.Queries.ListJobsByUpdatedAtDesc(ctx, sqlc.ListJobsByUpdatedAtDescParams(params))
+		}
+		return r.Queries.ListJobsByUpdatedAtAsc(ctx, sqlc.ListJobsByUpdatedAtAscParams(params))
+	}
+
+	byID := sqlc.ListJobsByIDAscParams{
+		Executor:      params.Executor,
+		Statuses:      params.Statuses,
+		IsOneShot:     params.IsOneShot,
+		Name:          params.Name,
+		Owner:         params.Owner,
+		Meta:          params.Meta,
+		MetaPath:      params.MetaPath,
+		RunAfter:      params.RunAfter,
+		RunBefore:     params.RunBefore,
+		UpdatedAfter:  params.UpdatedAfter,
+		UpdatedBefore: params.UpdatedBefore,
+		AfterID:       params.AfterID,
+		Limit:         params.Limit,
+	}
+	if desc {
+		return r.Queries.ListJobsByIDDesc(ctx, sqlc.ListJobsByIDDescParams(byID))
+	}
+	return r.Queries.ListJobsByIDAsc(ctx, byID)
+}
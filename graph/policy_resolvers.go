@@ -0,0 +1,107 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.34
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/graph/generated"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// Operation is the resolver for the operation field.
+func (r *auditEntryResolver) Operation(ctx context.Context, obj *sqlc.TinyAuditLog) (auth.Operation, error) {
+	return auth.Operation(obj.Operation), nil
+}
+
+// CreatedAt is the resolver for the created_at field.
+func (r *auditEntryResolver) CreatedAt(ctx context.Context, obj *sqlc.TinyAuditLog) (time.Time, error) {
+	return obj.CreatedAt.Time, nil
+}
+
+// GrantPolicy is the resolver for the grantPolicy field.
+func (r *mutationResolver) GrantPolicy(ctx context.Context, role string, executor string, operation *auth.Operation) (sqlc.TinyPolicy, error) {
+	if err := r.authorize(ctx, "", auth.OperationAdmin); err != nil {
+		return sqlc.TinyPolicy{}, err
+	}
+	if role == "" {
+		return sqlc.TinyPolicy{}, validationError(ctx, "role", "is required")
+	}
+	if executor == "" {
+		return sqlc.TinyPolicy{}, validationError(ctx, "executor", "is required")
+	}
+
+	params := sqlc.CreatePolicyParams{
+		Owner:     sqlc.FromCtx(ctx),
+		Role:      role,
+		Executor:  executor,
+		Operation: string(auth.OperationAny),
+	}
+	if operation != nil {
+		params.Operation = string(*operation)
+	}
+	return r.Queries.CreatePolicy(ctx, params)
+}
+
+// RevokePolicy is the resolver for the revokePolicy field.
+func (r *mutationResolver) RevokePolicy(ctx context.Context, id int64) (sqlc.TinyPolicy, error) {
+	if err := r.authorize(ctx, "", auth.OperationAdmin); err != nil {
+		return sqlc.TinyPolicy{}, err
+	}
+	return r.Queries.DeletePolicy(ctx, sqlc.DeletePolicyParams{
+		ID:    id,
+		Owner: sqlc.FromCtx(ctx),
+	})
+}
+
+// Operation is the resolver for the operation field.
+func (r *policyResolver) Operation(ctx context.Context, obj *sqlc.TinyPolicy) (*auth.Operation, error) {
+	if auth.Operation(obj.Operation) == auth.OperationAny {
+		return nil, nil
+	}
+	operation := auth.Operation(obj.Operation)
+	return &operation, nil
+}
+
+// CreatedAt is the resolver for the created_at field.
+func (r *policyResolver) CreatedAt(ctx context.Context, obj *sqlc.TinyPolicy) (time.Time, error) {
+	return obj.CreatedAt.Time, nil
+}
+
+// Policies is the resolver for the policies field.
+func (r *queryResolver) Policies(ctx context.Context) ([]sqlc.TinyPolicy, error) {
+	if err := r.authorize(ctx, "", auth.OperationAdmin); err != nil {
+		return nil, err
+	}
+	return r.Queries.ListPolicies(ctx, sqlc.FromCtx(ctx))
+}
+
+// AuditLog is the resolver for the auditLog field.
+func (r *queryResolver) AuditLog(ctx context.Context, executor *string, limit int) ([]sqlc.TinyAuditLog, error) {
+	if err := r.authorize(ctx, "", auth.OperationAdmin); err != nil {
+		return nil, err
+	}
+
+	params := sqlc.ListAuditLogParams{
+		Owner: sqlc.FromCtx(ctx),
+		Limit: int32(limit),
+	}
+	if executor != nil {
+		params.Executor = pgtype.Text{String: *executor, Valid: true}
+	}
+	return r.Queries.ListAuditLog(ctx, params)
+}
+
+// AuditEntry returns generated.AuditEntryResolver implementation.
+func (r *Resolver) AuditEntry() generated.AuditEntryResolver { return &auditEntryResolver{r} }
+
+// Policy returns generated.PolicyResolver implementation.
+func (r *Resolver) Policy() generated.PolicyResolver { return &policyResolver{r} }
+
+type auditEntryResolver struct{ *Resolver }
+type policyResolver struct{ *Resolver }
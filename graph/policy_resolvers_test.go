@@ -0,0 +1,136 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("policy")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := sqlc.NewCtx(context.Background(), "acme")
+
+	create := func(operation auth.Operation) *auth.Operation {
+		return &operation
+	}
+	_, err := resolver.Mutation().GrantPolicy(ctx, "producer", "email", create(auth.OperationCreate))
+	assert.Nil(t, err)
+	_, err = resolver.Mutation().GrantPolicy(ctx, "producer", "*", create(auth.OperationRead))
+	assert.Nil(t, err)
+	_, err = resolver.Mutation().GrantPolicy(ctx, "worker", "email", nil)
+	assert.Nil(t, err)
+
+	producer := auth.NewCtx(ctx, auth.Principal{
+		Subject: "producer-key",
+		Owner:   "acme",
+		Scopes:  []auth.Scope{auth.ScopeRead, auth.ScopeWrite},
+		Roles:   []string{"producer"},
+	})
+	args := model.CreateJobArgs{Expr: "@after 1 hour", Name: "welcome", State: "{}"}
+
+	t.Run("Should allow granted operations", func(t *testing.T) {
+		job, err := resolver.Mutation().CreateJob(producer, "email", args)
+		assert.Nil(t, err)
+
+		_, err = resolver.Query().QueryJobByID(producer, "email", job.ID)
+		assert.Nil(t, err)
+
+		_, err = resolver.Query().SearchJobs(producer, "billing", model.QueryJobsArgs{Limit: 10})
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should deny operations not granted", func(t *testing.T) {
+		job, err := resolver.Query().QueryJobByName(producer, "email", "welcome")
+		assert.Nil(t, err)
+
+		_, err = resolver.Mutation().DeleteJobByID(producer, "email", job.ID)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+
+		var denied *auth.DeniedError
+		assert.True(t, errors.As(err, &denied))
+		assert.Equal(t, "email", denied.Executor)
+		assert.Equal(t, auth.OperationDelete, denied.Operation)
+
+		_, err = resolver.Mutation().CreateJob(producer, "billing", args)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+
+		_, err = resolver.Mutation().CreateOrUpdateJob(producer, "email", args, model.UpsertModeReplace)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+
+		_, err = resolver.Query().Policies(producer)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+
+		// The job is still there
+		_, err = resolver.Query().QueryJobByID(ctx, "email", job.ID)
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should match any operation", func(t *testing.T) {
+		worker := auth.NewCtx(ctx, auth.Principal{
+			Subject: "worker-key",
+			Owner:   "acme",
+			Scopes:  []auth.Scope{auth.ScopeRead, auth.ScopeWrite},
+			Roles:   []string{"worker"},
+		})
+
+		_, err := resolver.Mutation().FetchForProcessing(worker, "email", 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+
+		_, err = resolver.Mutation().FetchForProcessing(worker, "billing", 10, nil, model.FetchModeFifo)
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+
+	t.Run("Should audit denied operations", func(t *testing.T) {
+		entries, err := resolver.Query().AuditLog(ctx, nil, 100)
+		assert.Nil(t, err)
+		assert.Len(t, entries, 5)
+		for _, entry := range entries {
+			assert.Equal(t, "acme", entry.Owner)
+		}
+
+		latest := entries[0]
+		assert.Equal(t, "worker-key", latest.Subject)
+		assert.Equal(t, []string{"worker"}, latest.Roles)
+		assert.Equal(t, "billing", latest.Executor)
+		assert.Equal(t, string(auth.OperationProcess), latest.Operation)
+
+		billing := "billing"
+		entries, err = resolver.Query().AuditLog(ctx, &billing, 100)
+		assert.Nil(t, err)
+		assert.Len(t, entries, 2)
+
+		entries, err = resolver.Query().AuditLog(sqlc.NewCtx(context.Background(), "other"), nil, 100)
+		assert.Nil(t, err)
+		assert.Len(t, entries, 0)
+	})
+
+	t.Run("Should revoke policies", func(t *testing.T) {
+		policies, err := resolver.Query().Policies(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, policies, 3)
+
+		for _, policy := range policies {
+			if policy.Role == "producer" && policy.Executor == "email" {
+				_, err := resolver.Mutation().RevokePolicy(ctx, policy.ID)
+				assert.Nil(t, err)
+			}
+		}
+
+		_, err = resolver.Mutation().CreateJob(producer, "email", model.CreateJobArgs{
+			Expr:  "@after 1 hour",
+			Name:  "reminder",
+			State: "{}",
+		})
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+}
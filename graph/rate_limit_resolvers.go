@@ -0,0 +1,20 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.34
+
+import (
+	"context"
+
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// RateLimits is the resolver for the rateLimits field.
+func (r *queryResolver) RateLimits(ctx context.Context, executor string) ([]sqlc.ListRateLimitsRow, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return nil, err
+	}
+	return r.Queries.ListRateLimits(ctx, executor)
+}
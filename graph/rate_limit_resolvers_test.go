@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("rate_limit")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+
+	createJobs := func(executor, owner string, amount int) {
+		for i := 0; i < amount; i++ {
+			_, err := resolver.Mutation().CreateJob(sqlc.NewCtx(ctx, owner), executor, model.CreateJobArgs{
+				Expr:  "@after 1 second",
+				Name:  fmt.Sprintf("%s-%d", owner, i),
+				State: "{}",
+			})
+			assert.Nil(t, err)
+		}
+	}
+
+	t.Run("Should limit jobs fetched from executor", func(t *testing.T) {
+		executor := "email"
+		err := queries.SetRateLimit(ctx, sqlc.SetRateLimitParams{
+			Executor: executor,
+			Rate:     1,
+			Burst:    3,
+		})
+		assert.Nil(t, err)
+
+		createJobs(executor, "default", 10)
+		time.Sleep(1 * time.Second)
+
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 3)
+
+		fetch, err = resolver.Mutation().FetchForProcessing(ctx, executor, 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 0)
+
+		limits, err := resolver.Query().RateLimits(ctx, executor)
+		assert.Nil(t, err)
+		assert.Len(t, limits, 1)
+		assert.Less(t, limits[0].Tokens, float64(1))
+
+		time.Sleep(1 * time.Second)
+
+		fetch, err = resolver.Mutation().FetchForProcessing(ctx, executor, 10, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 1)
+	})
+
+	t.Run("Should limit jobs fetched for a single owner", func(t *testing.T) {
+		executor := "sms"
+		err := queries.SetRateLimit(ctx, sqlc.SetRateLimitParams{
+			Executor: executor,
+			Owner:    "noisy",
+			Rate:     0.1,
+			Burst:    2,
+		})
+		assert.Nil(t, err)
+
+		createJobs(executor, "noisy", 5)
+		createJobs(executor, "quiet", 5)
+		time.Sleep(1 * time.Second)
+
+		for _, mode := range []model.FetchMode{model.FetchModeFifo, model.FetchModeFair} {
+			_, err = resolver.Mutation().FetchForProcessing(ctx, executor, 10, nil, mode)
+			assert.Nil(t, err)
+		}
+
+		pending, err := resolver.Query().SearchJobsByMeta(ctx, executor, model.QueryJobsMetaArgs{
+			Statuses:  []sqlc.TinyStatus{sqlc.TinyStatusPENDING},
+			From:      time.Now().Add(-1 * time.Hour),
+			To:        time.Now().Add(1 * time.Hour),
+			IsOneShot: true,
+			Limit:     100,
+		})
+		assert.Nil(t, err)
+
+		owners := map[string]int{}
+		for _, job := range pending.Jobs {
+			owners[job.Owner] += 1
+		}
+		assert.Equal(t, map[string]int{"noisy": 2, "quiet": 5}, owners)
+	})
+}
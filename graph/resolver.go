@@ -3,7 +3,11 @@ package graph
 //go:generate go run github.com/99designs/gqlgen@latest generate
 
 import (
+	"context"
+	"log/slog"
+
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lucagez/qron/auth"
 	"github.com/lucagez/qron/sqlc"
 )
 
@@ -29,10 +33,38 @@ import (
 // [] add partitioning to jobs table
 // [] rename `FAILURE` to `FAILED`
 // [] rename `TinyJob` to `Job`
-// [] Job deduplication can use a window of time instead of absolute, this can also be solved client side as
+// [✅] Job deduplication can use a window of time instead of absolute, this can also be solved client side as
 //    hashes can be created with e.g. a time bucket, by minute or by hour, etc..
 
 type Resolver struct {
 	Queries *sqlc.Queries
 	DB      *pgxpool.Pool
+	// Logger defaults to slog.Default() when nil
+	Logger *slog.Logger
+	// Notifier feeds subscriptions. Subscribing
+	// fails when nil
+	Notifier *Notifier
+	// Authorizer checks the principal in context.
+	// One using Logger is created when nil
+	Authorizer *auth.Authorizer
+}
+
+func (r *Resolver) logger() *slog.Logger {
+	if r.Logger == nil {
+		return slog.Default()
+	}
+	return r.Logger
+}
+
+func (r *Resolver) authorizer() *auth.Authorizer {
+	if r.Authorizer == nil {
+		return auth.NewAuthorizer(r.Queries, r.logger())
+	}
+	return r.Authorizer
+}
+
+// authorize fails with a *auth.DeniedError when the caller
+// is not allowed `op` on `executor`
+func (r *Resolver) authorize(ctx context.Context, executor string, op auth.Operation) error {
+	return r.authorizer().Authorize(ctx, executor, op)
 }
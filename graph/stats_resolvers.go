@@ -0,0 +1,66 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.34
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/graph/generated"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// OldestReadyRunAt is the resolver for the oldest_ready_run_at field.
+func (r *executorStatsResolver) OldestReadyRunAt(ctx context.Context, obj *sqlc.ListExecutorStatsRow) (*time.Time, error) {
+	if !obj.OldestReadyRunAt.Valid {
+		return nil, nil
+	}
+	return &obj.OldestReadyRunAt.Time, nil
+}
+
+// ExecutorStats is the resolver for the executorStats field.
+func (r *queryResolver) ExecutorStats(ctx context.Context, executor string) (sqlc.ListExecutorStatsRow, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return sqlc.ListExecutorStatsRow{}, err
+	}
+	stats, err := r.Queries.ListExecutorStats(ctx, pgtype.Text{String: executor, Valid: true})
+	if err != nil {
+		return sqlc.ListExecutorStatsRow{}, err
+	}
+	// Executors without jobs have no stats
+	if len(stats) == 0 {
+		return sqlc.ListExecutorStatsRow{Executor: executor}, nil
+	}
+	return stats[0], nil
+}
+
+// Executors is the resolver for the executors field.
+func (r *queryResolver) Executors(ctx context.Context) ([]sqlc.ListExecutorStatsRow, error) {
+	rows, err := r.Queries.ListExecutorStats(ctx, pgtype.Text{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Executors the caller can't read are left out
+	authorizer := r.authorizer()
+	var allowed []sqlc.ListExecutorStatsRow
+	for _, row := range rows {
+		ok, err := authorizer.Allowed(ctx, row.Executor, auth.OperationRead)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			allowed = append(allowed, row)
+		}
+	}
+	return allowed, nil
+}
+
+// ExecutorStats returns generated.ExecutorStatsResolver implementation.
+func (r *Resolver) ExecutorStats() generated.ExecutorStatsResolver { return &executorStatsResolver{r} }
+
+type executorStatsResolver struct{ *Resolver }
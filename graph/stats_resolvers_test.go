@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutorStats(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("executor_stats")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+
+	t.Run("Should return empty stats for unknown executor", func(t *testing.T) {
+		stats, err := resolver.Query().ExecutorStats(ctx, "unknown")
+		assert.Nil(t, err)
+		assert.Equal(t, "unknown", stats.Executor)
+		assert.Equal(t, int64(0), stats.Ready)
+		assert.False(t, stats.OldestReadyRunAt.Valid)
+	})
+
+	t.Run("Should compute stats from jobs and runs", func(t *testing.T) {
+		executor := "email"
+		for i := 0; i < 5; i++ {
+			_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+				Expr:  "@after 100ms",
+				Name:  fmt.Sprintf("due-%d", i),
+				State: "{}",
+			})
+			assert.Nil(t, err)
+		}
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 hour",
+			Name:  "later",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(200 * time.Millisecond)
+
+		stats, err := resolver.Query().ExecutorStats(ctx, executor)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(6), stats.Ready)
+		assert.Equal(t, int64(5), stats.Due)
+		assert.True(t, stats.OldestReadyRunAt.Valid)
+		assert.Equal(t, int64(0), stats.Throughput1h)
+
+		jobs, err := resolver.Mutation().FetchForProcessing(ctx, executor, 3, nil, model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, jobs, 3)
+
+		time.Sleep(100 * time.Millisecond)
+
+		rejected, err := resolver.Mutation().CommitJobs(ctx, executor, []model.CommitArgs{{ID: jobs[0].ID, Lease: int(jobs[0].Lease)}, {ID: jobs[1].ID, Lease: int(jobs[1].Lease)}})
+		assert.Nil(t, err)
+		assert.Len(t, rejected, 0)
+
+		stats, err = resolver.Query().ExecutorStats(ctx, executor)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(3), stats.Ready)
+		assert.Equal(t, int64(1), stats.Pending)
+		assert.Equal(t, int64(2), stats.Success)
+		assert.Equal(t, int64(2), stats.Throughput1m)
+		assert.Equal(t, int64(2), stats.Throughput1h)
+		assert.Greater(t, stats.AvgDuration, 0.05)
+		assert.GreaterOrEqual(t, stats.P99Duration, stats.P50Duration)
+	})
+
+	t.Run("Should list every executor", func(t *testing.T) {
+		_, err := resolver.Mutation().CreateJob(ctx, "billing", model.CreateJobArgs{
+			Expr:  "@every 1 hour",
+			Name:  "invoice",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		executors, err := resolver.Query().Executors(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, executors, 2)
+		assert.Equal(t, "billing", executors[0].Executor)
+		assert.Equal(t, "email", executors[1].Executor)
+	})
+}
@@ -0,0 +1,133 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.34
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/graph/generated"
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// JobUpdated is the resolver for the jobUpdated field.
+func (r *subscriptionResolver) JobUpdated(ctx context.Context, executor string, id *int64) (<-chan model.JobUpdate, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return nil, err
+	}
+	notifications, err := r.subscribe(ctx, executor)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan model.JobUpdate)
+	go func() {
+		defer close(ch)
+
+		for notification := range notifications {
+			if id != nil && notification.ID != *id {
+				continue
+			}
+
+			update := model.JobUpdate{
+				ID:        notification.ID,
+				Executor:  notification.Executor,
+				Operation: model.JobOperation(notification.Operation),
+			}
+			if update.Operation != model.JobOperationDelete {
+				job, err := r.Queries.GetJobByID(ctx, sqlc.GetJobByIDParams{
+					ID:       notification.ID,
+					Executor: executor,
+				})
+				if err != nil {
+					// Job deleted in the meantime or not visible to the owner
+					continue
+				}
+				update.Job = &job
+			}
+
+			select {
+			case ch <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// ExecutorStats is the resolver for the executorStats field.
+func (r *subscriptionResolver) ExecutorStats(ctx context.Context, executor string) (<-chan []sqlc.CountJobsByStatusRow, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return nil, err
+	}
+	notifications, err := r.subscribe(ctx, executor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Counts are scoped like notifications, as pools
+	// without RLS would count jobs of every owner
+	params := sqlc.CountJobsByStatusParams{Executor: executor}
+	if owner := sqlc.FromCtx(ctx); owner != "" {
+		params.Owner = pgtype.Text{String: owner, Valid: true}
+	}
+
+	ch := make(chan []sqlc.CountJobsByStatusRow)
+	go func() {
+		defer close(ch)
+
+		send := func() bool {
+			counts, err := r.Queries.CountJobsByStatus(ctx, params)
+			if err != nil {
+				r.logger().Error("error while counting jobs", "executor", executor, "error", err)
+				return false
+			}
+			select {
+			case ch <- counts:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if !send() {
+			return
+		}
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		// Changes are coalesced so bursts of updates
+		// result in a single count every tick
+		changed := false
+		for {
+			select {
+			case _, ok := <-notifications:
+				if !ok {
+					return
+				}
+				changed = true
+			case <-ticker.C:
+				if changed && !send() {
+					return
+				}
+				changed = false
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Subscription returns generated.SubscriptionResolver implementation.
+func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
+
+type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,185 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptions(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("subscriptions")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool, Notifier: NewNotifier(pool, nil)}
+
+	receive := func(ch <-chan model.JobUpdate) (model.JobUpdate, bool) {
+		select {
+		case update := <-ch:
+			return update, true
+		case <-time.After(2 * time.Second):
+			return model.JobUpdate{}, false
+		}
+	}
+
+	t.Run("Should emit job updates of the owner", func(t *testing.T) {
+		ctx, stop := context.WithCancel(sqlc.NewCtx(context.Background(), "alice"))
+		defer stop()
+
+		updates, err := resolver.Subscription().JobUpdated(ctx, "live", nil)
+		assert.Nil(t, err)
+
+		// Wait for the listener to be connected
+		time.Sleep(200 * time.Millisecond)
+
+		_, err = resolver.Mutation().CreateJob(sqlc.NewCtx(context.Background(), "bob"), "live", model.CreateJobArgs{
+			Expr:  "@after 1 hour",
+			Name:  "bob-job",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+		job, err := resolver.Mutation().CreateJob(ctx, "live", model.CreateJobArgs{
+			Expr:  "@after 1 hour",
+			Name:  "alice-job",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		update, ok := receive(updates)
+		assert.True(t, ok)
+		assert.Equal(t, job.ID, update.ID)
+		assert.Equal(t, model.JobOperationInsert, update.Operation)
+		assert.Equal(t, "alice-job", update.Job.Name)
+
+		_, err = resolver.Mutation().DeleteJobByID(ctx, "live", job.ID)
+		assert.Nil(t, err)
+
+		update, ok = receive(updates)
+		assert.True(t, ok)
+		assert.Equal(t, model.JobOperationDelete, update.Operation)
+		assert.Nil(t, update.Job)
+	})
+
+	t.Run("Should emit updates of a single job", func(t *testing.T) {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+
+		a, err := resolver.Mutation().CreateJob(ctx, "single", model.CreateJobArgs{
+			Expr:  "@every 1 hour",
+			Name:  "a",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+		b, err := resolver.Mutation().CreateJob(ctx, "single", model.CreateJobArgs{
+			Expr:  "@every 1 hour",
+			Name:  "b",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		updates, err := resolver.Subscription().JobUpdated(ctx, "single", &b.ID)
+		assert.Nil(t, err)
+		time.Sleep(200 * time.Millisecond)
+
+		_, err = resolver.Mutation().StopJob(ctx, "single", a.ID)
+		assert.Nil(t, err)
+		_, err = resolver.Mutation().StopJob(ctx, "single", b.ID)
+		assert.Nil(t, err)
+
+		update, ok := receive(updates)
+		assert.True(t, ok)
+		assert.Equal(t, b.ID, update.ID)
+		assert.Equal(t, model.JobOperationUpdate, update.Operation)
+		assert.Equal(t, sqlc.TinyStatusPAUSED, update.Job.Status)
+	})
+
+	t.Run("Should only emit status changes and unmuted inserts", func(t *testing.T) {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+
+		job, err := resolver.Mutation().CreateJob(ctx, "quiet", model.CreateJobArgs{
+			Expr:  "@every 1 hour",
+			Name:  "quiet",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		updates, err := resolver.Subscription().JobUpdated(ctx, "quiet", nil)
+		assert.Nil(t, err)
+		time.Sleep(200 * time.Millisecond)
+
+		tx, err := pool.Begin(ctx)
+		assert.Nil(t, err)
+		assert.Nil(t, queries.WithTx(tx).MuteJobNotifications(ctx))
+		_, err = tx.Exec(ctx, `insert into tiny.job (expr, executor, state, run_at) values ('@every 1 hour', 'quiet', '{}', now())`)
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Commit(ctx))
+
+		_, err = resolver.Mutation().UpdateStateByID(ctx, "quiet", job.ID, `{"seen":true}`)
+		assert.Nil(t, err)
+		_, err = resolver.Mutation().StopJob(ctx, "quiet", job.ID)
+		assert.Nil(t, err)
+
+		update, ok := receive(updates)
+		assert.True(t, ok)
+		assert.Equal(t, job.ID, update.ID)
+		assert.Equal(t, model.JobOperationUpdate, update.Operation)
+		assert.Equal(t, sqlc.TinyStatusPAUSED, update.Job.Status)
+	})
+
+	t.Run("Should emit executor stats", func(t *testing.T) {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+
+		stats, err := resolver.Subscription().ExecutorStats(ctx, "stats")
+		assert.Nil(t, err)
+
+		counts := <-stats
+		assert.Len(t, counts, 0)
+
+		for _, name := range []string{"a", "b"} {
+			_, err = resolver.Mutation().CreateJob(ctx, "stats", model.CreateJobArgs{
+				Expr:  "@after 1 hour",
+				Name:  name,
+				State: "{}",
+			})
+			assert.Nil(t, err)
+		}
+
+		select {
+		case counts = <-stats:
+		case <-time.After(3 * time.Second):
+			t.Fatal("no stats received")
+		}
+		assert.Equal(t, []sqlc.CountJobsByStatusRow{{Status: sqlc.TinyStatusREADY, Count: 2}}, counts)
+	})
+
+	t.Run("Should only count jobs of the owner", func(t *testing.T) {
+		ctx, stop := context.WithCancel(sqlc.NewCtx(context.Background(), "alice"))
+		defer stop()
+
+		_, err := resolver.Mutation().CreateJob(sqlc.NewCtx(context.Background(), "bob"), "owned-stats", model.CreateJobArgs{
+			Expr:  "@after 1 hour",
+			Name:  "bob-job",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+		_, err = resolver.Mutation().CreateJob(ctx, "owned-stats", model.CreateJobArgs{
+			Expr:  "@after 1 hour",
+			Name:  "alice-job",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		stats, err := resolver.Subscription().ExecutorStats(ctx, "owned-stats")
+		assert.Nil(t, err)
+
+		counts := <-stats
+		assert.Equal(t, []sqlc.CountJobsByStatusRow{{Status: sqlc.TinyStatusREADY, Count: 1}}, counts)
+	})
+}
@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lucagez/qron/graph/model"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ErrDuplicated is returned when an active job of the same owner and
+// executor, or one finished within the deduplication window, already
+// holds the deduplication key. CreateJob returns that job instead
+var ErrDuplicated = errors.New("duplicated deduplication key")
+
+// deduplicationConstraint is the unique index on the keys of active jobs
+const deduplicationConstraint = "job_deduplication_key_constraint"
+
+// constraintFields maps job table constraints to
+// the argument holding the invalid value
+var constraintFields = map[string]string{
+	"run_format":                 "expr",
+	"positive_timeout":           "timeout",
+	"max_retries":                "retries",
+	"job_state_check":            "state",
+	"positive_concurrency_limit": "concurrency_limit",
+}
+
+// validationError reports an invalid argument. `field` is the path
+// of the argument, e.g. `args.timeout`, exposed in the extensions
+func validationError(ctx context.Context, field, message string) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message: fmt.Sprintf("%s: %s", field, message),
+		Path:    graphql.GetPath(ctx),
+		Extensions: map[string]interface{}{
+			"code":  "BAD_USER_INPUT",
+			"field": field,
+		},
+	}
+}
+
+// validateCreateJobArgs checks what can be checked before hitting
+// the database. Remaining checks are left to table constraints
+func validateCreateJobArgs(ctx context.Context, prefix string, args model.CreateJobArgs) error {
+	if args.Expr == "" {
+		return validationError(ctx, prefix+".expr", "is required")
+	}
+	if args.Timeout != nil && *args.Timeout <= 0 {
+		return validationError(ctx, prefix+".timeout", "must be positive")
+	}
+	if args.Retries != nil && *args.Retries < 0 {
+		return validationError(ctx, prefix+".retries", "must not be negative")
+	}
+	if args.ConcurrencyLimit != nil && *args.ConcurrencyLimit <= 0 {
+		return validationError(ctx, prefix+".concurrency_limit", "must be positive")
+	}
+	if args.DeduplicationWindow != nil && *args.DeduplicationWindow < 0 {
+		return validationError(ctx, prefix+".deduplication_window", "must not be negative")
+	}
+	if args.Meta != nil {
+		var meta map[string]interface{}
+		if err := json.Unmarshal([]byte(*args.Meta), &meta); err != nil {
+			return validationError(ctx, prefix+".meta", "must be a JSON object")
+		}
+	}
+	return nil
+}
+
+// constraintError turns violations of the job table
+// constraints into errors pointing to the invalid argument
+func constraintError(ctx context.Context, prefix string, err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	if pgErr.ConstraintName == deduplicationConstraint {
+		return duplicatedError(err)
+	}
+	field, ok := constraintFields[pgErr.ConstraintName]
+	if !ok {
+		return err
+	}
+	return validationError(ctx, prefix+"."+field, "violates "+pgErr.ConstraintName)
+}
+
+// duplicatedError wraps violations of the deduplication
+// index with ErrDuplicated. Other errors are returned as is
+func duplicatedError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == deduplicationConstraint {
+		return fmt.Errorf("%w: %s", ErrDuplicated, pgErr.Detail)
+	}
+	return err
+}
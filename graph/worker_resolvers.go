@@ -0,0 +1,68 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.34
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/graph/generated"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// Heartbeat is the resolver for the heartbeat field.
+func (r *mutationResolver) Heartbeat(ctx context.Context, executor string, worker string, ttl int) (sqlc.TinyWorker, error) {
+	if err := r.authorize(ctx, executor, auth.OperationProcess); err != nil {
+		return sqlc.TinyWorker{}, err
+	}
+	if worker == "" {
+		return sqlc.TinyWorker{}, validationError(ctx, "worker", "is required")
+	}
+	if ttl <= 0 {
+		return sqlc.TinyWorker{}, validationError(ctx, "ttl", "must be positive")
+	}
+	return r.Queries.HeartbeatWorker(ctx, sqlc.HeartbeatWorkerParams{
+		ID:       worker,
+		Executor: executor,
+		Ttl:      int32(ttl),
+	})
+}
+
+// Workers is the resolver for the workers field.
+func (r *queryResolver) Workers(ctx context.Context, executor string) ([]sqlc.TinyWorker, error) {
+	if err := r.authorize(ctx, executor, auth.OperationRead); err != nil {
+		return nil, err
+	}
+	return r.Queries.ListWorkers(ctx, executor)
+}
+
+// StartedAt is the resolver for the started_at field.
+func (r *tinyWorkerResolver) StartedAt(ctx context.Context, obj *sqlc.TinyWorker) (time.Time, error) {
+	return obj.StartedAt.Time, nil
+}
+
+// HeartbeatAt is the resolver for the heartbeat_at field.
+func (r *tinyWorkerResolver) HeartbeatAt(ctx context.Context, obj *sqlc.TinyWorker) (time.Time, error) {
+	return obj.HeartbeatAt.Time, nil
+}
+
+// ExpiresAt is the resolver for the expires_at field.
+func (r *tinyWorkerResolver) ExpiresAt(ctx context.Context, obj *sqlc.TinyWorker) (time.Time, error) {
+	return obj.ExpiresAt.Time, nil
+}
+
+// Jobs is the resolver for the jobs field.
+func (r *tinyWorkerResolver) Jobs(ctx context.Context, obj *sqlc.TinyWorker) ([]sqlc.TinyJob, error) {
+	return r.Queries.ListWorkerJobs(ctx, sqlc.ListWorkerJobsParams{
+		Worker:   obj.ID,
+		Executor: obj.Executor,
+	})
+}
+
+// TinyWorker returns generated.TinyWorkerResolver implementation.
+func (r *Resolver) TinyWorker() generated.TinyWorkerResolver { return &tinyWorkerResolver{r} }
+
+type tinyWorkerResolver struct{ *Resolver }
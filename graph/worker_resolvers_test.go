@@ -0,0 +1,169 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+	"github.com/lucagez/qron/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkers(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("workers")
+	defer cleanup()
+
+	queries := sqlc.New(pool)
+	resolver := Resolver{Queries: queries, DB: pool}
+	ctx := context.Background()
+	executor := "test-executor"
+
+	t.Run("Should list live workers with their jobs", func(t *testing.T) {
+		_, err := queries.HeartbeatWorker(ctx, sqlc.HeartbeatWorkerParams{
+			ID:       "worker-a",
+			Executor: executor,
+			Ttl:      60,
+		})
+		assert.Nil(t, err)
+
+		_, err = resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 second",
+			Name:  "held",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(1 * time.Second)
+
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, ptrstring("worker-a"), model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 1)
+		assert.Equal(t, "worker-a", fetch[0].LockedBy.String)
+		assert.True(t, fetch[0].LockedAt.Valid)
+
+		workers, err := resolver.Query().Workers(ctx, executor)
+		assert.Nil(t, err)
+		assert.Len(t, workers, 1)
+		assert.Equal(t, "worker-a", workers[0].ID)
+
+		jobs, err := resolver.TinyWorker().Jobs(ctx, &workers[0])
+		assert.Nil(t, err)
+		assert.Len(t, jobs, 1)
+		assert.Equal(t, fetch[0].ID, jobs[0].ID)
+
+		failed, err := resolver.Mutation().CommitJobs(ctx, executor, []model.CommitArgs{{ID: fetch[0].ID, Lease: int(fetch[0].Lease)}})
+		assert.Nil(t, err)
+		assert.Len(t, failed, 0)
+
+		jobs, err = resolver.TinyWorker().Jobs(ctx, &workers[0])
+		assert.Nil(t, err)
+		assert.Len(t, jobs, 0)
+	})
+
+	t.Run("Should release jobs held by expired workers", func(t *testing.T) {
+		_, err := queries.HeartbeatWorker(ctx, sqlc.HeartbeatWorkerParams{
+			ID:       "worker-b",
+			Executor: executor,
+			Ttl:      1,
+		})
+		assert.Nil(t, err)
+
+		_, err = resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 second",
+			Name:  "orphaned",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(1 * time.Second)
+
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, ptrstring("worker-b"), model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 1)
+
+		// Live workers keep their jobs
+		ids, err := queries.ReleaseOrphanedJobs(ctx, sqlc.ReleaseOrphanedJobsParams{Executor: executor})
+		assert.Nil(t, err)
+		assert.Len(t, ids, 0)
+
+		time.Sleep(2 * time.Second)
+
+		workers, err := resolver.Query().Workers(ctx, executor)
+		assert.Nil(t, err)
+		for _, worker := range workers {
+			assert.NotEqual(t, "worker-b", worker.ID)
+		}
+
+		ids, err = queries.ReleaseOrphanedJobs(ctx, sqlc.ReleaseOrphanedJobsParams{Executor: executor})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{fetch[0].ID}, ids)
+
+		job, err := resolver.Query().QueryJobByID(ctx, executor, fetch[0].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusREADY, job.Status)
+		assert.False(t, job.LockedBy.Valid)
+	})
+
+	t.Run("Should release jobs of remote workers after their heartbeat", func(t *testing.T) {
+		worker, err := resolver.Mutation().Heartbeat(ctx, executor, "remote-worker", 1)
+		assert.Nil(t, err)
+		assert.Equal(t, "remote-worker", worker.ID)
+		assert.True(t, worker.ExpiresAt.Time.After(worker.HeartbeatAt.Time))
+
+		_, err = resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 second",
+			Name:  "remote",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(1 * time.Second)
+
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, ptrstring("remote-worker"), model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 1)
+
+		// Renewing the heartbeat keeps the job held
+		_, err = resolver.Mutation().Heartbeat(ctx, executor, "remote-worker", 2)
+		assert.Nil(t, err)
+		time.Sleep(1 * time.Second)
+		ids, err := queries.ReleaseOrphanedJobs(ctx, sqlc.ReleaseOrphanedJobsParams{Executor: executor})
+		assert.Nil(t, err)
+		assert.Len(t, ids, 0)
+
+		time.Sleep(2 * time.Second)
+		ids, err = queries.ReleaseOrphanedJobs(ctx, sqlc.ReleaseOrphanedJobsParams{Executor: executor})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{fetch[0].ID}, ids)
+	})
+
+	t.Run("Should leave jobs of unregistered workers to their timeout", func(t *testing.T) {
+		_, err := resolver.Mutation().CreateJob(ctx, executor, model.CreateJobArgs{
+			Expr:  "@after 1 second",
+			Name:  "unregistered",
+			State: "{}",
+		})
+		assert.Nil(t, err)
+
+		time.Sleep(1 * time.Second)
+
+		fetch, err := resolver.Mutation().FetchForProcessing(ctx, executor, 5, ptrstring("unregistered-worker"), model.FetchModeFifo)
+		assert.Nil(t, err)
+		assert.Len(t, fetch, 1)
+
+		ids, err := queries.ReleaseOrphanedJobs(ctx, sqlc.ReleaseOrphanedJobsParams{Executor: executor})
+		assert.Nil(t, err)
+		assert.Len(t, ids, 0)
+
+		job, err := resolver.Query().QueryJobByID(ctx, executor, fetch[0].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlc.TinyStatusPENDING, job.Status)
+	})
+
+	t.Run("Should validate heartbeat ttl", func(t *testing.T) {
+		_, err := resolver.Mutation().Heartbeat(ctx, executor, "remote-worker", 0)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,208 @@
+package qron
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/lucagez/qron/auth"
+	"github.com/lucagez/qron/graph/model"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// importChunkSize is the amount of jobs copied and
+// moved into tiny.job in a single transaction
+const importChunkSize = 50000
+
+// JobIterator streams jobs into `ImportJobs`.
+// It follows the same semantics as pgx.CopyFromSource.
+type JobIterator interface {
+	// Next advances to the next job. It returns false
+	// when there are no more jobs or an error occurred
+	Next() bool
+
+	// Job returns the current job
+	Job() (model.CreateJobArgs, error)
+
+	// Err returns any error encountered while iterating
+	Err() error
+}
+
+type sliceIterator struct {
+	jobs []model.CreateJobArgs
+	pos  int
+}
+
+// JobsFromSlice returns a JobIterator over a slice of jobs.
+func JobsFromSlice(jobs []model.CreateJobArgs) JobIterator {
+	return &sliceIterator{jobs: jobs, pos: -1}
+}
+
+func (s *sliceIterator) Next() bool {
+	s.pos++
+	return s.pos < len(s.jobs)
+}
+
+func (s *sliceIterator) Job() (model.CreateJobArgs, error) {
+	return s.jobs[s.pos], nil
+}
+
+func (s *sliceIterator) Err() error {
+	return nil
+}
+
+// importColumns are the tiny.job_import columns filled by
+// CopyImportJobs, in the order of CopyImportJobsParams
+var importColumns = []string{"expr", "name", "state", "executor", "start_at", "timeout", "meta", "owner", "retries", "deduplication_key", "concurrency_key", "concurrency_limit"}
+
+// importSource is a pgx.CopyFromSource reading at most
+// importChunkSize jobs from a JobIterator
+type importSource struct {
+	iter     JobIterator
+	executor string
+	owner    string
+	count    int
+	done     bool
+	row      sqlc.CopyImportJobsParams
+	err      error
+}
+
+func (s *importSource) Next() bool {
+	if s.count >= importChunkSize {
+		return false
+	}
+	if !s.iter.Next() {
+		s.done = true
+		return false
+	}
+	args, err := s.iter.Job()
+	if err != nil {
+		s.err = err
+		return false
+	}
+	s.row = importParams(s.executor, s.owner, args)
+	s.count++
+	return true
+}
+
+func (s *importSource) Values() ([]interface{}, error) {
+	return []interface{}{
+		s.row.Expr,
+		s.row.Name,
+		s.row.State,
+		s.row.Executor,
+		s.row.StartAt,
+		s.row.Timeout,
+		s.row.Meta,
+		s.row.Owner,
+		s.row.Retries,
+		s.row.DeduplicationKey,
+		s.row.ConcurrencyKey,
+		s.row.ConcurrencyLimit,
+	}, nil
+}
+
+func (s *importSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.iter.Err()
+}
+
+// ImportJobs bulk loads jobs using COPY into a staging table, then moves them
+// into tiny.job with a single set based insert per chunk.
+// Jobs are streamed from the iterator into COPY, at most importChunkSize
+// jobs are in flight at any time and none of them is buffered in memory.
+// Jobs whose name or deduplication key are taken by an active job are skipped.
+// Every chunk is committed separately, the returned count reflects the jobs
+// imported before an eventual error. Imported jobs are not notified
+// to jobUpdated or executorStats subscribers. Like CreateJob, callers
+// need the CREATE operation on the executor.
+func (c *Client) ImportJobs(ctx context.Context, executorName string, iter JobIterator) (int64, error) {
+	if err := c.Resolver.Authorizer.Authorize(ctx, executorName, auth.OperationCreate); err != nil {
+		return 0, err
+	}
+
+	var imported int64
+	owner := sqlc.FromCtx(ctx)
+
+	for {
+		src := &importSource{
+			iter:     iter,
+			executor: executorName,
+			owner:    owner,
+		}
+		n, err := c.importChunk(ctx, src)
+		if err != nil {
+			return imported, err
+		}
+		imported += n
+
+		if src.done {
+			return imported, nil
+		}
+	}
+}
+
+func (c *Client) importChunk(ctx context.Context, src *importSource) (int64, error) {
+	tx, err := c.Resolver.DB.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// One notification per imported job would
+	// flood subscribers on large imports
+	queries := c.Resolver.Queries.WithTx(tx)
+	if err := queries.MuteJobNotifications(ctx); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"tiny", "job_import"}, importColumns, src)
+	if err != nil {
+		return 0, err
+	}
+	if src.count == 0 {
+		return 0, nil
+	}
+
+	n, err := queries.ImportStagedJobs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit(ctx)
+}
+
+func importParams(executorName, owner string, args model.CreateJobArgs) sqlc.CopyImportJobsParams {
+	params := sqlc.CopyImportJobsParams{
+		Expr:     args.Expr,
+		Name:     args.Name,
+		State:    args.State,
+		Executor: executorName,
+		Owner:    owner,
+		Meta:     []byte("{}"),
+	}
+	if args.StartAt != nil {
+		params.StartAt = pgtype.Timestamptz{Time: *args.StartAt, Valid: true}
+	}
+	if args.Timeout != nil {
+		params.Timeout = int32(*args.Timeout)
+	}
+	if args.Meta != nil {
+		params.Meta = []byte(*args.Meta)
+	}
+	if args.Retries != nil {
+		params.Retries = int32(*args.Retries)
+	}
+	if args.DeduplicationKey != nil {
+		params.DeduplicationKey = pgtype.Text{String: *args.DeduplicationKey, Valid: true}
+	}
+	if args.ConcurrencyKey != nil {
+		params.ConcurrencyKey = pgtype.Text{String: *args.ConcurrencyKey, Valid: true}
+	}
+	if args.ConcurrencyLimit != nil {
+		params.ConcurrencyLimit = int32(*args.ConcurrencyLimit)
+	}
+	return params
+}
@@ -0,0 +1,203 @@
+package qron
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucagez/qron/sqlc"
+)
+
+// AdaptiveConfig enables an AIMD controller that adjusts `MaxInFlight`
+// based on handler latency and error rate. The limit grows by
+// `Increase` while handlers are healthy and is multiplied by
+// `Decrease` as soon as they slow down or fail too often.
+type AdaptiveConfig struct {
+	MinInFlight uint64
+	MaxInFlight uint64
+	// TargetLatency is the average time between delivering a job
+	// and its commit above which the limit shrinks
+	TargetLatency time.Duration
+	// MaxErrorRate is the ratio of failed or retried jobs
+	// above which the limit shrinks
+	MaxErrorRate float64
+	Increase     uint64
+	Decrease     float64
+	// Interval between two adjustments of the limit
+	Interval time.Duration
+}
+
+func (cfg AdaptiveConfig) withDefaults(maxInFlight uint64) AdaptiveConfig {
+	if cfg.MinInFlight == 0 {
+		cfg.MinInFlight = 1
+	}
+	if cfg.MaxInFlight == 0 {
+		cfg.MaxInFlight = 10 * maxInFlight
+	}
+	if cfg.TargetLatency == 0 {
+		cfg.TargetLatency = 1 * time.Second
+	}
+	if cfg.MaxErrorRate == 0 {
+		cfg.MaxErrorRate = 0.1
+	}
+	if cfg.Increase == 0 {
+		cfg.Increase = 1
+	}
+	if cfg.Decrease == 0 {
+		cfg.Decrease = 0.5
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	return cfg
+}
+
+type aimd struct {
+	mu         sync.Mutex
+	cfg        AdaptiveConfig
+	limit      *uint64
+	samples    int
+	errors     int
+	latency    time.Duration
+	lastAdjust time.Time
+}
+
+func newAimd(cfg AdaptiveConfig, limit *uint64) *aimd {
+	return &aimd{
+		cfg:        cfg,
+		limit:      limit,
+		lastAdjust: time.Now(),
+	}
+}
+
+func (a *aimd) observe(latency time.Duration, failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.samples += 1
+	a.latency += latency
+	if failed {
+		a.errors += 1
+	}
+
+	if time.Since(a.lastAdjust) < a.cfg.Interval {
+		return
+	}
+
+	avg := a.latency / time.Duration(a.samples)
+	errorRate := float64(a.errors) / float64(a.samples)
+	current := atomic.LoadUint64(a.limit)
+
+	next := current + a.cfg.Increase
+	if avg > a.cfg.TargetLatency || errorRate > a.cfg.MaxErrorRate {
+		next = uint64(float64(current) * a.cfg.Decrease)
+	}
+	if next < a.cfg.MinInFlight {
+		next = a.cfg.MinInFlight
+	}
+	if next > a.cfg.MaxInFlight {
+		next = a.cfg.MaxInFlight
+	}
+	atomic.StoreUint64(a.limit, next)
+
+	a.samples = 0
+	a.errors = 0
+	a.latency = 0
+	a.lastAdjust = time.Now()
+}
+
+type inFlightJob struct {
+	lease       int32
+	deliveredAt time.Time
+	expiresAt   time.Time
+	cancel      context.CancelFunc
+}
+
+// inFlight tracks jobs delivered by a fetch loop that were
+// not acknowledged yet. Jobs exceeding their timeout are not
+// counted anymore, as they are reset and fetched again.
+type inFlight struct {
+	mu         sync.Mutex
+	jobs       map[int64]inFlightJob
+	controller *aimd
+}
+
+func newInFlight(controller *aimd) *inFlight {
+	return &inFlight{
+		jobs:       map[int64]inFlightJob{},
+		controller: controller,
+	}
+}
+
+// add tracks a delivered job and returns the context handed
+// to its handler, derived from `parent`. The context is cancelled
+// once the job is acknowledged, expires or a cancel is requested.
+// A job fetched again while tracked, e.g. after a reset, cancels
+// the context of its previous delivery.
+func (f *inFlight) add(parent context.Context, job sqlc.TinyJob) context.Context {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if previous, ok := f.jobs[job.ID]; ok {
+		previous.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	now := time.Now()
+	entry := inFlightJob{lease: job.Lease, deliveredAt: now, cancel: cancel}
+	if job.Timeout > 0 {
+		entry.expiresAt = now.Add(time.Duration(job.Timeout) * time.Second)
+	}
+	f.jobs[job.ID] = entry
+	return ctx
+}
+
+func (f *inFlight) done(job Job) {
+	f.mu.Lock()
+	entry, ok := f.jobs[job.ID]
+	// Acknowledgements of previous deliveries
+	// leave the current one tracked
+	ok = ok && entry.lease == job.Lease
+	if ok {
+		delete(f.jobs, job.ID)
+	}
+	f.mu.Unlock()
+
+	if ok {
+		entry.cancel()
+	}
+	if ok && f.controller != nil {
+		f.controller.observe(time.Since(entry.deliveredAt), job.failed)
+	}
+}
+
+func (f *inFlight) outstanding() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for id, entry := range f.jobs {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			entry.cancel()
+			delete(f.jobs, id)
+		}
+	}
+	return uint64(len(f.jobs))
+}
+
+// cancel cancels the context of the tracked jobs among `ids`
+// and returns the ones that were found.
+func (f *inFlight) cancel(ids []int64) []int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var cancelled []int64
+	for _, id := range ids {
+		if entry, ok := f.jobs[id]; ok {
+			entry.cancel()
+			cancelled = append(cancelled, id)
+		}
+	}
+	return cancelled
+}
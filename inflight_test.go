@@ -0,0 +1,102 @@
+package qron
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucagez/qron/sqlc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInFlight(t *testing.T) {
+	t.Run("Should count jobs until acknowledged", func(t *testing.T) {
+		inflight := newInFlight(nil)
+		inflight.add(context.Background(), sqlc.TinyJob{ID: 1, Timeout: 120})
+		inflight.add(context.Background(), sqlc.TinyJob{ID: 2, Timeout: 120})
+		assert.Equal(t, uint64(2), inflight.outstanding())
+
+		inflight.done(Job{TinyJob: sqlc.TinyJob{ID: 1}})
+		assert.Equal(t, uint64(1), inflight.outstanding())
+	})
+
+	t.Run("Should stop counting jobs after timeout", func(t *testing.T) {
+		inflight := newInFlight(nil)
+		inflight.add(context.Background(), sqlc.TinyJob{ID: 1, Timeout: 1})
+		inflight.add(context.Background(), sqlc.TinyJob{ID: 2})
+		assert.Equal(t, uint64(2), inflight.outstanding())
+
+		time.Sleep(1100 * time.Millisecond)
+		assert.Equal(t, uint64(1), inflight.outstanding())
+	})
+
+	t.Run("Should cancel context of requested jobs", func(t *testing.T) {
+		inflight := newInFlight(nil)
+		first := inflight.add(context.Background(), sqlc.TinyJob{ID: 1})
+		second := inflight.add(context.Background(), sqlc.TinyJob{ID: 2})
+
+		assert.Equal(t, []int64{1}, inflight.cancel([]int64{1, 3}))
+		assert.ErrorIs(t, first.Err(), context.Canceled)
+		assert.Nil(t, second.Err())
+
+		inflight.done(Job{TinyJob: sqlc.TinyJob{ID: 2}})
+		assert.ErrorIs(t, second.Err(), context.Canceled)
+	})
+
+	t.Run("Should cancel previous delivery of jobs fetched again", func(t *testing.T) {
+		inflight := newInFlight(nil)
+		first := inflight.add(context.Background(), sqlc.TinyJob{ID: 1, Lease: 1})
+		second := inflight.add(context.Background(), sqlc.TinyJob{ID: 1, Lease: 2})
+		assert.ErrorIs(t, first.Err(), context.Canceled)
+		assert.Nil(t, second.Err())
+		assert.Equal(t, uint64(1), inflight.outstanding())
+
+		inflight.done(Job{TinyJob: sqlc.TinyJob{ID: 1, Lease: 1}})
+		assert.Nil(t, second.Err())
+		assert.Equal(t, uint64(1), inflight.outstanding())
+
+		inflight.done(Job{TinyJob: sqlc.TinyJob{ID: 1, Lease: 2}})
+		assert.ErrorIs(t, second.Err(), context.Canceled)
+		assert.Equal(t, uint64(0), inflight.outstanding())
+	})
+}
+
+func TestAimd(t *testing.T) {
+	cfg := AdaptiveConfig{
+		MinInFlight:   2,
+		MaxInFlight:   12,
+		TargetLatency: 100 * time.Millisecond,
+		MaxErrorRate:  0.5,
+		Increase:      1,
+		Decrease:      0.5,
+		// Adjust on every observation
+		Interval: time.Nanosecond,
+	}
+
+	t.Run("Should grow limit additively while healthy", func(t *testing.T) {
+		limit := uint64(10)
+		controller := newAimd(cfg, &limit)
+		controller.observe(10*time.Millisecond, false)
+		assert.Equal(t, uint64(11), limit)
+		controller.observe(10*time.Millisecond, false)
+		controller.observe(10*time.Millisecond, false)
+		assert.Equal(t, uint64(12), limit)
+	})
+
+	t.Run("Should shrink limit multiplicatively when slow", func(t *testing.T) {
+		limit := uint64(10)
+		controller := newAimd(cfg, &limit)
+		controller.observe(time.Second, false)
+		assert.Equal(t, uint64(5), limit)
+		controller.observe(time.Second, false)
+		controller.observe(time.Second, false)
+		assert.Equal(t, uint64(2), limit)
+	})
+
+	t.Run("Should shrink limit on errors", func(t *testing.T) {
+		limit := uint64(10)
+		controller := newAimd(cfg, &limit)
+		controller.observe(10*time.Millisecond, true)
+		assert.Equal(t, uint64(5), limit)
+	})
+}
@@ -0,0 +1,169 @@
+package qron
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lucagez/qron/sqlc"
+)
+
+// Leader elects a single client among the ones sharing the
+// database. Leadership is a session level advisory lock held
+// on a dedicated connection, so it is lost as soon as the
+// connection drops or the process dies.
+type Leader struct {
+	Name     string
+	db       *pgxpool.Pool
+	interval time.Duration
+	logger   *slog.Logger
+	// conn is only used by the campaign goroutine
+	conn      *pgx.Conn
+	mu        sync.Mutex
+	leader    bool
+	onElected []func()
+	onLost    []func()
+}
+
+func newLeader(db *pgxpool.Pool, name string, interval time.Duration, logger *slog.Logger) *Leader {
+	return &Leader{
+		Name:     name,
+		db:       db,
+		interval: interval,
+		logger:   logger.With("leader", name),
+	}
+}
+
+// IsLeader reports whether the client currently holds leadership.
+func (l *Leader) IsLeader() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.leader
+}
+
+// OnElected registers fn to be called every time leadership is
+// acquired. fn is called right away when already leading.
+func (l *Leader) OnElected(fn func()) {
+	l.mu.Lock()
+	l.onElected = append(l.onElected, fn)
+	leader := l.leader
+	l.mu.Unlock()
+
+	if leader {
+		fn()
+	}
+}
+
+// OnLost registers fn to be called every time leadership is
+// lost, including when the context of the campaign is done.
+func (l *Leader) OnLost(fn func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onLost = append(l.onLost, fn)
+}
+
+func (l *Leader) run(ctx context.Context) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.resign()
+			return
+		case <-ticker.C:
+			if l.IsLeader() {
+				l.renew(ctx)
+			} else {
+				l.campaign(ctx)
+			}
+		}
+	}
+}
+
+// campaign tries to acquire the lock without waiting
+// for the current leader to step down
+func (l *Leader) campaign(ctx context.Context) {
+	if l.conn == nil {
+		conn, err := pgx.ConnectConfig(ctx, l.db.Config().ConnConfig.Copy())
+		if err != nil {
+			l.logger.Error("error while connecting for leadership", "error", err)
+			return
+		}
+		l.conn = conn
+	}
+
+	locked, err := sqlc.New(l.conn).TryAdvisoryLock(ctx, l.Name)
+	if err != nil {
+		l.close()
+		l.logger.Error("error while acquiring leadership", "error", err)
+		return
+	}
+	if !locked {
+		return
+	}
+
+	l.mu.Lock()
+	l.leader = true
+	callbacks := l.onElected
+	l.mu.Unlock()
+
+	l.logger.Info("elected")
+	for _, fn := range callbacks {
+		fn()
+	}
+}
+
+// renew makes sure the session holding the lock is still alive.
+// The lock is lost together with the connection
+func (l *Leader) renew(ctx context.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx, l.interval)
+	err := l.conn.Ping(pingCtx)
+	cancel()
+
+	if err != nil && ctx.Err() == nil {
+		l.logger.Error("error while renewing leadership", "error", err)
+		l.lose()
+	}
+}
+
+func (l *Leader) resign() {
+	if l.conn != nil && l.IsLeader() {
+		_, err := sqlc.New(l.conn).AdvisoryUnlock(context.Background(), l.Name)
+		if err != nil {
+			l.logger.Error("error while releasing leadership", "error", err)
+		}
+	}
+	l.lose()
+}
+
+func (l *Leader) lose() {
+	l.close()
+
+	l.mu.Lock()
+	wasLeader := l.leader
+	l.leader = false
+	callbacks := l.onLost
+	l.mu.Unlock()
+
+	if !wasLeader {
+		return
+	}
+
+	l.logger.Info("lost leadership")
+	for _, fn := range callbacks {
+		fn()
+	}
+}
+
+// close drops the connection and with it any lock it holds
+func (l *Leader) close() {
+	if l.conn == nil {
+		return
+	}
+	l.conn.Close(context.Background())
+	l.conn = nil
+}
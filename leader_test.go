@@ -0,0 +1,58 @@
+package qron
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lucagez/qron/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeader(t *testing.T) {
+	pool, cleanup := testutil.PG.CreateDb("leader")
+	defer cleanup()
+
+	client, err := NewClient(pool, Config{
+		LeaderInterval: 50 * time.Millisecond,
+	})
+	assert.Nil(t, err)
+
+	t.Run("Should elect a single leader", func(t *testing.T) {
+		ctxA, stopA := context.WithCancel(context.Background())
+		defer stopA()
+		ctxB, stopB := context.WithCancel(context.Background())
+		defer stopB()
+
+		a := client.Leader(ctxA, "nightly")
+		b := client.Leader(ctxB, "nightly")
+		assert.True(t, a.IsLeader())
+		assert.False(t, b.IsLeader())
+
+		var lost, elected int32
+		a.OnLost(func() { atomic.AddInt32(&lost, 1) })
+		b.OnElected(func() { atomic.AddInt32(&elected, 1) })
+
+		time.Sleep(200 * time.Millisecond)
+		assert.True(t, a.IsLeader())
+		assert.False(t, b.IsLeader())
+
+		stopA()
+		time.Sleep(200 * time.Millisecond)
+		assert.False(t, a.IsLeader())
+		assert.True(t, b.IsLeader())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&lost))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&elected))
+	})
+
+	t.Run("Should lead different names independently", func(t *testing.T) {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+
+		a := client.Leader(ctx, "reconcile")
+		b := client.Leader(ctx, "report")
+		assert.True(t, a.IsLeader())
+		assert.True(t, b.IsLeader())
+	})
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"time"
+)
+
+// Recorder receives measurements from the fetch, flush and
+// reset loops of a client. Implementations must be safe for
+// concurrent use. Recorders implementing http.Handler are
+// exposed on `/metrics` by the client handler.
+type Recorder interface {
+	JobsFetched(executor string, amount int)
+	JobsCommitted(executor string, amount int)
+	JobsFailed(executor string, amount int)
+	JobsRetried(executor string, amount int)
+	// JobsReset counts jobs taken back after a timeout
+	// or after their worker stopped heartbeating
+	JobsReset(executor string, amount int)
+	FetchLatency(executor string, latency time.Duration)
+	FlushSize(executor string, size int)
+	// Lag is the delay between the scheduled run of a job
+	// and the moment it was fetched
+	Lag(executor string, lag time.Duration)
+	QueueDepth(executor, status string, depth int64)
+}
+
+// Noop discards every measurement
+type Noop struct{}
+
+func (Noop) JobsFetched(string, int)            {}
+func (Noop) JobsCommitted(string, int)          {}
+func (Noop) JobsFailed(string, int)             {}
+func (Noop) JobsRetried(string, int)            {}
+func (Noop) JobsReset(string, int)              {}
+func (Noop) FetchLatency(string, time.Duration) {}
+func (Noop) FlushSize(string, int)              {}
+func (Noop) Lag(string, time.Duration)          {}
+func (Noop) QueueDepth(string, string, int64)   {}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus records measurements as prometheus collectors
+// labelled by executor.
+type Prometheus struct {
+	jobs         *prometheus.CounterVec
+	fetchLatency *prometheus.HistogramVec
+	flushSize    *prometheus.HistogramVec
+	lag          *prometheus.HistogramVec
+	queueDepth   *prometheus.GaugeVec
+	handler      http.Handler
+}
+
+// NewPrometheus registers the qron collectors on `registry`.
+// A dedicated registry is used when nil.
+func NewPrometheus(registry *prometheus.Registry) *Prometheus {
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+
+	p := &Prometheus{
+		jobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "qron",
+			Name:      "jobs_total",
+			Help:      "Jobs fetched, committed, failed, retried or reset.",
+		}, []string{"executor", "outcome"}),
+		fetchLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "qron",
+			Name:      "fetch_duration_seconds",
+			Help:      "Time spent fetching due jobs.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"executor"}),
+		flushSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "qron",
+			Name:      "flush_size",
+			Help:      "Jobs acknowledged in a single flush.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+		}, []string{"executor"}),
+		lag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "qron",
+			Name:      "lag_seconds",
+			Help:      "Delay between the scheduled run of a job and its fetch.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 14),
+		}, []string{"executor"}),
+		queueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "qron",
+			Name:      "queue_depth",
+			Help:      "Jobs per status.",
+		}, []string{"executor", "status"}),
+		handler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+	}
+
+	registry.MustRegister(
+		p.jobs,
+		p.fetchLatency,
+		p.flushSize,
+		p.lag,
+		p.queueDepth,
+	)
+	return p
+}
+
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.handler.ServeHTTP(w, r)
+}
+
+func (p *Prometheus) JobsFetched(executor string, amount int) {
+	p.jobs.WithLabelValues(executor, "fetched").Add(float64(amount))
+}
+
+func (p *Prometheus) JobsCommitted(executor string, amount int) {
+	p.jobs.WithLabelValues(executor, "committed").Add(float64(amount))
+}
+
+func (p *Prometheus) JobsFailed(executor string, amount int) {
+	p.jobs.WithLabelValues(executor, "failed").Add(float64(amount))
+}
+
+func (p *Prometheus) JobsRetried(executor string, amount int) {
+	p.jobs.WithLabelValues(executor, "retried").Add(float64(amount))
+}
+
+func (p *Prometheus) JobsReset(executor string, amount int) {
+	p.jobs.WithLabelValues(executor, "reset").Add(float64(amount))
+}
+
+func (p *Prometheus) FetchLatency(executor string, latency time.Duration) {
+	p.fetchLatency.WithLabelValues(executor).Observe(latency.Seconds())
+}
+
+func (p *Prometheus) FlushSize(executor string, size int) {
+	p.flushSize.WithLabelValues(executor).Observe(float64(size))
+}
+
+func (p *Prometheus) Lag(executor string, lag time.Duration) {
+	p.lag.WithLabelValues(executor).Observe(lag.Seconds())
+}
+
+func (p *Prometheus) QueueDepth(executor, status string, depth int64) {
+	p.queueDepth.WithLabelValues(executor, status).Set(float64(depth))
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheus(t *testing.T) {
+	recorder := NewPrometheus(nil)
+
+	recorder.JobsFetched("backup", 3)
+	recorder.JobsCommitted("backup", 2)
+	recorder.JobsFailed("backup", 1)
+	recorder.JobsReset("email", 4)
+	recorder.FetchLatency("backup", 20*time.Millisecond)
+	recorder.FlushSize("backup", 3)
+	recorder.Lag("backup", 2*time.Second)
+	recorder.QueueDepth("backup", "READY", 10)
+
+	res := httptest.NewRecorder()
+	recorder.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(res.Body)
+	assert.Nil(t, err)
+
+	out := string(body)
+	assert.Contains(t, out, `qron_jobs_total{executor="backup",outcome="fetched"} 3`)
+	assert.Contains(t, out, `qron_jobs_total{executor="backup",outcome="committed"} 2`)
+	assert.Contains(t, out, `qron_jobs_total{executor="backup",outcome="failed"} 1`)
+	assert.Contains(t, out, `qron_jobs_total{executor="email",outcome="reset"} 4`)
+	assert.Contains(t, out, `qron_fetch_duration_seconds_count{executor="backup"} 1`)
+	assert.Contains(t, out, `qron_flush_size_sum{executor="backup"} 3`)
+	assert.Contains(t, out, `qron_lag_seconds_sum{executor="backup"} 2`)
+	assert.Contains(t, out, `qron_queue_depth{executor="backup",status="READY"} 10`)
+}
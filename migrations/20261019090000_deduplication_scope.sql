@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table tiny.job drop constraint job_deduplication_key_constraint;
+drop index tiny.job_deduplication_key_idx;
+
+-- Deduplication keys are scoped by owner and executor.
+-- Only active jobs are unique, so keys can be reused after a job finishes
+create unique index job_deduplication_key_constraint
+  on tiny.job (owner, executor, deduplication_key)
+  where status in ('READY', 'PENDING', 'PAUSED');
+
+create index job_deduplication_key_idx on tiny.job (owner, executor, deduplication_key);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index tiny.job_deduplication_key_idx;
+drop index tiny.job_deduplication_key_constraint;
+
+alter table tiny.job add constraint job_deduplication_key_constraint unique (deduplication_key);
+create index job_deduplication_key_idx on tiny.job (deduplication_key);
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Staging table for bulk imports. Rows are copied and moved
+-- into tiny.job within the same transaction, so they are never
+-- visible to other sessions and wal is not needed
+create unlogged table tiny.job_import
+(
+    expr              text not null,
+    name              text not null default '',
+    state             text not null default '',
+    executor          text not null,
+    start_at          timestamptz,
+    timeout           integer not null default 0,
+    meta              json not null default '{}',
+    owner             text not null default '',
+    retries           integer not null default 0,
+    deduplication_key text
+);
+
+grant all on tiny.job_import to tinyrole;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table tiny.job_import;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Jobs sharing a concurrency key are never running more
+-- than `concurrency_limit` at the same time, across executors
+alter table tiny.job add column concurrency_key text;
+alter table tiny.job add column concurrency_limit integer not null default 1;
+alter table tiny.job add constraint positive_concurrency_limit check (concurrency_limit > 0);
+
+create index job_concurrency_key_idx
+  on tiny.job (owner, concurrency_key, status, created_at)
+  where concurrency_key is not null;
+
+alter table tiny.job_import add column concurrency_key text;
+alter table tiny.job_import add column concurrency_limit integer not null default 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table tiny.job_import drop column concurrency_limit;
+alter table tiny.job_import drop column concurrency_key;
+
+drop index tiny.job_concurrency_key_idx;
+alter table tiny.job drop column concurrency_limit;
+alter table tiny.job drop column concurrency_key;
+-- +goose StatementEnd
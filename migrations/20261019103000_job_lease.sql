@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Fencing token bumped every time a job is handed to a worker
+-- or taken back from it. Commits carrying a stale lease are rejected
+alter table tiny.job add column lease integer not null default 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table tiny.job drop column lease;
+-- +goose StatementEnd
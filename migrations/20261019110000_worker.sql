@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table tiny.job add column locked_by text;
+alter table tiny.job add column locked_at timestamptz;
+
+create index job_locked_by_idx on tiny.job (executor, locked_by) where status = 'PENDING';
+
+-- Registry of running clients. A worker is considered
+-- dead as soon as it stops heartbeating past `expires_at`
+create table if not exists tiny.worker (
+  id text not null,
+  executor text not null,
+  started_at timestamptz not null default now(),
+  heartbeat_at timestamptz not null default now(),
+  expires_at timestamptz not null,
+  primary key (id, executor)
+);
+
+grant all on tiny.worker to tinyrole;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table tiny.worker;
+drop index tiny.job_locked_by_idx;
+alter table tiny.job drop column locked_at;
+alter table tiny.job drop column locked_by;
+-- +goose StatementEnd
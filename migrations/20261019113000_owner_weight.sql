@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Share of due jobs handed to each owner by fair fetches.
+-- Owners without a row have a weight of 1
+create table if not exists tiny.owner_weight (
+  owner text primary key,
+  weight integer not null default 1 check (weight > 0)
+);
+
+grant select on tiny.owner_weight to tinyrole;
+
+create index job_owner_due_idx on tiny.job (executor, owner, created_at) where status = 'READY';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index tiny.job_owner_due_idx;
+drop table tiny.owner_weight;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Token buckets shared by every client fetching from an executor.
+-- An empty owner limits the executor as a whole
+create table if not exists tiny.rate_limit (
+  executor text not null,
+  owner text not null default '',
+  -- tokens added per second
+  rate float8 not null check (rate > 0),
+  burst integer not null check (burst > 0),
+  tokens float8 not null,
+  updated_at timestamptz not null default now(),
+  primary key (executor, owner)
+);
+
+grant all on tiny.rate_limit to tinyrole;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table tiny.rate_limit;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Executor level switches. Jobs of a paused executor
+-- stay READY and are not fetched until it is resumed
+create table if not exists tiny.executor (
+  name text primary key,
+  paused boolean not null default false,
+  paused_at timestamptz,
+  updated_at timestamptz not null default now()
+);
+
+grant all on tiny.executor to tinyrole;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table tiny.executor;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- +goose StatementBegin
+alter type tiny.status add value if not exists 'CANCELLED';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Running jobs cannot be interrupted from the database. A cancel
+-- request is recorded and the job becomes CANCELLED as soon as
+-- the worker holding it reports back or loses it
+alter table tiny.job add column cancel_requested_at timestamptz;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Enum values cannot be dropped. CANCELLED is left in place
+alter table tiny.job drop column cancel_requested_at;
+-- +goose StatementEnd
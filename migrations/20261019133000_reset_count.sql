@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Consecutive resets of a job that timed out or whose
+-- worker died. Cleared as soon as the job is acknowledged
+alter table tiny.job add column reset_count integer not null default 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table tiny.job drop column reset_count;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Jobs reset too many times in a row are moved to FAILURE
+-- and flagged as quarantined until explicitly requeued
+alter table tiny.job add column quarantined_at timestamptz;
+
+create index job_quarantined_idx on tiny.job (executor, quarantined_at) where quarantined_at is not null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index tiny.job_quarantined_idx;
+alter table tiny.job drop column quarantined_at;
+-- +goose StatementEnd
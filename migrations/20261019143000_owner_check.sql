@@ -0,0 +1,36 @@
+-- Once a connection has set `tiny.owner`, resetting it leaves the
+-- setting defined as an empty string. The original policy compared
+-- owners against that empty string, so a scoped connection acquired
+-- without an owner silently matched no rows. The policy now raises
+-- instead, making a missing owner an error for the caller.
+
+-- +goose Up
+-- +goose StatementBegin
+create or replace function tiny.current_owner() returns text as $$
+declare
+  owner text := current_setting('tiny.owner', true);
+begin
+  if owner is null or owner = '' then
+    raise exception 'no owner in context';
+  end if;
+  return owner;
+end
+$$ language plpgsql stable;
+
+drop policy job_policy on tiny.job;
+create policy job_policy on tiny.job
+    for all
+    using ((select tiny.current_owner()) = owner)
+   	with check ((select tiny.current_owner()) = owner);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop policy job_policy on tiny.job;
+create policy job_policy on tiny.job
+    for all
+    using (current_setting('tiny.owner') = owner)
+   	with check (current_setting('tiny.owner') = owner);
+
+drop function tiny.current_owner;
+-- +goose StatementEnd
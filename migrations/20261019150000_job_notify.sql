@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+create or replace function tiny.notify_job() returns trigger as $$
+declare
+  job tiny.job;
+begin
+  if tg_op = 'DELETE' then
+    job := old;
+  else
+    job := new;
+  end if;
+
+  -- payload is kept small as notifications are limited
+  -- to 8000 bytes. Listeners query the job when needed
+  perform pg_notify('qron_job', json_build_object(
+    'id', job.id,
+    'executor', job.executor,
+    'owner', job.owner,
+    'op', tg_op
+  )::text);
+
+  return null;
+end
+$$ language plpgsql;
+
+-- Bulk imports set `tiny.mute_notify` in their transaction,
+-- so copying large files doesn't flood listeners
+create trigger job_notify_insert
+after insert on tiny.job
+for each row
+when (current_setting('tiny.mute_notify', true) is distinct from 'on')
+execute function tiny.notify_job();
+
+-- Only status changes are notified, other writes like
+-- heartbeats or state updates would be noise
+create trigger job_notify_update
+after update on tiny.job
+for each row
+when (old.status is distinct from new.status)
+execute function tiny.notify_job();
+
+create trigger job_notify_delete
+after delete on tiny.job
+for each row execute function tiny.notify_job();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop trigger job_notify_insert on tiny.job;
+drop trigger job_notify_update on tiny.job;
+drop trigger job_notify_delete on tiny.job;
+drop function tiny.notify_job;
+-- +goose StatementEnd
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+-- History of finished runs. Jobs only keep their latest
+-- run, so durations and throughput are computed from here
+create table if not exists tiny.job_run (
+  id bigserial primary key,
+  job_id bigint not null,
+  executor text not null,
+  owner text not null,
+  status tiny.status not null,
+  started_at timestamptz not null,
+  finished_at timestamptz not null default now()
+);
+
+create index job_run_executor_finished_idx on tiny.job_run (executor, finished_at);
+
+grant all on tiny.job_run to tinyrole;
+grant usage, select on sequence tiny.job_run_id_seq to tinyrole;
+
+alter table tiny.job_run enable row level security;
+create policy job_run_policy on tiny.job_run
+    for all
+    using ((select tiny.current_owner()) = owner)
+   	with check ((select tiny.current_owner()) = owner);
+
+-- A run is finished as soon as a PENDING job is
+-- committed, failed, retried, reset or released
+create or replace function tiny.record_job_run() returns trigger as $$
+begin
+  insert into tiny.job_run (job_id, executor, owner, status, started_at)
+  values (old.id, old.executor, old.owner, new.status, old.last_run_at);
+  return null;
+end
+$$ language plpgsql;
+
+create trigger job_run_record
+after update on tiny.job
+for each row
+when (old.status = 'PENDING' and new.status <> 'PENDING')
+execute function tiny.record_job_run();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop trigger job_run_record on tiny.job;
+drop function tiny.record_job_run;
+drop table tiny.job_run;
+-- +goose StatementEnd
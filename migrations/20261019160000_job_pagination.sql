@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Keyset pagination indexes, one for every sort option
+create index job_executor_id_idx on tiny.job (executor, id);
+create index job_executor_run_at_idx on tiny.job (executor, run_at, id);
+create index job_executor_updated_at_idx on tiny.job (executor, updated_at, id);
+
+-- Serves both containment and jsonpath filters on meta
+create index job_meta_idx on tiny.job using gin ((meta::jsonb) jsonb_path_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index tiny.job_meta_idx;
+drop index tiny.job_executor_updated_at_idx;
+drop index tiny.job_executor_run_at_idx;
+drop index tiny.job_executor_id_idx;
+-- +goose StatementEnd
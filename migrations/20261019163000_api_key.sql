@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Keys are looked up by hash before the owner is known,
+-- so the table is not subject to RLS. Queries filter by owner
+create table if not exists tiny.api_key (
+  id bigserial primary key,
+  name text not null,
+  -- first characters of the key, to tell keys apart
+  prefix text not null,
+  hash text not null unique,
+  owner text not null,
+  scopes text[] not null check (scopes <@ array['READ', 'WRITE', 'ADMIN']),
+  -- executors the key is restricted to. Empty means any
+  executors text[] not null default '{}',
+  created_at timestamptz not null default now(),
+  expires_at timestamptz,
+  revoked_at timestamptz
+);
+
+create index api_key_owner_idx on tiny.api_key (owner);
+
+grant all on tiny.api_key to tinyrole;
+grant usage, select on sequence tiny.api_key_id_seq to tinyrole;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table tiny.api_key;
+-- +goose StatementEnd
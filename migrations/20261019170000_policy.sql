@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Grants of roles on executors and operations. Principals with roles
+-- are only allowed what their roles are granted. '*' matches any
+-- executor or operation
+create table if not exists tiny.policy (
+  id bigserial primary key,
+  owner text not null,
+  role text not null,
+  executor text not null,
+  operation text not null check (
+    operation in ('*', 'READ', 'CREATE', 'UPDATE', 'DELETE', 'PROCESS', 'MANAGE', 'ADMIN')
+  ),
+  created_at timestamptz not null default now(),
+  unique (owner, role, executor, operation)
+);
+
+grant all on tiny.policy to tinyrole;
+grant usage, select on sequence tiny.policy_id_seq to tinyrole;
+
+alter table tiny.policy enable row level security;
+create policy policy_policy on tiny.policy
+    for all
+    using ((select tiny.current_owner()) = owner)
+   	with check ((select tiny.current_owner()) = owner);
+
+-- Denied operations
+create table if not exists tiny.audit_log (
+  id bigserial primary key,
+  owner text not null,
+  -- key id or JWT subject of the denied principal
+  subject text not null,
+  roles text[] not null default '{}',
+  executor text not null,
+  operation text not null,
+  reason text not null,
+  created_at timestamptz not null default now()
+);
+
+create index audit_log_owner_created_idx on tiny.audit_log (owner, created_at);
+
+grant all on tiny.audit_log to tinyrole;
+grant usage, select on sequence tiny.audit_log_id_seq to tinyrole;
+
+alter table tiny.audit_log enable row level security;
+create policy audit_log_policy on tiny.audit_log
+    for all
+    using ((select tiny.current_owner()) = owner)
+   	with check ((select tiny.current_owner()) = owner);
+
+alter table tiny.api_key add column roles text[] not null default '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table tiny.api_key drop column roles;
+drop table tiny.audit_log;
+drop table tiny.policy;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- The role is shared by every database of the cluster, so the grants
+-- of 20221231110809_rls.sql are skipped on all databases but the one
+-- creating it. They are repeated here as they are idempotent.
+
+-- +goose Up
+-- +goose StatementBegin
+grant usage on schema tiny to tinyrole;
+grant usage on schema cronexp to tinyrole;
+grant usage, select on sequence tiny.job_id_seq to tinyrole;
+grant all on tiny.job to tinyrole;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Grants are left in place, other databases may rely on them
@@ -41,13 +41,35 @@ and executor = $2
 and status not in ('FAILURE', 'SUCCESS', 'PENDING')
 returning *;
 
+-- name: CancelJob :one
+update tiny.job
+set status = case
+    when status = 'PENDING' then status
+    else 'CANCELLED'::tiny.status
+  end,
+  cancel_requested_at = now(),
+  updated_at = now()
+where id = $1
+and executor = $2
+-- Running jobs are only flagged. The worker holding
+-- them is notified on its next heartbeat
+and status in ('READY', 'PAUSED', 'PENDING')
+returning *;
+
 -- name: RestartJob :one
+-- Restarting a cancelled job, or a running one whose cancel
+-- was not acknowledged yet, withdraws the cancellation
 update tiny.job
-set status = 'READY',
-  updated_at = now() 
+set status = case
+    when status = 'PENDING' then status
+    else 'READY'::tiny.status
+  end,
+  cancel_requested_at = null,
+  updated_at = now()
 where id = $1
 and executor = $2
-and status = 'PAUSED'
+and (status in ('PAUSED', 'CANCELLED')
+  or (status = 'PENDING' and cancel_requested_at is not null))
 returning *;
 
 -- name: UpdateJobByID :one
@@ -100,41 +122,191 @@ and executor = $2
 returning *;
 
 -- name: CreateJob :one
-insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key)
-values (
-  sqlc.arg('expr'),
-  coalesce(nullif(sqlc.arg('name'), ''), substr(md5(random()::text), 0, 25)),
-  sqlc.arg('state'),
+insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key, concurrency_key, concurrency_limit)
+select
+  sqlc.arg('expr')::text,
+  coalesce(nullif(sqlc.arg('name')::text, ''), substr(md5(random()::text), 0, 25)),
+  sqlc.arg('state')::text,
   'READY',
-  sqlc.arg('executor'),
-  tiny.next(greatest(sqlc.arg('start_at'), now()), sqlc.arg('expr')),
-  coalesce(nullif(sqlc.arg('timeout'), 0), 120),
-  sqlc.arg('start_at'),
-  sqlc.arg('meta'),
-  coalesce(nullif(sqlc.arg('owner'), ''), 'default'),
-  coalesce(nullif(sqlc.arg('retries'), 0), 5),
-  sqlc.arg('deduplication_key')
+  sqlc.arg('executor')::text,
+  tiny.next(greatest(sqlc.arg('start_at')::timestamptz, now()), sqlc.arg('expr')::text),
+  coalesce(nullif(sqlc.arg('timeout')::int, 0), 120),
+  sqlc.arg('start_at')::timestamptz,
+  sqlc.arg('meta')::json,
+  coalesce(nullif(sqlc.arg('owner')::text, ''), 'default'),
+  coalesce(nullif(sqlc.arg('retries')::int, 0), 5),
+  sqlc.narg('deduplication_key')::text,
+  sqlc.narg('concurrency_key')::text,
+  coalesce(nullif(sqlc.arg('concurrency_limit')::int, 0), 1)
+-- A duplicate is an active job with the same key or one
+-- that finished within the deduplication window
+where not exists (
+  select 1 from tiny.job
+  where deduplication_key = sqlc.narg('deduplication_key')::text
+  and owner = coalesce(nullif(sqlc.arg('owner')::text, ''), 'default')
+  and executor = sqlc.arg('executor')::text
+  and (status in ('READY', 'PENDING', 'PAUSED')
+    or updated_at > now() - make_interval(mins => sqlc.narg('deduplication_window')::int))
 )
--- on conflict on constraint job_name_owner_key
--- do ...
+on conflict (owner, executor, deduplication_key)
+where status in ('READY', 'PENDING', 'PAUSED')
+do nothing
 returning *;
 
--- name: BatchCreateJobs :batchexec
-insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key)
-values (
-  sqlc.arg('expr'),
-  coalesce(nullif(sqlc.arg('name'), ''), substr(md5(random()::text), 0, 25)),
-  sqlc.arg('state'),
+-- name: GetDuplicatedJob :one
+select * from tiny.job
+where deduplication_key = sqlc.arg('deduplication_key')::text
+and owner = coalesce(nullif(sqlc.arg('owner')::text, ''), 'default')
+and executor = sqlc.arg('executor')::text
+and (status in ('READY', 'PENDING', 'PAUSED')
+  or updated_at > now() - make_interval(mins => sqlc.narg('deduplication_window')::int))
+order by id desc
+limit 1;
+
+-- name: CreateOrUpdateJob :one
+insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key, concurrency_key, concurrency_limit)
+select
+  sqlc.arg('expr')::text,
+  sqlc.arg('name')::text,
+  sqlc.arg('state')::text,
   'READY',
-  sqlc.arg('executor'),
-  tiny.next(greatest(sqlc.arg('start_at'), now()), sqlc.arg('expr')),
-  coalesce(nullif(sqlc.arg('timeout'), 0), 120),
-  sqlc.arg('start_at'),
-  sqlc.arg('meta'),
-  coalesce(nullif(sqlc.arg('owner'), ''), 'default'),
-  coalesce(nullif(sqlc.arg('retries'), 0), 5),
-  sqlc.arg('deduplication_key')
-);
+  sqlc.arg('executor')::text,
+  tiny.next(greatest(sqlc.arg('start_at')::timestamptz, now()), sqlc.arg('expr')::text),
+  coalesce(nullif(sqlc.arg('timeout')::int, 0), 120),
+  sqlc.arg('start_at')::timestamptz,
+  sqlc.arg('meta')::json,
+  coalesce(nullif(sqlc.arg('owner')::text, ''), 'default'),
+  coalesce(nullif(sqlc.arg('retries')::int, 0), 5),
+  sqlc.narg('deduplication_key')::text,
+  sqlc.narg('concurrency_key')::text,
+  coalesce(nullif(sqlc.arg('concurrency_limit')::int, 0), 1)
+-- Jobs that finished within the deduplication window still hold
+-- their key. Active ones are rejected by the unique index
+where not exists (
+  select 1 from tiny.job
+  where deduplication_key = sqlc.narg('deduplication_key')::text
+  and owner = coalesce(nullif(sqlc.arg('owner')::text, ''), 'default')
+  and executor = sqlc.arg('executor')::text
+  and name <> sqlc.arg('name')::text
+  and updated_at > now() - make_interval(mins => sqlc.narg('deduplication_window')::int)
+)
+on conflict on constraint job_name_owner_key
+do update set
+  expr = case
+    when sqlc.arg('mode')::text = 'KEEP_EXISTING' then tiny.job.expr
+    else excluded.expr
+  end,
+  meta = case
+    when sqlc.arg('mode')::text = 'REPLACE' then excluded.meta
+    else tiny.job.meta
+  end,
+  state = case
+    when sqlc.arg('mode')::text = 'REPLACE' then excluded.state
+    else tiny.job.state
+  end,
+  concurrency_key = case
+    when sqlc.arg('mode')::text = 'KEEP_EXISTING' then tiny.job.concurrency_key
+    else excluded.concurrency_key
+  end,
+  concurrency_limit = case
+    when sqlc.arg('mode')::text = 'KEEP_EXISTING' then tiny.job.concurrency_limit
+    else excluded.concurrency_limit
+  end,
+  timeout = case
+    when sqlc.arg('mode')::text = 'KEEP_EXISTING' then tiny.job.timeout
+    else excluded.timeout
+  end,
+  retries = case
+    when sqlc.arg('mode')::text = 'KEEP_EXISTING' then tiny.job.retries
+    else excluded.retries
+  end,
+  -- Finished jobs are made runnable again, as if newly created
+  status = case
+    when sqlc.arg('mode')::text <> 'KEEP_EXISTING' and tiny.job.status in ('SUCCESS', 'FAILURE') then 'READY'
+    else tiny.job.status
+  end,
+  updated_at = case
+    when sqlc.arg('mode')::text = 'KEEP_EXISTING' then tiny.job.updated_at
+    else now()
+  end,
+  -- `run_at` is recomputed only when the schedule actually changes,
+  -- so re-registering the same job does not shift its next execution
+  run_at = case
+    when sqlc.arg('mode')::text = 'KEEP_EXISTING' then tiny.job.run_at
+    when tiny.job.status in ('SUCCESS', 'FAILURE') then excluded.run_at
+    when tiny.job.expr = excluded.expr then tiny.job.run_at
+    else tiny.next(coalesce(tiny.job.last_run_at, tiny.job.created_at), excluded.expr)
+  end
+-- Names are scoped by owner, a job registered by a different
+-- executor is never taken over
+where tiny.job.executor = excluded.executor
+returning *;
+
+-- name: BatchCreateJobs :batchone
+insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key, concurrency_key, concurrency_limit)
+select
+  sqlc.arg('expr')::text,
+  coalesce(nullif(sqlc.arg('name')::text, ''), substr(md5(random()::text), 0, 25)),
+  sqlc.arg('state')::text,
+  'READY',
+  sqlc.arg('executor')::text,
+  tiny.next(greatest(sqlc.arg('start_at')::timestamptz, now()), sqlc.arg('expr')::text),
+  coalesce(nullif(sqlc.arg('timeout')::int, 0), 120),
+  sqlc.arg('start_at')::timestamptz,
+  sqlc.arg('meta')::json,
+  coalesce(nullif(sqlc.arg('owner')::text, ''), 'default'),
+  coalesce(nullif(sqlc.arg('retries')::int, 0), 5),
+  sqlc.narg('deduplication_key')::text,
+  sqlc.narg('concurrency_key')::text,
+  coalesce(nullif(sqlc.arg('concurrency_limit')::int, 0), 1)
+-- A duplicate is an active job with the same key or one
+-- that finished within the deduplication window
+where not exists (
+  select 1 from tiny.job
+  where deduplication_key = sqlc.narg('deduplication_key')::text
+  and owner = coalesce(nullif(sqlc.arg('owner')::text, ''), 'default')
+  and executor = sqlc.arg('executor')::text
+  and (status in ('READY', 'PENDING', 'PAUSED')
+    or updated_at > now() - make_interval(mins => sqlc.narg('deduplication_window')::int))
+)
+on conflict (owner, executor, deduplication_key)
+where status in ('READY', 'PENDING', 'PAUSED')
+do nothing
+returning *;
+
+-- name: CopyImportJobs :copyfrom
+insert into tiny.job_import (expr, name, state, executor, start_at, timeout, meta, owner, retries, deduplication_key, concurrency_key, concurrency_limit)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+
+-- name: ImportStagedJobs :execrows
+with staged as (
+  delete from tiny.job_import
+  returning *
+)
+insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key, concurrency_key, concurrency_limit)
+select
+  expr,
+  coalesce(nullif(name, ''), substr(md5(random()::text), 0, 25)),
+  state,
+  'READY',
+  executor,
+  tiny.next(greatest(coalesce(start_at, now()), now()), expr),
+  coalesce(nullif(timeout, 0), 120),
+  coalesce(start_at, now()),
+  meta,
+  coalesce(nullif(owner, ''), 'default'),
+  coalesce(nullif(retries, 0), 5),
+  deduplication_key,
+  concurrency_key,
+  coalesce(nullif(concurrency_limit, 0), 1)
+from staged
+-- Jobs with a name or deduplication key already
+-- taken by an active job are skipped
+on conflict do nothing;
+
+-- name: MuteJobNotifications :exec
+-- Inserts of the current transaction are not notified
+select set_config('tiny.mute_notify', 'on', true);
 
 -- name: SearchJobs :many
 select * from tiny.job
@@ -165,13 +337,19 @@ order by last_run_at desc
 limit sqlc.arg('limit')::int
 offset sqlc.arg('offset')::int;
 
--- name: BatchUpdateJobs :batchexec
+-- name: BatchUpdateJobs :batchone
 update tiny.job
 set last_run_at = now(),
   state = coalesce(nullif(sqlc.arg('state')::text, ''), state),
   expr = coalesce(nullif(sqlc.arg('expr')::text, ''), expr),
-  status = sqlc.arg('status'),
+  status = case
+    when cancel_requested_at is not null then 'CANCELLED'::tiny.status
+    else sqlc.arg('status')::tiny.status
+  end,
   updated_at = now(),
+  locked_by = null,
+  locked_at = null,
+  reset_count = 0,
   execution_amount = execution_amount + 1,
   retries = sqlc.arg('retries'),
   run_at = tiny.next(
@@ -179,15 +357,25 @@ set last_run_at = now(),
     coalesce(nullif(sqlc.arg('expr')::text, ''), expr)
   )
 where id = sqlc.arg('id')
-and executor = sqlc.arg('executor'); 
+and executor = sqlc.arg('executor')
+-- A job reset or fetched again after the worker got
+-- it carries a newer lease and is left untouched.
+-- Jobs are committed only once while they are held
+and lease = sqlc.arg('lease')::int
+and status = 'PENDING'
+returning id;
 
--- name: BatchUpdateFailedJobs :batchexec
+-- name: BatchUpdateFailedJobs :batchone
 update tiny.job
 set last_run_at = now(),
   state = coalesce(nullif(sqlc.arg('state')::text, ''), state),
   updated_at = now(),
+  locked_by = null,
+  locked_at = null,
+  reset_count = 0,
   expr = coalesce(nullif(sqlc.arg('expr')::text, ''), expr),
   status = case 
+    when cancel_requested_at is not null then 'CANCELLED'::tiny.status
     when tiny.is_one_shot(expr) and retries - 1 <= 0 then 'FAILURE'::tiny.status
     else 'READY'::tiny.status
   end,
@@ -201,7 +389,32 @@ set last_run_at = now(),
     )
   end
 where id = sqlc.arg('id')
-and executor = sqlc.arg('executor'); 
+and executor = sqlc.arg('executor')
+-- A job reset or fetched again after the worker got
+-- it carries a newer lease and is left untouched.
+-- Jobs are committed only once while they are held
+and lease = sqlc.arg('lease')::int
+and status = 'PENDING'
+returning id;
+
+-- name: LockConcurrencyKeys :many
+-- Serializes fetches of due jobs sharing a concurrency key,
+-- so that every fetch counts the PENDING jobs committed by
+-- the previous one. Keys are locked in order to avoid deadlocks
+-- and released when the transaction ends
+select l.key::text
+from (
+  select k.key, pg_advisory_xact_lock(hashtextextended(k.key, 0))
+  from (
+    select distinct owner || ':' || concurrency_key as key
+    from tiny.job
+    where executor = sqlc.arg('executor')
+      and status = 'READY'
+      and run_at < now()
+      and concurrency_key is not null
+  ) as k
+  order by k.key
+) as l;
 
 -- name: FetchDueJobs :many
 with due_jobs as (
@@ -210,6 +423,60 @@ with due_jobs as (
   where j.run_at < now()
     and j.status = 'READY'
     and j.executor = sqlc.arg('executor')
+    and not exists (
+      select 1 from tiny.executor e
+      where e.name = j.executor
+      and e.paused
+    )
+    and (
+      j.concurrency_key is null
+      or (
+        -- keys locked by LockConcurrencyKeys, jobs that became
+        -- due after the lock was taken wait for the next fetch
+        j.owner || ':' || j.concurrency_key = any(sqlc.arg('locked')::text[])
+        and (
+          select count(*)
+          from tiny.job p
+          where p.owner = j.owner
+            and p.concurrency_key = j.concurrency_key
+            and p.status = 'PENDING'
+        ) + (
+          -- due jobs of this executor sharing the key that would be fetched first
+          select count(*)
+          from (
+            select 1
+            from tiny.job s
+            where s.owner = j.owner
+              and s.concurrency_key = j.concurrency_key
+              and s.executor = j.executor
+              and s.status = 'READY'
+              and s.run_at < now()
+              and (s.created_at, s.id) < (j.created_at, j.id)
+            limit j.concurrency_limit
+          ) as preceding
+        ) < j.concurrency_limit
+      )
+    )
+    and not exists (
+      -- owners without rate limit budget left for this job
+      select 1
+      from tiny.rate_limit r
+      where r.executor = j.executor
+        and r.owner = j.owner
+        and (
+          select count(*)
+          from (
+            select 1
+            from tiny.job s
+            where s.executor = j.executor
+              and s.owner = j.owner
+              and s.status = 'READY'
+              and s.run_at < now()
+              and (s.created_at, s.id) < (j.created_at, j.id)
+            limit greatest(floor(r.tokens), 0)::int
+          ) as preceding
+        ) >= floor(r.tokens)
+    )
   order by j.created_at
   limit $1
   for update skip locked
@@ -217,22 +484,197 @@ with due_jobs as (
 update tiny.job as updated_jobs
 set status = 'PENDING',
   updated_at = now(),
-  last_run_at = now()
+  last_run_at = now(),
+  lease = updated_jobs.lease + 1,
+  locked_by = sqlc.narg('worker')::text,
+  locked_at = now()
+from due_jobs
+where due_jobs.id = updated_jobs.id
+returning updated_jobs.*;
+
+-- name: FetchDueJobsFair :many
+-- Interleaves due jobs across owners so that a single owner
+-- with a large backlog cannot starve the others. Owners get a
+-- share of every fetch proportional to their weight.
+-- Up to `limit` jobs per owner are locked while ranking them
+with owners as (
+  select o.owner, coalesce(w.weight, 1) as weight
+  from (
+    select distinct j.owner
+    from tiny.job j
+    where j.run_at < now()
+      and j.status = 'READY'
+      and j.executor = sqlc.arg('executor')
+      and not exists (
+        select 1 from tiny.executor e
+        where e.name = j.executor
+        and e.paused
+      )
+  ) as o
+  left join tiny.owner_weight w on w.owner = o.owner
+),
+candidates as (
+  -- oldest due jobs of every owner, ranked by
+  -- their position in the owner queue over its weight
+  select
+    c.id,
+    c.created_at,
+    row_number() over (partition by owners.owner order by c.created_at, c.id)::float / owners.weight as share
+  from owners
+  cross join lateral (
+    select j.id, j.created_at
+    from tiny.job j
+    where j.owner = owners.owner
+      and j.run_at < now()
+      and j.status = 'READY'
+      and j.executor = sqlc.arg('executor')
+      and (
+        j.concurrency_key is null
+        or (
+          -- keys locked by LockConcurrencyKeys, jobs that became
+          -- due after the lock was taken wait for the next fetch
+          j.owner || ':' || j.concurrency_key = any(sqlc.arg('locked')::text[])
+          and (
+            select count(*)
+            from tiny.job p
+            where p.owner = j.owner
+              and p.concurrency_key = j.concurrency_key
+              and p.status = 'PENDING'
+          ) + (
+            -- due jobs of this executor sharing the key that would be fetched first
+            select count(*)
+            from (
+              select 1
+              from tiny.job s
+              where s.owner = j.owner
+                and s.concurrency_key = j.concurrency_key
+                and s.executor = j.executor
+                and s.status = 'READY'
+                and s.run_at < now()
+                and (s.created_at, s.id) < (j.created_at, j.id)
+              limit j.concurrency_limit
+            ) as preceding
+          ) < j.concurrency_limit
+        )
+      )
+      and not exists (
+        -- owners without rate limit budget left for this job
+        select 1
+        from tiny.rate_limit r
+        where r.executor = j.executor
+          and r.owner = j.owner
+          and (
+            select count(*)
+            from (
+              select 1
+              from tiny.job s
+              where s.executor = j.executor
+                and s.owner = j.owner
+                and s.status = 'READY'
+                and s.run_at < now()
+                and (s.created_at, s.id) < (j.created_at, j.id)
+              limit greatest(floor(r.tokens), 0)::int
+            ) as preceding
+          ) >= floor(r.tokens)
+      )
+    order by j.created_at
+    limit $1
+    for update skip locked
+  ) as c
+),
+due_jobs as (
+  select id
+  from candidates
+  order by share, created_at
+  limit $1
+)
+update tiny.job as updated_jobs
+set status = 'PENDING',
+  updated_at = now(),
+  last_run_at = now(),
+  lease = updated_jobs.lease + 1,
+  locked_by = sqlc.narg('worker')::text,
+  locked_at = now()
 from due_jobs
 where due_jobs.id = updated_jobs.id
 returning updated_jobs.*;
 
 -- name: ResetTimeoutJobs :many
 update tiny.job
-set status = 'READY',
-  updated_at = now()
+-- Jobs reset `max_resets` times in a row are quarantined.
+-- Quarantine is disabled when `max_resets` is zero
+set status = case
+    when cancel_requested_at is not null then 'CANCELLED'::tiny.status
+    when sqlc.arg('max_resets')::int > 0 and reset_count + 1 >= sqlc.arg('max_resets')::int then 'FAILURE'::tiny.status
+    else 'READY'::tiny.status
+  end,
+  quarantined_at = case
+    when cancel_requested_at is null and sqlc.arg('max_resets')::int > 0 and reset_count + 1 >= sqlc.arg('max_resets')::int then now()
+  end,
+  updated_at = now(),
+  lease = lease + 1,
+  reset_count = reset_count + 1,
+  locked_by = null,
+  locked_at = null
 where timeout is not null
 and timeout > 0
 and now() - last_run_at > make_interval(secs => timeout)
-and executor = $1
+and executor = sqlc.arg('executor')
 and status = 'PENDING'
 returning id;
 
+-- name: ListQuarantinedJobs :many
+select * from tiny.job
+where executor = $1
+and quarantined_at is not null
+order by quarantined_at desc
+limit $2
+offset $3;
+
+-- name: RequeueJob :one
+update tiny.job
+set status = 'READY',
+  quarantined_at = null,
+  reset_count = 0,
+  run_at = now(),
+  updated_at = now()
+where id = $1
+and executor = $2
+and quarantined_at is not null
+returning *;
+
+-- name: ListAnomalies :many
+-- Jobs that keep timing out, one shot jobs overdue and
+-- cron jobs lagging behind their schedule
+select
+  (case
+    when reset_count >= sqlc.arg('max_resets')::int then 'REPEATED_RESETS'
+    when tiny.is_one_shot(expr) then 'OVERDUE'
+    else 'STALE_CRON'
+  end)::text as kind,
+  id,
+  name,
+  expr,
+  status,
+  owner,
+  run_at,
+  last_run_at,
+  reset_count
+from tiny.job
+where executor = sqlc.arg('executor')::text
+and status in ('READY', 'PENDING')
+and (
+  reset_count >= sqlc.arg('max_resets')::int
+  or (status = 'READY'
+    and tiny.is_one_shot(expr)
+    and run_at < now() - make_interval(secs => sqlc.arg('overdue_after')::int))
+  or (status = 'READY'
+    and not tiny.is_one_shot(expr)
+    and run_at < now() - make_interval(secs => sqlc.arg('stale_cron_after')::int))
+)
+order by run_at
+limit sqlc.arg('limit');
+
 -- name: CronNextRun :one
 select run_at::timestamptz 
 from tiny.cron_next_run(
@@ -251,3 +693,453 @@ from tiny.next(
 select count(*) from tiny.job
 where executor = $1
 and status = $2;
+
+-- name: CountJobsByStatus :many
+-- Counts jobs of every owner when owner is null
+select status, count(*) from tiny.job
+where executor = sqlc.arg('executor')::text
+and (sqlc.narg('owner')::text is null or owner = sqlc.narg('owner')::text)
+group by status;
+
+-- name: HeartbeatWorker :one
+insert into tiny.worker (id, executor, expires_at)
+values (
+  sqlc.arg('id'),
+  sqlc.arg('executor'),
+  now() + make_interval(secs => sqlc.arg('ttl')::int)
+)
+on conflict (id, executor) do update
+set heartbeat_at = now(),
+  expires_at = excluded.expires_at
+returning *;
+
+-- name: ReleaseOrphanedJobs :many
+update tiny.job j
+set status = case
+    when j.cancel_requested_at is not null then 'CANCELLED'::tiny.status
+    when sqlc.arg('max_resets')::int > 0 and j.reset_count + 1 >= sqlc.arg('max_resets')::int then 'FAILURE'::tiny.status
+    else 'READY'::tiny.status
+  end,
+  quarantined_at = case
+    when j.cancel_requested_at is null and sqlc.arg('max_resets')::int > 0 and j.reset_count + 1 >= sqlc.arg('max_resets')::int then now()
+  end,
+  updated_at = now(),
+  lease = lease + 1,
+  reset_count = reset_count + 1,
+  locked_by = null,
+  locked_at = null
+where j.executor = sqlc.arg('executor')
+and j.status = 'PENDING'
+and j.locked_by is not null
+-- Jobs held by a registered worker that stopped heartbeating
+-- are released without waiting for their timeout. Jobs held
+-- by workers that never heartbeat are left to their timeout
+and exists (
+  select 1 from tiny.worker w
+  where w.id = j.locked_by
+  and w.executor = j.executor
+  and w.expires_at <= now()
+)
+returning j.id;
+
+-- name: DeleteExpiredWorkers :execrows
+delete from tiny.worker
+where executor = $1
+and expires_at < now();
+
+-- name: ListWorkers :many
+select * from tiny.worker
+where executor = $1
+and expires_at > now()
+order by started_at;
+
+-- name: ListWorkerJobs :many
+select * from tiny.job
+where locked_by = sqlc.arg('worker')::text
+and executor = sqlc.arg('executor')::text
+and status = 'PENDING'
+order by locked_at;
+
+-- name: ListCancelRequestedJobs :many
+select id from tiny.job
+where locked_by = sqlc.arg('worker')::text
+and executor = sqlc.arg('executor')::text
+and status = 'PENDING'
+and cancel_requested_at is not null;
+
+-- name: SetOwnerWeight :exec
+insert into tiny.owner_weight (owner, weight)
+values ($1, $2)
+on conflict (owner) do update
+set weight = excluded.weight;
+
+-- name: RefillRateLimits :many
+update tiny.rate_limit
+set tokens = least(burst, tokens + extract(epoch from now() - updated_at) * rate),
+  updated_at = now()
+where executor = $1
+returning *;
+
+-- name: ConsumeRateLimit :exec
+update tiny.rate_limit
+set tokens = tokens - sqlc.arg('amount')::int
+where executor = sqlc.arg('executor')
+and owner = sqlc.arg('owner');
+
+-- name: SetRateLimit :exec
+insert into tiny.rate_limit (executor, owner, rate, burst, tokens)
+values ($1, $2, $3, $4, $4)
+on conflict (executor, owner) do update
+set rate = excluded.rate,
+  burst = excluded.burst,
+  tokens = least(tiny.rate_limit.tokens, excluded.burst);
+
+-- name: DeleteRateLimit :exec
+delete from tiny.rate_limit
+where executor = $1
+and owner = $2;
+
+-- name: ListRateLimits :many
+select
+  executor,
+  owner,
+  rate,
+  burst,
+  least(burst, tokens + extract(epoch from now() - updated_at) * rate)::float8 as tokens
+from tiny.rate_limit
+where executor = $1
+order by owner;
+
+-- name: PauseExecutor :one
+insert into tiny.executor (name, paused, paused_at)
+values ($1, true, now())
+on conflict (name) do update
+set paused = true,
+  paused_at = coalesce(tiny.executor.paused_at, now()),
+  updated_at = now()
+returning *;
+
+-- name: ResumeExecutor :one
+insert into tiny.executor (name, paused)
+values ($1, false)
+on conflict (name) do update
+set paused = false,
+  paused_at = null,
+  updated_at = now()
+returning *;
+
+-- name: PauseJobsByFilter :execrows
+update tiny.job
+set status = 'PAUSED',
+  updated_at = now()
+where executor = sqlc.arg('executor')::text
+and (sqlc.narg('query')::text is null or meta::jsonb @> (sqlc.narg('query')::text)::jsonb)
+and (sqlc.narg('statuses')::text is null or status::text = any(string_to_array(sqlc.narg('statuses')::text, ',')))
+and (sqlc.narg('from')::timestamptz is null or created_at > sqlc.narg('from')::timestamptz)
+and (sqlc.narg('to')::timestamptz is null or created_at < sqlc.narg('to')::timestamptz)
+and (sqlc.narg('name')::text is null
+  or name like concat(sqlc.narg('name')::text, '%')
+  or name like concat('%', sqlc.narg('name')::text))
+and (sqlc.narg('is_one_shot')::boolean is null or tiny.is_one_shot(expr) = sqlc.narg('is_one_shot')::boolean)
+and status = 'READY';
+
+-- name: ResumeJobsByFilter :execrows
+update tiny.job
+set status = 'READY',
+  updated_at = now()
+where executor = sqlc.arg('executor')::text
+and (sqlc.narg('query')::text is null or meta::jsonb @> (sqlc.narg('query')::text)::jsonb)
+and (sqlc.narg('statuses')::text is null or status::text = any(string_to_array(sqlc.narg('statuses')::text, ',')))
+and (sqlc.narg('from')::timestamptz is null or created_at > sqlc.narg('from')::timestamptz)
+and (sqlc.narg('to')::timestamptz is null or created_at < sqlc.narg('to')::timestamptz)
+and (sqlc.narg('name')::text is null
+  or name like concat(sqlc.narg('name')::text, '%')
+  or name like concat('%', sqlc.narg('name')::text))
+and (sqlc.narg('is_one_shot')::boolean is null or tiny.is_one_shot(expr) = sqlc.narg('is_one_shot')::boolean)
+and status = 'PAUSED';
+
+-- name: DeleteJobsByFilter :execrows
+delete from tiny.job
+where executor = sqlc.arg('executor')::text
+and (sqlc.narg('query')::text is null or meta::jsonb @> (sqlc.narg('query')::text)::jsonb)
+and (sqlc.narg('statuses')::text is null or status::text = any(string_to_array(sqlc.narg('statuses')::text, ',')))
+and (sqlc.narg('from')::timestamptz is null or created_at > sqlc.narg('from')::timestamptz)
+and (sqlc.narg('to')::timestamptz is null or created_at < sqlc.narg('to')::timestamptz)
+and (sqlc.narg('name')::text is null
+  or name like concat(sqlc.narg('name')::text, '%')
+  or name like concat('%', sqlc.narg('name')::text))
+and (sqlc.narg('is_one_shot')::boolean is null or tiny.is_one_shot(expr) = sqlc.narg('is_one_shot')::boolean);
+
+-- name: TryAdvisoryLock :one
+-- Locks are namespaced not to clash with advisory
+-- locks taken by applications sharing the database
+select pg_try_advisory_lock(hashtext('qron'), hashtext(sqlc.arg('name')::text)) as locked;
+
+-- name: AdvisoryUnlock :one
+select pg_advisory_unlock(hashtext('qron'), hashtext(sqlc.arg('name')::text)) as unlocked;
+
+-- name: ListExecutorStats :many
+-- Durations (in seconds) and throughput are computed from runs
+-- finished in the last hour. Stats of every executor are
+-- returned when `executor` is null
+with jobs as (
+  select
+    executor,
+    count(*) filter (where status = 'READY') as ready,
+    count(*) filter (where status = 'PENDING') as pending,
+    count(*) filter (where status = 'FAILURE') as failure,
+    count(*) filter (where status = 'SUCCESS') as success,
+    count(*) filter (where status = 'PAUSED') as paused,
+    count(*) filter (where status = 'CANCELLED') as cancelled,
+    count(*) filter (where status = 'READY' and run_at <= now()) as due,
+    min(run_at) filter (where status = 'READY') as oldest_ready_run_at
+  from tiny.job
+  where sqlc.narg('executor')::text is null or executor = sqlc.narg('executor')::text
+  group by executor
+),
+runs as (
+  select
+    executor,
+    avg(extract(epoch from finished_at - started_at)::float8) as avg_duration,
+    percentile_cont(0.5) within group (order by extract(epoch from finished_at - started_at)::float8) as p50_duration,
+    percentile_cont(0.95) within group (order by extract(epoch from finished_at - started_at)::float8) as p95_duration,
+    percentile_cont(0.99) within group (order by extract(epoch from finished_at - started_at)::float8) as p99_duration,
+    count(*) filter (where finished_at > now() - interval '1 minute') as throughput_1m,
+    count(*) filter (where finished_at > now() - interval '5 minutes') as throughput_5m,
+    count(*) as throughput_1h
+  from tiny.job_run
+  where finished_at > now() - interval '1 hour'
+  and (sqlc.narg('executor')::text is null or executor = sqlc.narg('executor')::text)
+  group by executor
+)
+select
+  jobs.executor,
+  jobs.ready,
+  jobs.pending,
+  jobs.failure,
+  jobs.success,
+  jobs.paused,
+  jobs.cancelled,
+  jobs.due,
+  jobs.oldest_ready_run_at::timestamptz as oldest_ready_run_at,
+  coalesce(runs.avg_duration, 0)::float8 as avg_duration,
+  coalesce(runs.p50_duration, 0)::float8 as p50_duration,
+  coalesce(runs.p95_duration, 0)::float8 as p95_duration,
+  coalesce(runs.p99_duration, 0)::float8 as p99_duration,
+  coalesce(runs.throughput_1m, 0)::bigint as throughput_1m,
+  coalesce(runs.throughput_5m, 0)::bigint as throughput_5m,
+  coalesce(runs.throughput_1h, 0)::bigint as throughput_1h
+from jobs
+left join runs on runs.executor = jobs.executor
+order by jobs.executor;
+
+-- name: PruneJobRuns :execrows
+delete from tiny.job_run
+where executor = sqlc.arg('executor')::text
+and finished_at < now() - make_interval(secs => sqlc.arg('retention')::int);
+
+-- name: ListJobsByIDAsc :many
+-- Jobs are paginated with keyset cursors, so pages are
+-- as fast as the first one regardless of the table size.
+-- Walks job_executor_id_idx; a missing cursor falls back to a sentinel
+-- so the range condition stays usable by generic plans
+select * from tiny.job
+where executor = sqlc.arg('executor')::text
+and (sqlc.narg('statuses')::text is null or status::text = any(string_to_array(sqlc.narg('statuses')::text, ',')))
+and (sqlc.narg('is_one_shot')::boolean is null or tiny.is_one_shot(expr) = sqlc.narg('is_one_shot')::boolean)
+and (sqlc.narg('name')::text is null
+  or name like concat(sqlc.narg('name')::text, '%')
+  or name like concat('%', sqlc.narg('name')::text))
+and (sqlc.narg('owner')::text is null or owner = sqlc.narg('owner')::text)
+and (sqlc.narg('meta')::text is null or meta::jsonb @> (sqlc.narg('meta')::text)::jsonb)
+and (sqlc.narg('meta_path')::text is null or meta::jsonb @? (sqlc.narg('meta_path')::text)::jsonpath)
+and (sqlc.narg('run_after')::timestamptz is null or run_at > sqlc.narg('run_after')::timestamptz)
+and (sqlc.narg('run_before')::timestamptz is null or run_at < sqlc.narg('run_before')::timestamptz)
+and (sqlc.narg('updated_after')::timestamptz is null or updated_at > sqlc.narg('updated_after')::timestamptz)
+and (sqlc.narg('updated_before')::timestamptz is null or updated_at < sqlc.narg('updated_before')::timestamptz)
+and id > coalesce(sqlc.narg('after_id')::bigint, 0)
+order by id
+limit sqlc.arg('limit')::int;
+
+-- name: ListJobsByIDDesc :many
+-- Walks job_executor_id_idx
+select * from tiny.job
+where executor = sqlc.arg('executor')::text
+and (sqlc.narg('statuses')::text is null or status::text = any(string_to_array(sqlc.narg('statuses')::text, ',')))
+and (sqlc.narg('is_one_shot')::boolean is null or tiny.is_one_shot(expr) = sqlc.narg('is_one_shot')::boolean)
+and (sqlc.narg('name')::text is null
+  or name like concat(sqlc.narg('name')::text, '%')
+  or name like concat('%', sqlc.narg('name')::text))
+and (sqlc.narg('owner')::text is null or owner = sqlc.narg('owner')::text)
+and (sqlc.narg('meta')::text is null or meta::jsonb @> (sqlc.narg('meta')::text)::jsonb)
+and (sqlc.narg('meta_path')::text is null or meta::jsonb @? (sqlc.narg('meta_path')::text)::jsonpath)
+and (sqlc.narg('run_after')::timestamptz is null or run_at > sqlc.narg('run_after')::timestamptz)
+and (sqlc.narg('run_before')::timestamptz is null or run_at < sqlc.narg('run_before')::timestamptz)
+and (sqlc.narg('updated_after')::timestamptz is null or updated_at > sqlc.narg('updated_after')::timestamptz)
+and (sqlc.narg('updated_before')::timestamptz is null or updated_at < sqlc.narg('updated_before')::timestamptz)
+and id < coalesce(sqlc.narg('after_id')::bigint, 9223372036854775807)
+order by id desc
+limit sqlc.arg('limit')::int;
+
+-- name: ListJobsByRunAtAsc :many
+-- Walks job_executor_run_at_idx
+select * from tiny.job
+where executor = sqlc.arg('executor')::text
+and (sqlc.narg('statuses')::text is null or status::text = any(string_to_array(sqlc.narg('statuses')::text, ',')))
+and (sqlc.narg('is_one_shot')::boolean is null or tiny.is_one_shot(expr) = sqlc.narg('is_one_shot')::boolean)
+and (sqlc.narg('name')::text is null
+  or name like concat(sqlc.narg('name')::text, '%')
+  or name like concat('%', sqlc.narg('name')::text))
+and (sqlc.narg('owner')::text is null or owner = sqlc.narg('owner')::text)
+and (sqlc.narg('meta')::text is null or meta::jsonb @> (sqlc.narg('meta')::text)::jsonb)
+and (sqlc.narg('meta_path')::text is null or meta::jsonb @? (sqlc.narg('meta_path')::text)::jsonpath)
+and (sqlc.narg('run_after')::timestamptz is null or run_at > sqlc.narg('run_after')::timestamptz)
+and (sqlc.narg('run_before')::timestamptz is null or run_at < sqlc.narg('run_before')::timestamptz)
+and (sqlc.narg('updated_after')::timestamptz is null or updated_at > sqlc.narg('updated_after')::timestamptz)
+and (sqlc.narg('updated_before')::timestamptz is null or updated_at < sqlc.narg('updated_before')::timestamptz)
+and (run_at, id) > (coalesce(sqlc.narg('after_at')::timestamptz, '-infinity'), coalesce(sqlc.narg('after_id')::bigint, 0))
+order by run_at, id
+limit sqlc.arg('limit')::int;
+
+-- name: ListJobsByRunAtDesc :many
+-- Walks job_executor_run_at_idx
+select * from tiny.job
+where executor = sqlc.arg('executor')::text
+and (sqlc.narg('statuses')::text is null or status::text = any(string_to_array(sqlc.narg('statuses')::text, ',')))
+and (sqlc.narg('is_one_shot')::boolean is null or tiny.is_one_shot(expr) = sqlc.narg('is_one_shot')::boolean)
+and (sqlc.narg('name')::text is null
+  or name like concat(sqlc.narg('name')::text, '%')
+  or name like concat('%', sqlc.narg('name')::text))
+and (sqlc.narg('owner')::text is null or owner = sqlc.narg('owner')::text)
+and (sqlc.narg('meta')::text is null or meta::jsonb @> (sqlc.narg('meta')::text)::jsonb)
+and (sqlc.narg('meta_path')::text is null or meta::jsonb @? (sqlc.narg('meta_path')::text)::jsonpath)
+and (sqlc.narg('run_after')::timestamptz is null or run_at > sqlc.narg('run_after')::timestamptz)
+and (sqlc.narg('run_before')::timestamptz is null or run_at < sqlc.narg('run_before')::timestamptz)
+and (sqlc.narg('updated_after')::timestamptz is null or updated_at > sqlc.narg('updated_after')::timestamptz)
+and (sqlc.narg('updated_before')::timestamptz is null or updated_at < sqlc.narg('updated_before')::timestamptz)
+and (run_at, id) < (coalesce(sqlc.narg('after_at')::timestamptz, 'infinity'), coalesce(sqlc.narg('after_id')::bigint, 0))
+order by run_at desc, id desc
+limit sqlc.arg('limit')::int;
+
+-- name: ListJobsByUpdatedAtAsc :many
+-- Walks job_executor_updated_at_idx
+select * from tiny.job
+where executor = sqlc.arg('executor')::text
+and (sqlc.narg('statuses')::text is null or status::text = any(string_to_array(sqlc.narg('statuses')::text, ',')))
+and (sqlc.narg('is_one_shot')::boolean is null or tiny.is_one_shot(expr) = sqlc.narg('is_one_shot')::boolean)
+and (sqlc.narg('name')::text is null
+  or name like concat(sqlc.narg('name')::text, '%')
+  or name like concat('%', sqlc.narg('name')::text))
+and (sqlc.narg('owner')::text is null or owner = sqlc.narg('owner')::text)
+and (sqlc.narg('meta')::text is null or meta::jsonb @> (sqlc.narg('meta')::text)::jsonb)
+and (sqlc.narg('meta_path')::text is null or meta::jsonb @? (sqlc.narg('meta_path')::text)::jsonpath)
+and (sqlc.narg('run_after')::timestamptz is null or run_at > sqlc.narg('run_after')::timestamptz)
+and (sqlc.narg('run_before')::timestamptz is null or run_at < sqlc.narg('run_before')::timestamptz)
+and (sqlc.narg('updated_after')::timestamptz is null or updated_at > sqlc.narg('updated_after')::timestamptz)
+and (sqlc.narg('updated_before')::timestamptz is null or updated_at < sqlc.narg('updated_before')::timestamptz)
+and (updated_at, id) > (coalesce(sqlc.narg('after_at')::timestamptz, '-infinity'), coalesce(sqlc.narg('after_id')::bigint, 0))
+order by updated_at, id
+limit sqlc.arg('limit')::int;
+
+-- name: ListJobsByUpdatedAtDesc :many
+-- Walks job_executor_updated_at_idx
+select * from tiny.job
+where executor = sqlc.arg('executor')::text
+and (sqlc.narg('statuses')::text is null or status::text = any(string_to_array(sqlc.narg('statuses')::text, ',')))
+and (sqlc.narg('is_one_shot')::boolean is null or tiny.is_one_shot(expr) = sqlc.narg('is_one_shot')::boolean)
+and (sqlc.narg('name')::text is null
+  or name like concat(sqlc.narg('name')::text, '%')
+  or name like concat('%', sqlc.narg('name')::text))
+and (sqlc.narg('owner')::text is null or owner = sqlc.narg('owner')::text)
+and (sqlc.narg('meta')::text is null or meta::jsonb @> (sqlc.narg('meta')::text)::jsonb)
+and (sqlc.narg('meta_path')::text is null or meta::jsonb @? (sqlc.narg('meta_path')::text)::jsonpath)
+and (sqlc.narg('run_after')::timestamptz is null or run_at > sqlc.narg('run_after')::timestamptz)
+and (sqlc.narg('run_before')::timestamptz is null or run_at < sqlc.narg('run_before')::timestamptz)
+and (sqlc.narg('updated_after')::timestamptz is null or updated_at > sqlc.narg('updated_after')::timestamptz)
+and (sqlc.narg('updated_before')::timestamptz is null or updated_at < sqlc.narg('updated_before')::timestamptz)
+and (updated_at, id) < (coalesce(sqlc.narg('after_at')::timestamptz, 'infinity'), coalesce(sqlc.narg('after_id')::bigint, 0))
+order by updated_at desc, id desc
+limit sqlc.arg('limit')::int;
+
+-- name: CreateApiKey :one
+insert into tiny.api_key (name, prefix, hash, owner, scopes, executors, roles, expires_at)
+values (
+  sqlc.arg('name')::text,
+  sqlc.arg('prefix')::text,
+  sqlc.arg('hash')::text,
+  coalesce(nullif(sqlc.arg('owner')::text, ''), 'default'),
+  sqlc.arg('scopes')::text[],
+  sqlc.arg('executors')::text[],
+  sqlc.arg('roles')::text[],
+  sqlc.narg('expires_at')::timestamptz
+)
+returning *;
+
+-- name: GetActiveApiKey :one
+select * from tiny.api_key
+where hash = sqlc.arg('hash')::text
+and revoked_at is null
+and (expires_at is null or expires_at > now());
+
+-- name: ListApiKeys :many
+select * from tiny.api_key
+where owner = coalesce(nullif(sqlc.arg('owner')::text, ''), 'default')
+order by id;
+
+-- name: RevokeApiKey :one
+update tiny.api_key
+set revoked_at = coalesce(revoked_at, now())
+where id = sqlc.arg('id')::bigint
+and owner = coalesce(nullif(sqlc.arg('owner')::text, ''), 'default')
+returning *;
+
+-- name: CreatePolicy :one
+insert into tiny.policy (owner, role, executor, operation)
+values (
+  coalesce(nullif(sqlc.arg('owner')::text, ''), 'default'),
+  sqlc.arg('role')::text,
+  sqlc.arg('executor')::text,
+  sqlc.arg('operation')::text
+)
+on conflict (owner, role, executor, operation) do update
+set role = excluded.role
+returning *;
+
+-- name: DeletePolicy :one
+delete from tiny.policy
+where id = sqlc.arg('id')::bigint
+and owner = coalesce(nullif(sqlc.arg('owner')::text, ''), 'default')
+returning *;
+
+-- name: ListPolicies :many
+select * from tiny.policy
+where owner = coalesce(nullif(sqlc.arg('owner')::text, ''), 'default')
+order by role, executor, operation;
+
+-- name: IsAllowed :one
+-- Whether any of the roles is granted the operation on the executor
+select exists (
+  select 1 from tiny.policy
+  where owner = coalesce(nullif(sqlc.arg('owner')::text, ''), 'default')
+  and role = any(sqlc.arg('roles')::text[])
+  and executor in (sqlc.arg('executor')::text, '*')
+  and operation in (sqlc.arg('operation')::text, '*')
+);
+
+-- name: CreateAuditEntry :exec
+insert into tiny.audit_log (owner, subject, roles, executor, operation, reason)
+values (
+  coalesce(nullif(sqlc.arg('owner')::text, ''), 'default'),
+  sqlc.arg('subject')::text,
+  sqlc.arg('roles')::text[],
+  sqlc.arg('executor')::text,
+  sqlc.arg('operation')::text,
+  sqlc.arg('reason')::text
+);
+
+-- name: ListAuditLog :many
+select * from tiny.audit_log
+where owner = coalesce(nullif(sqlc.arg('owner')::text, ''), 'default')
+and (sqlc.narg('executor')::text is null or executor = sqlc.narg('executor')::text)
+order by created_at desc, id desc
+limit sqlc.arg('limit')::int;
@@ -13,22 +13,35 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const batchCreateJobs = `-- name: BatchCreateJobs :batchexec
-insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key)
-values (
-  $1,
-  coalesce(nullif($2, ''), substr(md5(random()::text), 0, 25)),
-  $3,
+const batchCreateJobs = `-- name: BatchCreateJobs :batchone
+insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key, concurrency_key, concurrency_limit)
+select
+  $1::text,
+  coalesce(nullif($2::text, ''), substr(md5(random()::text), 0, 25)),
+  $3::text,
   'READY',
-  $4,
-  tiny.next(greatest($5, now()), $1),
-  coalesce(nullif($6, 0), 120),
-  $5,
-  $7,
-  coalesce(nullif($8, ''), 'default'),
-  coalesce(nullif($9, 0), 5),
-  $10
+  $4::text,
+  tiny.next(greatest($5::timestamptz, now()), $1::text),
+  coalesce(nullif($6::int, 0), 120),
+  $5::timestamptz,
+  $7::json,
+  coalesce(nullif($8::text, ''), 'default'),
+  coalesce(nullif($9::int, 0), 5),
+  $10::text,
+  $11::text,
+  coalesce(nullif($12::int, 0), 1)
+where not exists (
+  select 1 from tiny.job
+  where deduplication_key = $10::text
+  and owner = coalesce(nullif($8::text, ''), 'default')
+  and executor = $4::text
+  and (status in ('READY', 'PENDING', 'PAUSED')
+    or updated_at > now() - make_interval(mins => $13::int))
 )
+on conflict (owner, executor, deduplication_key)
+where status in ('READY', 'PENDING', 'PAUSED')
+do nothing
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
 `
 
 type BatchCreateJobsBatchResults struct {
@@ -38,18 +51,23 @@ type BatchCreateJobsBatchResults struct {
 }
 
 type BatchCreateJobsParams struct {
-	Expr             string             `json:"expr"`
-	Name             interface{}        `json:"name"`
-	State            string             `json:"state"`
-	Executor         string             `json:"executor"`
-	StartAt          pgtype.Timestamptz `json:"start_at"`
-	Timeout          interface{}        `json:"timeout"`
-	Meta             []byte             `json:"meta"`
-	Owner            interface{}        `json:"owner"`
-	Retries          interface{}        `json:"retries"`
-	DeduplicationKey pgtype.Text        `json:"deduplication_key"`
+	Expr                string             `json:"expr"`
+	Name                string             `json:"name"`
+	State               string             `json:"state"`
+	Executor            string             `json:"executor"`
+	StartAt             pgtype.Timestamptz `json:"start_at"`
+	Timeout             int32              `json:"timeout"`
+	Meta                []byte             `json:"meta"`
+	Owner               string             `json:"owner"`
+	Retries             int32              `json:"retries"`
+	DeduplicationKey    pgtype.Text        `json:"deduplication_key"`
+	ConcurrencyKey      pgtype.Text        `json:"concurrency_key"`
+	ConcurrencyLimit    int32              `json:"concurrency_limit"`
+	DeduplicationWindow pgtype.Int4        `json:"deduplication_window"`
 }
 
+// A duplicate is an active job with the same key or one
+// that finished within the deduplication window
 func (q *Queries) BatchCreateJobs(ctx context.Context, arg []BatchCreateJobsParams) *BatchCreateJobsBatchResults {
 	batch := &pgx.Batch{}
 	for _, a := range arg {
@@ -64,6 +82,9 @@ func (q *Queries) BatchCreateJobs(ctx context.Context, arg []BatchCreateJobsPara
 			a.Owner,
 			a.Retries,
 			a.DeduplicationKey,
+			a.ConcurrencyKey,
+			a.ConcurrencyLimit,
+			a.DeduplicationWindow,
 		}
 		batch.Queue(batchCreateJobs, vals...)
 	}
@@ -71,18 +92,46 @@ func (q *Queries) BatchCreateJobs(ctx context.Context, arg []BatchCreateJobsPara
 	return &BatchCreateJobsBatchResults{br, len(arg), false}
 }
 
-func (b *BatchCreateJobsBatchResults) Exec(f func(int, error)) {
+func (b *BatchCreateJobsBatchResults) QueryRow(f func(int, TinyJob, error)) {
 	defer b.br.Close()
 	for t := 0; t < b.tot; t++ {
+		var i TinyJob
 		if b.closed {
 			if f != nil {
-				f(t, errors.New("batch already closed"))
+				f(t, i, errors.New("batch already closed"))
 			}
 			continue
 		}
-		_, err := b.br.Exec()
+		row := b.br.QueryRow()
+		err := row.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		)
 		if f != nil {
-			f(t, err)
+			f(t, i, err)
 		}
 	}
 }
@@ -92,13 +141,17 @@ func (b *BatchCreateJobsBatchResults) Close() error {
 	return b.br.Close()
 }
 
-const batchUpdateFailedJobs = `-- name: BatchUpdateFailedJobs :batchexec
+const batchUpdateFailedJobs = `-- name: BatchUpdateFailedJobs :batchone
 update tiny.job
 set last_run_at = now(),
   state = coalesce(nullif($1::text, ''), state),
   updated_at = now(),
+  locked_by = null,
+  locked_at = null,
+  reset_count = 0,
   expr = coalesce(nullif($2::text, ''), expr),
   status = case 
+    when cancel_requested_at is not null then 'CANCELLED'::tiny.status
     when tiny.is_one_shot(expr) and retries - 1 <= 0 then 'FAILURE'::tiny.status
     else 'READY'::tiny.status
   end,
@@ -113,6 +166,9 @@ set last_run_at = now(),
   end
 where id = $3
 and executor = $4
+and lease = $5::int
+and status = 'PENDING'
+returning id
 `
 
 type BatchUpdateFailedJobsBatchResults struct {
@@ -126,8 +182,12 @@ type BatchUpdateFailedJobsParams struct {
 	Expr     string `json:"expr"`
 	ID       int64  `json:"id"`
 	Executor string `json:"executor"`
+	Lease    int32  `json:"lease"`
 }
 
+// A job reset or fetched again after the worker got
+// it carries a newer lease and is left untouched.
+// Jobs are committed only once while they are held
 func (q *Queries) BatchUpdateFailedJobs(ctx context.Context, arg []BatchUpdateFailedJobsParams) *BatchUpdateFailedJobsBatchResults {
 	batch := &pgx.Batch{}
 	for _, a := range arg {
@@ -136,6 +196,7 @@ func (q *Queries) BatchUpdateFailedJobs(ctx context.Context, arg []BatchUpdateFa
 			a.Expr,
 			a.ID,
 			a.Executor,
+			a.Lease,
 		}
 		batch.Queue(batchUpdateFailedJobs, vals...)
 	}
@@ -143,18 +204,20 @@ func (q *Queries) BatchUpdateFailedJobs(ctx context.Context, arg []BatchUpdateFa
 	return &BatchUpdateFailedJobsBatchResults{br, len(arg), false}
 }
 
-func (b *BatchUpdateFailedJobsBatchResults) Exec(f func(int, error)) {
+func (b *BatchUpdateFailedJobsBatchResults) QueryRow(f func(int, int64, error)) {
 	defer b.br.Close()
 	for t := 0; t < b.tot; t++ {
+		var id int64
 		if b.closed {
 			if f != nil {
-				f(t, errors.New("batch already closed"))
+				f(t, id, errors.New("batch already closed"))
 			}
 			continue
 		}
-		_, err := b.br.Exec()
+		row := b.br.QueryRow()
+		err := row.Scan(&id)
 		if f != nil {
-			f(t, err)
+			f(t, id, err)
 		}
 	}
 }
@@ -164,13 +227,19 @@ func (b *BatchUpdateFailedJobsBatchResults) Close() error {
 	return b.br.Close()
 }
 
-const batchUpdateJobs = `-- name: BatchUpdateJobs :batchexec
+const batchUpdateJobs = `-- name: BatchUpdateJobs :batchone
 update tiny.job
 set last_run_at = now(),
   state = coalesce(nullif($1::text, ''), state),
   expr = coalesce(nullif($2::text, ''), expr),
-  status = $3,
+  status = case
+    when cancel_requested_at is not null then 'CANCELLED'::tiny.status
+    else $3::tiny.status
+  end,
   updated_at = now(),
+  locked_by = null,
+  locked_at = null,
+  reset_count = 0,
   execution_amount = execution_amount + 1,
   retries = $4,
   run_at = tiny.next(
@@ -179,6 +248,9 @@ set last_run_at = now(),
   )
 where id = $5
 and executor = $6
+and lease = $7::int
+and status = 'PENDING'
+returning id
 `
 
 type BatchUpdateJobsBatchResults struct {
@@ -194,8 +266,12 @@ type BatchUpdateJobsParams struct {
 	Retries  int32      `json:"retries"`
 	ID       int64      `json:"id"`
 	Executor string     `json:"executor"`
+	Lease    int32      `json:"lease"`
 }
 
+// A job reset or fetched again after the worker got
+// it carries a newer lease and is left untouched.
+// Jobs are committed only once while they are held
 func (q *Queries) BatchUpdateJobs(ctx context.Context, arg []BatchUpdateJobsParams) *BatchUpdateJobsBatchResults {
 	batch := &pgx.Batch{}
 	for _, a := range arg {
@@ -206,6 +282,7 @@ func (q *Queries) BatchUpdateJobs(ctx context.Context, arg []BatchUpdateJobsPara
 			a.Retries,
 			a.ID,
 			a.Executor,
+			a.Lease,
 		}
 		batch.Queue(batchUpdateJobs, vals...)
 	}
@@ -213,18 +290,20 @@ func (q *Queries) BatchUpdateJobs(ctx context.Context, arg []BatchUpdateJobsPara
 	return &BatchUpdateJobsBatchResults{br, len(arg), false}
 }
 
-func (b *BatchUpdateJobsBatchResults) Exec(f func(int, error)) {
+func (b *BatchUpdateJobsBatchResults) QueryRow(f func(int, int64, error)) {
 	defer b.br.Close()
 	for t := 0; t < b.tot; t++ {
+		var id int64
 		if b.closed {
 			if f != nil {
-				f(t, errors.New("batch already closed"))
+				f(t, id, errors.New("batch already closed"))
 			}
 			continue
 		}
-		_, err := b.br.Exec()
+		row := b.br.QueryRow()
+		err := row.Scan(&id)
 		if f != nil {
-			f(t, err)
+			f(t, id, err)
 		}
 	}
 }
@@ -14,11 +14,12 @@ import (
 type TinyStatus string
 
 const (
-	TinyStatusREADY   TinyStatus = "READY"
-	TinyStatusPENDING TinyStatus = "PENDING"
-	TinyStatusFAILURE TinyStatus = "FAILURE"
-	TinyStatusSUCCESS TinyStatus = "SUCCESS"
-	TinyStatusPAUSED  TinyStatus = "PAUSED"
+	TinyStatusREADY     TinyStatus = "READY"
+	TinyStatusPENDING   TinyStatus = "PENDING"
+	TinyStatusFAILURE   TinyStatus = "FAILURE"
+	TinyStatusSUCCESS   TinyStatus = "SUCCESS"
+	TinyStatusPAUSED    TinyStatus = "PAUSED"
+	TinyStatusCANCELLED TinyStatus = "CANCELLED"
 )
 
 func (e *TinyStatus) Scan(src interface{}) error {
@@ -56,22 +57,103 @@ func (ns NullTinyStatus) Value() (driver.Value, error) {
 	return ns.TinyStatus, nil
 }
 
+type TinyApiKey struct {
+	ID        int64              `json:"id"`
+	Name      string             `json:"name"`
+	Prefix    string             `json:"prefix"`
+	Hash      string             `json:"hash"`
+	Owner     string             `json:"owner"`
+	Scopes    []string           `json:"scopes"`
+	Executors []string           `json:"executors"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+	Roles     []string           `json:"roles"`
+}
+
+type TinyAuditLog struct {
+	ID        int64              `json:"id"`
+	Owner     string             `json:"owner"`
+	Subject   string             `json:"subject"`
+	Roles     []string           `json:"roles"`
+	Executor  string             `json:"executor"`
+	Operation string             `json:"operation"`
+	Reason    string             `json:"reason"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type TinyExecutor struct {
+	Name      string             `json:"name"`
+	Paused    bool               `json:"paused"`
+	PausedAt  pgtype.Timestamptz `json:"paused_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
 type TinyJob struct {
-	ID               int64              `json:"id"`
-	Expr             string             `json:"expr"`
-	RunAt            pgtype.Timestamptz `json:"run_at"`
-	LastRunAt        pgtype.Timestamptz `json:"last_run_at"`
-	CreatedAt        pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
-	StartAt          pgtype.Timestamptz `json:"start_at"`
-	ExecutionAmount  int32              `json:"execution_amount"`
-	Retries          int32              `json:"retries"`
-	Name             string             `json:"name"`
-	Meta             []byte             `json:"meta"`
-	Timeout          int32              `json:"timeout"`
-	Status           TinyStatus         `json:"status"`
-	State            string             `json:"state"`
-	Executor         string             `json:"executor"`
-	Owner            string             `json:"owner"`
-	DeduplicationKey pgtype.Text        `json:"deduplication_key"`
+	ID                int64              `json:"id"`
+	Expr              string             `json:"expr"`
+	RunAt             pgtype.Timestamptz `json:"run_at"`
+	LastRunAt         pgtype.Timestamptz `json:"last_run_at"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+	StartAt           pgtype.Timestamptz `json:"start_at"`
+	ExecutionAmount   int32              `json:"execution_amount"`
+	Retries           int32              `json:"retries"`
+	Name              string             `json:"name"`
+	Meta              []byte             `json:"meta"`
+	Timeout           int32              `json:"timeout"`
+	Status            TinyStatus         `json:"status"`
+	State             string             `json:"state"`
+	Executor          string             `json:"executor"`
+	Owner             string             `json:"owner"`
+	DeduplicationKey  pgtype.Text        `json:"deduplication_key"`
+	ConcurrencyKey    pgtype.Text        `json:"concurrency_key"`
+	ConcurrencyLimit  int32              `json:"concurrency_limit"`
+	Lease             int32              `json:"lease"`
+	LockedBy          pgtype.Text        `json:"locked_by"`
+	LockedAt          pgtype.Timestamptz `json:"locked_at"`
+	CancelRequestedAt pgtype.Timestamptz `json:"cancel_requested_at"`
+	ResetCount        int32              `json:"reset_count"`
+	QuarantinedAt     pgtype.Timestamptz `json:"quarantined_at"`
+}
+
+type TinyJobRun struct {
+	ID         int64              `json:"id"`
+	JobID      int64              `json:"job_id"`
+	Executor   string             `json:"executor"`
+	Owner      string             `json:"owner"`
+	Status     TinyStatus         `json:"status"`
+	StartedAt  pgtype.Timestamptz `json:"started_at"`
+	FinishedAt pgtype.Timestamptz `json:"finished_at"`
+}
+
+type TinyOwnerWeight struct {
+	Owner  string `json:"owner"`
+	Weight int32  `json:"weight"`
+}
+
+type TinyPolicy struct {
+	ID        int64              `json:"id"`
+	Owner     string             `json:"owner"`
+	Role      string             `json:"role"`
+	Executor  string             `json:"executor"`
+	Operation string             `json:"operation"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type TinyRateLimit struct {
+	Executor  string             `json:"executor"`
+	Owner     string             `json:"owner"`
+	Rate      float64            `json:"rate"`
+	Burst     int32              `json:"burst"`
+	Tokens    float64            `json:"tokens"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type TinyWorker struct {
+	ID          string             `json:"id"`
+	Executor    string             `json:"executor"`
+	StartedAt   pgtype.Timestamptz `json:"started_at"`
+	HeartbeatAt pgtype.Timestamptz `json:"heartbeat_at"`
+	ExpiresAt   pgtype.Timestamptz `json:"expires_at"`
 }
@@ -2,8 +2,7 @@ package sqlc
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -29,7 +28,21 @@ func FromCtx(ctx context.Context) string {
 	return key
 }
 
+// NewScopedPgx creates a pool whose connections are scoped to the
+// owner found in the acquiring context. Connections acquired without
+// an owner are left unscoped, so queries through them return an error
+// instead of silently matching no rows. Records go to slog.Default()
 func NewScopedPgx(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+	return NewScopedPgxWithLogger(ctx, dsn, slog.Default())
+}
+
+// NewScopedPgxWithLogger is like NewScopedPgx, logging to `logger`.
+// A nil logger uses slog.Default()
+func NewScopedPgxWithLogger(ctx context.Context, dsn string, logger *slog.Logger) (*pgxpool.Pool, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
@@ -40,19 +53,23 @@ func NewScopedPgx(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
 	}
 	config.BeforeAcquire = func(_ctx context.Context, c *pgx.Conn) bool {
 		owner, ok := _ctx.Value(key).(string)
-		// Prevent setting owner to empty string
-		if !ok {
-			log.Fatal("no owner in context")
-			return false
+		// Prevent setting owner to empty string.
+		// Refusing the connection would make the pool retry
+		// until the context is done
+		if !ok || owner == "" {
+			logger.Warn("acquiring connection without owner in context")
+			_, err := c.Exec(_ctx, "reset tiny.owner")
+			return err == nil
 		}
 
-		log.Println("setting owner in borrowed connection to:", owner)
+		logger.Debug("setting owner in borrowed connection", "owner", owner)
 
-		// TODO: use positional arguments. Currently it throws a syntax error
+		// `set` doesn't accept parameters. Owners come from
+		// credentials, so they are never interpolated
 		// TODO: After reset, tiny.owner is still set but empty
-		_, err := c.Exec(_ctx, fmt.Sprintf(`set tiny.owner = '%s'`, owner))
+		_, err := c.Exec(_ctx, "select set_config('tiny.owner', $1, false)", owner)
 		if err != nil {
-			log.Fatal("failed to set owner in acquired connection")
+			logger.Error("failed to set owner in acquired connection", "owner", owner, "error", err)
 			return false
 		}
 
@@ -11,6 +11,109 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const advisoryUnlock = `-- name: AdvisoryUnlock :one
+select pg_advisory_unlock(hashtext('qron'), hashtext($1::text)) as unlocked
+`
+
+func (q *Queries) AdvisoryUnlock(ctx context.Context, name string) (bool, error) {
+	row := q.db.QueryRow(ctx, advisoryUnlock, name)
+	var unlocked bool
+	err := row.Scan(&unlocked)
+	return unlocked, err
+}
+
+const cancelJob = `-- name: CancelJob :one
+update tiny.job
+set status = case
+    when status = 'PENDING' then status
+    else 'CANCELLED'::tiny.status
+  end,
+  cancel_requested_at = now(),
+  updated_at = now()
+where id = $1
+and executor = $2
+and status in ('READY', 'PAUSED', 'PENDING')
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
+`
+
+type CancelJobParams struct {
+	ID       int64  `json:"id"`
+	Executor string `json:"executor"`
+}
+
+// Running jobs are only flagged. The worker holding
+// them is notified on its next heartbeat
+func (q *Queries) CancelJob(ctx context.Context, arg CancelJobParams) (TinyJob, error) {
+	row := q.db.QueryRow(ctx, cancelJob, arg.ID, arg.Executor)
+	var i TinyJob
+	err := row.Scan(
+		&i.ID,
+		&i.Expr,
+		&i.RunAt,
+		&i.LastRunAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.StartAt,
+		&i.ExecutionAmount,
+		&i.Retries,
+		&i.Name,
+		&i.Meta,
+		&i.Timeout,
+		&i.Status,
+		&i.State,
+		&i.Executor,
+		&i.Owner,
+		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
+	)
+	return i, err
+}
+
+const countJobsByStatus = `-- name: CountJobsByStatus :many
+select status, count(*) from tiny.job
+where executor = $1::text
+and ($2::text is null or owner = $2::text)
+group by status
+`
+
+type CountJobsByStatusParams struct {
+	Executor string      `json:"executor"`
+	Owner    pgtype.Text `json:"owner"`
+}
+
+type CountJobsByStatusRow struct {
+	Status TinyStatus `json:"status"`
+	Count  int64      `json:"count"`
+}
+
+// Counts jobs of every owner when owner is null
+func (q *Queries) CountJobsByStatus(ctx context.Context, arg CountJobsByStatusParams) ([]CountJobsByStatusRow, error) {
+	rows, err := q.db.Query(ctx, countJobsByStatus, arg.Executor, arg.Owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountJobsByStatusRow
+	for rows.Next() {
+		var i CountJobsByStatusRow
+		if err := rows.Scan(&i.Status, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const countJobsInStatus = `-- name: CountJobsInStatus :one
 select count(*) from tiny.job
 where executor = $1
@@ -29,40 +132,175 @@ func (q *Queries) CountJobsInStatus(ctx context.Context, arg CountJobsInStatusPa
 	return count, err
 }
 
-const createJob = `-- name: CreateJob :one
-insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key)
-values (
-  $1,
-  coalesce(nullif($2, ''), substr(md5(random()::text), 0, 25)),
-  $3,
-  'READY',
-  $4,
-  tiny.next(greatest($5, now()), $1),
-  coalesce(nullif($6, 0), 120),
-  $5,
-  $7,
-  coalesce(nullif($8, ''), 'default'),
-  coalesce(nullif($9, 0), 5),
-  $10
-)
-returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key
+const consumeRateLimit = `-- name: ConsumeRateLimit :exec
+update tiny.rate_limit
+set tokens = tokens - $1::int
+where executor = $2
+and owner = $3
 `
 
-type CreateJobParams struct {
+type ConsumeRateLimitParams struct {
+	Amount   int32  `json:"amount"`
+	Executor string `json:"executor"`
+	Owner    string `json:"owner"`
+}
+
+func (q *Queries) ConsumeRateLimit(ctx context.Context, arg ConsumeRateLimitParams) error {
+	_, err := q.db.Exec(ctx, consumeRateLimit, arg.Amount, arg.Executor, arg.Owner)
+	return err
+}
+
+type CopyImportJobsParams struct {
 	Expr             string             `json:"expr"`
-	Name             interface{}        `json:"name"`
+	Name             string             `json:"name"`
 	State            string             `json:"state"`
 	Executor         string             `json:"executor"`
 	StartAt          pgtype.Timestamptz `json:"start_at"`
-	Timeout          interface{}        `json:"timeout"`
+	Timeout          int32              `json:"timeout"`
 	Meta             []byte             `json:"meta"`
-	Owner            interface{}        `json:"owner"`
-	Retries          interface{}        `json:"retries"`
+	Owner            string             `json:"owner"`
+	Retries          int32              `json:"retries"`
 	DeduplicationKey pgtype.Text        `json:"deduplication_key"`
+	ConcurrencyKey   pgtype.Text        `json:"concurrency_key"`
+	ConcurrencyLimit int32              `json:"concurrency_limit"`
+}
+
+const createApiKey = `-- name: CreateApiKey :one
+insert into tiny.api_key (name, prefix, hash, owner, scopes, executors, roles, expires_at)
+values (
+  $1::text,
+  $2::text,
+  $3::text,
+  coalesce(nullif($4::text, ''), 'default'),
+  $5::text[],
+  $6::text[],
+  $7::text[],
+  $8::timestamptz
+)
+returning id, name, prefix, hash, owner, scopes, executors, created_at, expires_at, revoked_at, roles
+`
+
+type CreateApiKeyParams struct {
+	Name      string             `json:"name"`
+	Prefix    string             `json:"prefix"`
+	Hash      string             `json:"hash"`
+	Owner     string             `json:"owner"`
+	Scopes    []string           `json:"scopes"`
+	Executors []string           `json:"executors"`
+	Roles     []string           `json:"roles"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (TinyApiKey, error) {
+	row := q.db.QueryRow(ctx, createApiKey,
+		arg.Name,
+		arg.Prefix,
+		arg.Hash,
+		arg.Owner,
+		arg.Scopes,
+		arg.Executors,
+		arg.Roles,
+		arg.ExpiresAt,
+	)
+	var i TinyApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.Hash,
+		&i.Owner,
+		&i.Scopes,
+		&i.Executors,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.Roles,
+	)
+	return i, err
+}
+
+const createAuditEntry = `-- name: CreateAuditEntry :exec
+insert into tiny.audit_log (owner, subject, roles, executor, operation, reason)
+values (
+  coalesce(nullif($1::text, ''), 'default'),
+  $2::text,
+  $3::text[],
+  $4::text,
+  $5::text,
+  $6::text
+)
+`
+
+type CreateAuditEntryParams struct {
+	Owner     string   `json:"owner"`
+	Subject   string   `json:"subject"`
+	Roles     []string `json:"roles"`
+	Executor  string   `json:"executor"`
+	Operation string   `json:"operation"`
+	Reason    string   `json:"reason"`
+}
+
+func (q *Queries) CreateAuditEntry(ctx context.Context, arg CreateAuditEntryParams) error {
+	_, err := q.db.Exec(ctx, createAuditEntry,
+		arg.Owner,
+		arg.Subject,
+		arg.Roles,
+		arg.Executor,
+		arg.Operation,
+		arg.Reason,
+	)
+	return err
+}
+
+const createJob = `-- name: CreateJob :one
+insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key, concurrency_key, concurrency_limit)
+select
+  $1::text,
+  coalesce(nullif($2::text, ''), substr(md5(random()::text), 0, 25)),
+  $3::text,
+  'READY',
+  $4::text,
+  tiny.next(greatest($5::timestamptz, now()), $1::text),
+  coalesce(nullif($6::int, 0), 120),
+  $5::timestamptz,
+  $7::json,
+  coalesce(nullif($8::text, ''), 'default'),
+  coalesce(nullif($9::int, 0), 5),
+  $10::text,
+  $11::text,
+  coalesce(nullif($12::int, 0), 1)
+where not exists (
+  select 1 from tiny.job
+  where deduplication_key = $10::text
+  and owner = coalesce(nullif($8::text, ''), 'default')
+  and executor = $4::text
+  and (status in ('READY', 'PENDING', 'PAUSED')
+    or updated_at > now() - make_interval(mins => $13::int))
+)
+on conflict (owner, executor, deduplication_key)
+where status in ('READY', 'PENDING', 'PAUSED')
+do nothing
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
+`
+
+type CreateJobParams struct {
+	Expr                string             `json:"expr"`
+	Name                string             `json:"name"`
+	State               string             `json:"state"`
+	Executor            string             `json:"executor"`
+	StartAt             pgtype.Timestamptz `json:"start_at"`
+	Timeout             int32              `json:"timeout"`
+	Meta                []byte             `json:"meta"`
+	Owner               string             `json:"owner"`
+	Retries             int32              `json:"retries"`
+	DeduplicationKey    pgtype.Text        `json:"deduplication_key"`
+	ConcurrencyKey      pgtype.Text        `json:"concurrency_key"`
+	ConcurrencyLimit    int32              `json:"concurrency_limit"`
+	DeduplicationWindow pgtype.Int4        `json:"deduplication_window"`
 }
 
-// on conflict on constraint job_name_owner_key
-// do ...
+// A duplicate is an active job with the same key or one
+// that finished within the deduplication window
 func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (TinyJob, error) {
 	row := q.db.QueryRow(ctx, createJob,
 		arg.Expr,
@@ -75,6 +313,154 @@ func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (TinyJob,
 		arg.Owner,
 		arg.Retries,
 		arg.DeduplicationKey,
+		arg.ConcurrencyKey,
+		arg.ConcurrencyLimit,
+		arg.DeduplicationWindow,
+	)
+	var i TinyJob
+	err := row.Scan(
+		&i.ID,
+		&i.Expr,
+		&i.RunAt,
+		&i.LastRunAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.StartAt,
+		&i.ExecutionAmount,
+		&i.Retries,
+		&i.Name,
+		&i.Meta,
+		&i.Timeout,
+		&i.Status,
+		&i.State,
+		&i.Executor,
+		&i.Owner,
+		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
+	)
+	return i, err
+}
+
+const createOrUpdateJob = `-- name: CreateOrUpdateJob :one
+insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key, concurrency_key, concurrency_limit)
+select
+  $1::text,
+  $2::text,
+  $3::text,
+  'READY',
+  $4::text,
+  tiny.next(greatest($5::timestamptz, now()), $1::text),
+  coalesce(nullif($6::int, 0), 120),
+  $5::timestamptz,
+  $7::json,
+  coalesce(nullif($8::text, ''), 'default'),
+  coalesce(nullif($9::int, 0), 5),
+  $10::text,
+  $11::text,
+  coalesce(nullif($12::int, 0), 1)
+where not exists (
+  select 1 from tiny.job
+  where deduplication_key = $10::text
+  and owner = coalesce(nullif($8::text, ''), 'default')
+  and executor = $4::text
+  and name <> $2::text
+  and updated_at > now() - make_interval(mins => $13::int)
+)
+on conflict on constraint job_name_owner_key
+do update set
+  expr = case
+    when $14::text = 'KEEP_EXISTING' then tiny.job.expr
+    else excluded.expr
+  end,
+  meta = case
+    when $14::text = 'REPLACE' then excluded.meta
+    else tiny.job.meta
+  end,
+  state = case
+    when $14::text = 'REPLACE' then excluded.state
+    else tiny.job.state
+  end,
+  concurrency_key = case
+    when $14::text = 'KEEP_EXISTING' then tiny.job.concurrency_key
+    else excluded.concurrency_key
+  end,
+  concurrency_limit = case
+    when $14::text = 'KEEP_EXISTING' then tiny.job.concurrency_limit
+    else excluded.concurrency_limit
+  end,
+  timeout = case
+    when $14::text = 'KEEP_EXISTING' then tiny.job.timeout
+    else excluded.timeout
+  end,
+  retries = case
+    when $14::text = 'KEEP_EXISTING' then tiny.job.retries
+    else excluded.retries
+  end,
+  -- Finished jobs are made runnable again, as if newly created
+  status = case
+    when $14::text <> 'KEEP_EXISTING' and tiny.job.status in ('SUCCESS', 'FAILURE') then 'READY'
+    else tiny.job.status
+  end,
+  updated_at = case
+    when $14::text = 'KEEP_EXISTING' then tiny.job.updated_at
+    else now()
+  end,
+  -- ` + "`" + `run_at` + "`" + ` is recomputed only when the schedule actually changes,
+  -- so re-registering the same job does not shift its next execution
+  run_at = case
+    when $14::text = 'KEEP_EXISTING' then tiny.job.run_at
+    when tiny.job.status in ('SUCCESS', 'FAILURE') then excluded.run_at
+    when tiny.job.expr = excluded.expr then tiny.job.run_at
+    else tiny.next(coalesce(tiny.job.last_run_at, tiny.job.created_at), excluded.expr)
+  end
+where tiny.job.executor = excluded.executor
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
+`
+
+type CreateOrUpdateJobParams struct {
+	Expr                string             `json:"expr"`
+	Name                string             `json:"name"`
+	State               string             `json:"state"`
+	Executor            string             `json:"executor"`
+	StartAt             pgtype.Timestamptz `json:"start_at"`
+	Timeout             int32              `json:"timeout"`
+	Meta                []byte             `json:"meta"`
+	Owner               string             `json:"owner"`
+	Retries             int32              `json:"retries"`
+	DeduplicationKey    pgtype.Text        `json:"deduplication_key"`
+	ConcurrencyKey      pgtype.Text        `json:"concurrency_key"`
+	ConcurrencyLimit    int32              `json:"concurrency_limit"`
+	DeduplicationWindow pgtype.Int4        `json:"deduplication_window"`
+	Mode                string             `json:"mode"`
+}
+
+// Jobs that finished within the deduplication window still hold
+// their key. Active ones are rejected by the unique index
+// Names are scoped by owner, a job registered by a different
+// executor is never taken over
+func (q *Queries) CreateOrUpdateJob(ctx context.Context, arg CreateOrUpdateJobParams) (TinyJob, error) {
+	row := q.db.QueryRow(ctx, createOrUpdateJob,
+		arg.Expr,
+		arg.Name,
+		arg.State,
+		arg.Executor,
+		arg.StartAt,
+		arg.Timeout,
+		arg.Meta,
+		arg.Owner,
+		arg.Retries,
+		arg.DeduplicationKey,
+		arg.ConcurrencyKey,
+		arg.ConcurrencyLimit,
+		arg.DeduplicationWindow,
+		arg.Mode,
 	)
 	var i TinyJob
 	err := row.Scan(
@@ -95,6 +481,53 @@ func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (TinyJob,
 		&i.Executor,
 		&i.Owner,
 		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
+	)
+	return i, err
+}
+
+const createPolicy = `-- name: CreatePolicy :one
+insert into tiny.policy (owner, role, executor, operation)
+values (
+  coalesce(nullif($1::text, ''), 'default'),
+  $2::text,
+  $3::text,
+  $4::text
+)
+on conflict (owner, role, executor, operation) do update
+set role = excluded.role
+returning id, owner, role, executor, operation, created_at
+`
+
+type CreatePolicyParams struct {
+	Owner     string `json:"owner"`
+	Role      string `json:"role"`
+	Executor  string `json:"executor"`
+	Operation string `json:"operation"`
+}
+
+func (q *Queries) CreatePolicy(ctx context.Context, arg CreatePolicyParams) (TinyPolicy, error) {
+	row := q.db.QueryRow(ctx, createPolicy,
+		arg.Owner,
+		arg.Role,
+		arg.Executor,
+		arg.Operation,
+	)
+	var i TinyPolicy
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Role,
+		&i.Executor,
+		&i.Operation,
+		&i.CreatedAt,
 	)
 	return i, err
 }
@@ -119,11 +552,25 @@ func (q *Queries) CronNextRun(ctx context.Context, arg CronNextRunParams) (pgtyp
 	return run_at, err
 }
 
+const deleteExpiredWorkers = `-- name: DeleteExpiredWorkers :execrows
+delete from tiny.worker
+where executor = $1
+and expires_at < now()
+`
+
+func (q *Queries) DeleteExpiredWorkers(ctx context.Context, executor string) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredWorkers, executor)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deleteJobByID = `-- name: DeleteJobByID :one
 delete from tiny.job
 where id = $1
 and executor = $2 
-returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
 `
 
 type DeleteJobByIDParams struct {
@@ -152,99 +599,2036 @@ func (q *Queries) DeleteJobByID(ctx context.Context, arg DeleteJobByIDParams) (T
 		&i.Executor,
 		&i.Owner,
 		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
+	)
+	return i, err
+}
+
+const deleteJobByName = `-- name: DeleteJobByName :one
+delete from tiny.job
+where name = $1
+and executor = $2 
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
+`
+
+type DeleteJobByNameParams struct {
+	Name     string `json:"name"`
+	Executor string `json:"executor"`
+}
+
+func (q *Queries) DeleteJobByName(ctx context.Context, arg DeleteJobByNameParams) (TinyJob, error) {
+	row := q.db.QueryRow(ctx, deleteJobByName, arg.Name, arg.Executor)
+	var i TinyJob
+	err := row.Scan(
+		&i.ID,
+		&i.Expr,
+		&i.RunAt,
+		&i.LastRunAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.StartAt,
+		&i.ExecutionAmount,
+		&i.Retries,
+		&i.Name,
+		&i.Meta,
+		&i.Timeout,
+		&i.Status,
+		&i.State,
+		&i.Executor,
+		&i.Owner,
+		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
+	)
+	return i, err
+}
+
+const deleteJobsByFilter = `-- name: DeleteJobsByFilter :execrows
+delete from tiny.job
+where executor = $1::text
+and ($2::text is null or meta::jsonb @> ($2::text)::jsonb)
+and ($3::text is null or status::text = any(string_to_array($3::text, ',')))
+and ($4::timestamptz is null or created_at > $4::timestamptz)
+and ($5::timestamptz is null or created_at < $5::timestamptz)
+and ($6::text is null
+  or name like concat($6::text, '%')
+  or name like concat('%', $6::text))
+and ($7::boolean is null or tiny.is_one_shot(expr) = $7::boolean)
+`
+
+type DeleteJobsByFilterParams struct {
+	Executor  string             `json:"executor"`
+	Query     pgtype.Text        `json:"query"`
+	Statuses  pgtype.Text        `json:"statuses"`
+	From      pgtype.Timestamptz `json:"from"`
+	To        pgtype.Timestamptz `json:"to"`
+	Name      pgtype.Text        `json:"name"`
+	IsOneShot pgtype.Bool        `json:"is_one_shot"`
+}
+
+func (q *Queries) DeleteJobsByFilter(ctx context.Context, arg DeleteJobsByFilterParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteJobsByFilter,
+		arg.Executor,
+		arg.Query,
+		arg.Statuses,
+		arg.From,
+		arg.To,
+		arg.Name,
+		arg.IsOneShot,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deletePolicy = `-- name: DeletePolicy :one
+delete from tiny.policy
+where id = $1::bigint
+and owner = coalesce(nullif($2::text, ''), 'default')
+returning id, owner, role, executor, operation, created_at
+`
+
+type DeletePolicyParams struct {
+	ID    int64  `json:"id"`
+	Owner string `json:"owner"`
+}
+
+func (q *Queries) DeletePolicy(ctx context.Context, arg DeletePolicyParams) (TinyPolicy, error) {
+	row := q.db.QueryRow(ctx, deletePolicy, arg.ID, arg.Owner)
+	var i TinyPolicy
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Role,
+		&i.Executor,
+		&i.Operation,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteRateLimit = `-- name: DeleteRateLimit :exec
+delete from tiny.rate_limit
+where executor = $1
+and owner = $2
+`
+
+type DeleteRateLimitParams struct {
+	Executor string `json:"executor"`
+	Owner    string `json:"owner"`
+}
+
+func (q *Queries) DeleteRateLimit(ctx context.Context, arg DeleteRateLimitParams) error {
+	_, err := q.db.Exec(ctx, deleteRateLimit, arg.Executor, arg.Owner)
+	return err
+}
+
+const fetchDueJobs = `-- name: FetchDueJobs :many
+with due_jobs as (
+  select id
+  from tiny.job j
+  where j.run_at < now()
+    and j.status = 'READY'
+    and j.executor = $2
+    and not exists (
+      select 1 from tiny.executor e
+      where e.name = j.executor
+      and e.paused
+    )
+    and (
+      j.concurrency_key is null
+      or (
+        -- keys locked by LockConcurrencyKeys, jobs that became
+        -- due after the lock was taken wait for the next fetch
+        j.owner || ':' || j.concurrency_key = any($3::text[])
+        and (
+          select count(*)
+          from tiny.job p
+          where p.owner = j.owner
+            and p.concurrency_key = j.concurrency_key
+            and p.status = 'PENDING'
+        ) + (
+          -- due jobs of this executor sharing the key that would be fetched first
+          select count(*)
+          from (
+            select 1
+            from tiny.job s
+            where s.owner = j.owner
+              and s.concurrency_key = j.concurrency_key
+              and s.executor = j.executor
+              and s.status = 'READY'
+              and s.run_at < now()
+              and (s.created_at, s.id) < (j.created_at, j.id)
+            limit j.concurrency_limit
+          ) as preceding
+        ) < j.concurrency_limit
+      )
+    )
+    and not exists (
+      -- owners without rate limit budget left for this job
+      select 1
+      from tiny.rate_limit r
+      where r.executor = j.executor
+        and r.owner = j.owner
+        and (
+          select count(*)
+          from (
+            select 1
+            from tiny.job s
+            where s.executor = j.executor
+              and s.owner = j.owner
+              and s.status = 'READY'
+              and s.run_at < now()
+              and (s.created_at, s.id) < (j.created_at, j.id)
+            limit greatest(floor(r.tokens), 0)::int
+          ) as preceding
+        ) >= floor(r.tokens)
+    )
+  order by j.created_at
+  limit $1
+  for update skip locked
+)
+update tiny.job as updated_jobs
+set status = 'PENDING',
+  updated_at = now(),
+  last_run_at = now(),
+  lease = updated_jobs.lease + 1,
+  locked_by = $4::text,
+  locked_at = now()
+from due_jobs
+where due_jobs.id = updated_jobs.id
+returning updated_jobs.id, updated_jobs.expr, updated_jobs.run_at, updated_jobs.last_run_at, updated_jobs.created_at, updated_jobs.updated_at, updated_jobs.start_at, updated_jobs.execution_amount, updated_jobs.retries, updated_jobs.name, updated_jobs.meta, updated_jobs.timeout, updated_jobs.status, updated_jobs.state, updated_jobs.executor, updated_jobs.owner, updated_jobs.deduplication_key, updated_jobs.concurrency_key, updated_jobs.concurrency_limit, updated_jobs.lease, updated_jobs.locked_by, updated_jobs.locked_at, updated_jobs.cancel_requested_at, updated_jobs.reset_count, updated_jobs.quarantined_at
+`
+
+type FetchDueJobsParams struct {
+	Limit    int32       `json:"limit"`
+	Executor string      `json:"executor"`
+	Locked   []string    `json:"locked"`
+	Worker   pgtype.Text `json:"worker"`
+}
+
+func (q *Queries) FetchDueJobs(ctx context.Context, arg FetchDueJobsParams) ([]TinyJob, error) {
+	rows, err := q.db.Query(ctx, fetchDueJobs, arg.Limit, arg.Executor, arg.Locked, arg.Worker)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyJob
+	for rows.Next() {
+		var i TinyJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const fetchDueJobsFair = `-- name: FetchDueJobsFair :many
+with owners as (
+  select o.owner, coalesce(w.weight, 1) as weight
+  from (
+    select distinct j.owner
+    from tiny.job j
+    where j.run_at < now()
+      and j.status = 'READY'
+      and j.executor = $2
+      and not exists (
+        select 1 from tiny.executor e
+        where e.name = j.executor
+        and e.paused
+      )
+  ) as o
+  left join tiny.owner_weight w on w.owner = o.owner
+),
+candidates as (
+  -- oldest due jobs of every owner, ranked by
+  -- their position in the owner queue over its weight
+  select
+    c.id,
+    c.created_at,
+    row_number() over (partition by owners.owner order by c.created_at, c.id)::float / owners.weight as share
+  from owners
+  cross join lateral (
+    select j.id, j.created_at
+    from tiny.job j
+    where j.owner = owners.owner
+      and j.run_at < now()
+      and j.status = 'READY'
+      and j.executor = $2
+      and (
+        j.concurrency_key is null
+        or (
+          -- keys locked by LockConcurrencyKeys, jobs that became
+          -- due after the lock was taken wait for the next fetch
+          j.owner || ':' || j.concurrency_key = any($3::text[])
+          and (
+            select count(*)
+            from tiny.job p
+            where p.owner = j.owner
+              and p.concurrency_key = j.concurrency_key
+              and p.status = 'PENDING'
+          ) + (
+            -- due jobs of this executor sharing the key that would be fetched first
+            select count(*)
+            from (
+              select 1
+              from tiny.job s
+              where s.owner = j.owner
+                and s.concurrency_key = j.concurrency_key
+                and s.executor = j.executor
+                and s.status = 'READY'
+                and s.run_at < now()
+                and (s.created_at, s.id) < (j.created_at, j.id)
+              limit j.concurrency_limit
+            ) as preceding
+          ) < j.concurrency_limit
+        )
+      )
+      and not exists (
+        -- owners without rate limit budget left for this job
+        select 1
+        from tiny.rate_limit r
+        where r.executor = j.executor
+          and r.owner = j.owner
+          and (
+            select count(*)
+            from (
+              select 1
+              from tiny.job s
+              where s.executor = j.executor
+                and s.owner = j.owner
+                and s.status = 'READY'
+                and s.run_at < now()
+                and (s.created_at, s.id) < (j.created_at, j.id)
+              limit greatest(floor(r.tokens), 0)::int
+            ) as preceding
+          ) >= floor(r.tokens)
+      )
+    order by j.created_at
+    limit $1
+    for update skip locked
+  ) as c
+),
+due_jobs as (
+  select id
+  from candidates
+  order by share, created_at
+  limit $1
+)
+update tiny.job as updated_jobs
+set status = 'PENDING',
+  updated_at = now(),
+  last_run_at = now(),
+  lease = updated_jobs.lease + 1,
+  locked_by = $4::text,
+  locked_at = now()
+from due_jobs
+where due_jobs.id = updated_jobs.id
+returning updated_jobs.id, updated_jobs.expr, updated_jobs.run_at, updated_jobs.last_run_at, updated_jobs.created_at, updated_jobs.updated_at, updated_jobs.start_at, updated_jobs.execution_amount, updated_jobs.retries, updated_jobs.name, updated_jobs.meta, updated_jobs.timeout, updated_jobs.status, updated_jobs.state, updated_jobs.executor, updated_jobs.owner, updated_jobs.deduplication_key, updated_jobs.concurrency_key, updated_jobs.concurrency_limit, updated_jobs.lease, updated_jobs.locked_by, updated_jobs.locked_at, updated_jobs.cancel_requested_at, updated_jobs.reset_count, updated_jobs.quarantined_at
+`
+
+type FetchDueJobsFairParams struct {
+	Limit    int32       `json:"limit"`
+	Executor string      `json:"executor"`
+	Locked   []string    `json:"locked"`
+	Worker   pgtype.Text `json:"worker"`
+}
+
+// Interleaves due jobs across owners so that a single owner
+// with a large backlog cannot starve the others. Owners get a
+// share of every fetch proportional to their weight.
+// Up to `limit` jobs per owner are locked while ranking them
+func (q *Queries) FetchDueJobsFair(ctx context.Context, arg FetchDueJobsFairParams) ([]TinyJob, error) {
+	rows, err := q.db.Query(ctx, fetchDueJobsFair, arg.Limit, arg.Executor, arg.Locked, arg.Worker)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyJob
+	for rows.Next() {
+		var i TinyJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getActiveApiKey = `-- name: GetActiveApiKey :one
+select id, name, prefix, hash, owner, scopes, executors, created_at, expires_at, revoked_at, roles from tiny.api_key
+where hash = $1::text
+and revoked_at is null
+and (expires_at is null or expires_at > now())
+`
+
+func (q *Queries) GetActiveApiKey(ctx context.Context, hash string) (TinyApiKey, error) {
+	row := q.db.QueryRow(ctx, getActiveApiKey, hash)
+	var i TinyApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.Hash,
+		&i.Owner,
+		&i.Scopes,
+		&i.Executors,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.Roles,
+	)
+	return i, err
+}
+
+const getDuplicatedJob = `-- name: GetDuplicatedJob :one
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where deduplication_key = $1::text
+and owner = coalesce(nullif($2::text, ''), 'default')
+and executor = $3::text
+and (status in ('READY', 'PENDING', 'PAUSED')
+  or updated_at > now() - make_interval(mins => $4::int))
+order by id desc
+limit 1
+`
+
+type GetDuplicatedJobParams struct {
+	DeduplicationKey    string      `json:"deduplication_key"`
+	Owner               string      `json:"owner"`
+	Executor            string      `json:"executor"`
+	DeduplicationWindow pgtype.Int4 `json:"deduplication_window"`
+}
+
+func (q *Queries) GetDuplicatedJob(ctx context.Context, arg GetDuplicatedJobParams) (TinyJob, error) {
+	row := q.db.QueryRow(ctx, getDuplicatedJob,
+		arg.DeduplicationKey,
+		arg.Owner,
+		arg.Executor,
+		arg.DeduplicationWindow,
+	)
+	var i TinyJob
+	err := row.Scan(
+		&i.ID,
+		&i.Expr,
+		&i.RunAt,
+		&i.LastRunAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.StartAt,
+		&i.ExecutionAmount,
+		&i.Retries,
+		&i.Name,
+		&i.Meta,
+		&i.Timeout,
+		&i.Status,
+		&i.State,
+		&i.Executor,
+		&i.Owner,
+		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
+	)
+	return i, err
+}
+
+const getJobByID = `-- name: GetJobByID :one
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where id = $1
+and executor = $2 
+limit 1
+`
+
+type GetJobByIDParams struct {
+	ID       int64  `json:"id"`
+	Executor string `json:"executor"`
+}
+
+func (q *Queries) GetJobByID(ctx context.Context, arg GetJobByIDParams) (TinyJob, error) {
+	row := q.db.QueryRow(ctx, getJobByID, arg.ID, arg.Executor)
+	var i TinyJob
+	err := row.Scan(
+		&i.ID,
+		&i.Expr,
+		&i.RunAt,
+		&i.LastRunAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.StartAt,
+		&i.ExecutionAmount,
+		&i.Retries,
+		&i.Name,
+		&i.Meta,
+		&i.Timeout,
+		&i.Status,
+		&i.State,
+		&i.Executor,
+		&i.Owner,
+		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
+	)
+	return i, err
+}
+
+const getJobByName = `-- name: GetJobByName :one
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where name = $1 
+and executor = $2
+limit 1
+`
+
+type GetJobByNameParams struct {
+	Name     string `json:"name"`
+	Executor string `json:"executor"`
+}
+
+func (q *Queries) GetJobByName(ctx context.Context, arg GetJobByNameParams) (TinyJob, error) {
+	row := q.db.QueryRow(ctx, getJobByName, arg.Name, arg.Executor)
+	var i TinyJob
+	err := row.Scan(
+		&i.ID,
+		&i.Expr,
+		&i.RunAt,
+		&i.LastRunAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.StartAt,
+		&i.ExecutionAmount,
+		&i.Retries,
+		&i.Name,
+		&i.Meta,
+		&i.Timeout,
+		&i.Status,
+		&i.State,
+		&i.Executor,
+		&i.Owner,
+		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
+	)
+	return i, err
+}
+
+const heartbeatWorker = `-- name: HeartbeatWorker :one
+insert into tiny.worker (id, executor, expires_at)
+values (
+  $1,
+  $2,
+  now() + make_interval(secs => $3::int)
+)
+on conflict (id, executor) do update
+set heartbeat_at = now(),
+  expires_at = excluded.expires_at
+returning id, executor, started_at, heartbeat_at, expires_at
+`
+
+type HeartbeatWorkerParams struct {
+	ID       string `json:"id"`
+	Executor string `json:"executor"`
+	Ttl      int32  `json:"ttl"`
+}
+
+func (q *Queries) HeartbeatWorker(ctx context.Context, arg HeartbeatWorkerParams) (TinyWorker, error) {
+	row := q.db.QueryRow(ctx, heartbeatWorker, arg.ID, arg.Executor, arg.Ttl)
+	var i TinyWorker
+	err := row.Scan(
+		&i.ID,
+		&i.Executor,
+		&i.StartedAt,
+		&i.HeartbeatAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const importStagedJobs = `-- name: ImportStagedJobs :execrows
+with staged as (
+  delete from tiny.job_import
+  returning expr, name, state, executor, start_at, timeout, meta, owner, retries, deduplication_key, concurrency_key, concurrency_limit
+)
+insert into tiny.job(expr, name, state, status, executor, run_at, timeout, start_at, meta, owner, retries, deduplication_key, concurrency_key, concurrency_limit)
+select
+  expr,
+  coalesce(nullif(name, ''), substr(md5(random()::text), 0, 25)),
+  state,
+  'READY',
+  executor,
+  tiny.next(greatest(coalesce(start_at, now()), now()), expr),
+  coalesce(nullif(timeout, 0), 120),
+  coalesce(start_at, now()),
+  meta,
+  coalesce(nullif(owner, ''), 'default'),
+  coalesce(nullif(retries, 0), 5),
+  deduplication_key,
+  concurrency_key,
+  coalesce(nullif(concurrency_limit, 0), 1)
+from staged
+on conflict do nothing
+`
+
+// Jobs with a name or deduplication key already
+// taken by an active job are skipped
+func (q *Queries) ImportStagedJobs(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, importStagedJobs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const isAllowed = `-- name: IsAllowed :one
+select exists (
+  select 1 from tiny.policy
+  where owner = coalesce(nullif($1::text, ''), 'default')
+  and role = any($2::text[])
+  and executor in ($3::text, '*')
+  and operation in ($4::text, '*')
+)
+`
+
+type IsAllowedParams struct {
+	Owner     string   `json:"owner"`
+	Roles     []string `json:"roles"`
+	Executor  string   `json:"executor"`
+	Operation string   `json:"operation"`
+}
+
+// Whether any of the roles is granted the operation on the executor
+func (q *Queries) IsAllowed(ctx context.Context, arg IsAllowedParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isAllowed,
+		arg.Owner,
+		arg.Roles,
+		arg.Executor,
+		arg.Operation,
+	)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const lastUpdate = `-- name: LastUpdate :one
+select max(updated_at)::timestamptz as last_update 
+from tiny.job
+where executor = $1
+`
+
+func (q *Queries) LastUpdate(ctx context.Context, executor string) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, lastUpdate, executor)
+	var last_update pgtype.Timestamptz
+	err := row.Scan(&last_update)
+	return last_update, err
+}
+
+const listAnomalies = `-- name: ListAnomalies :many
+select
+  (case
+    when reset_count >= $2::int then 'REPEATED_RESETS'
+    when tiny.is_one_shot(expr) then 'OVERDUE'
+    else 'STALE_CRON'
+  end)::text as kind,
+  id,
+  name,
+  expr,
+  status,
+  owner,
+  run_at,
+  last_run_at,
+  reset_count
+from tiny.job
+where executor = $1::text
+and status in ('READY', 'PENDING')
+and (
+  reset_count >= $2::int
+  or (status = 'READY'
+    and tiny.is_one_shot(expr)
+    and run_at < now() - make_interval(secs => $3::int))
+  or (status = 'READY'
+    and not tiny.is_one_shot(expr)
+    and run_at < now() - make_interval(secs => $4::int))
+)
+order by run_at
+limit $5
+`
+
+type ListAnomaliesParams struct {
+	Executor       string `json:"executor"`
+	MaxResets      int32  `json:"max_resets"`
+	OverdueAfter   int32  `json:"overdue_after"`
+	StaleCronAfter int32  `json:"stale_cron_after"`
+	Limit          int32  `json:"limit"`
+}
+
+type ListAnomaliesRow struct {
+	Kind          string             `json:"kind"`
+	ID            int64              `json:"id"`
+	Name          string             `json:"name"`
+	Expr          string             `json:"expr"`
+	Status        TinyStatus         `json:"status"`
+	Owner         string             `json:"owner"`
+	RunAt         pgtype.Timestamptz `json:"run_at"`
+	LastRunAt     pgtype.Timestamptz `json:"last_run_at"`
+	ResetCount    int32              `json:"reset_count"`
+	QuarantinedAt pgtype.Timestamptz `json:"quarantined_at"`
+}
+
+// Jobs that keep timing out, one shot jobs overdue and
+// cron jobs lagging behind their schedule
+func (q *Queries) ListAnomalies(ctx context.Context, arg ListAnomaliesParams) ([]ListAnomaliesRow, error) {
+	rows, err := q.db.Query(ctx, listAnomalies,
+		arg.Executor,
+		arg.MaxResets,
+		arg.OverdueAfter,
+		arg.StaleCronAfter,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAnomaliesRow
+	for rows.Next() {
+		var i ListAnomaliesRow
+		if err := rows.Scan(
+			&i.Kind,
+			&i.ID,
+			&i.Name,
+			&i.Expr,
+			&i.Status,
+			&i.Owner,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listApiKeys = `-- name: ListApiKeys :many
+select id, name, prefix, hash, owner, scopes, executors, created_at, expires_at, revoked_at, roles from tiny.api_key
+where owner = coalesce(nullif($1::text, ''), 'default')
+order by id
+`
+
+func (q *Queries) ListApiKeys(ctx context.Context, owner string) ([]TinyApiKey, error) {
+	rows, err := q.db.Query(ctx, listApiKeys, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyApiKey
+	for rows.Next() {
+		var i TinyApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Prefix,
+			&i.Hash,
+			&i.Owner,
+			&i.Scopes,
+			&i.Executors,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.Roles,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditLog = `-- name: ListAuditLog :many
+select id, owner, subject, roles, executor, operation, reason, created_at from tiny.audit_log
+where owner = coalesce(nullif($1::text, ''), 'default')
+and ($2::text is null or executor = $2::text)
+order by created_at desc, id desc
+limit $3::int
+`
+
+type ListAuditLogParams struct {
+	Owner    string      `json:"owner"`
+	Executor pgtype.Text `json:"executor"`
+	Limit    int32       `json:"limit"`
+}
+
+func (q *Queries) ListAuditLog(ctx context.Context, arg ListAuditLogParams) ([]TinyAuditLog, error) {
+	rows, err := q.db.Query(ctx, listAuditLog, arg.Owner, arg.Executor, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyAuditLog
+	for rows.Next() {
+		var i TinyAuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Subject,
+			&i.Roles,
+			&i.Executor,
+			&i.Operation,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCancelRequestedJobs = `-- name: ListCancelRequestedJobs :many
+select id from tiny.job
+where locked_by = $1::text
+and executor = $2::text
+and status = 'PENDING'
+and cancel_requested_at is not null
+`
+
+type ListCancelRequestedJobsParams struct {
+	Worker   string `json:"worker"`
+	Executor string `json:"executor"`
+}
+
+func (q *Queries) ListCancelRequestedJobs(ctx context.Context, arg ListCancelRequestedJobsParams) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listCancelRequestedJobs, arg.Worker, arg.Executor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExecutorStats = `-- name: ListExecutorStats :many
+with jobs as (
+  select
+    executor,
+    count(*) filter (where status = 'READY') as ready,
+    count(*) filter (where status = 'PENDING') as pending,
+    count(*) filter (where status = 'FAILURE') as failure,
+    count(*) filter (where status = 'SUCCESS') as success,
+    count(*) filter (where status = 'PAUSED') as paused,
+    count(*) filter (where status = 'CANCELLED') as cancelled,
+    count(*) filter (where status = 'READY' and run_at <= now()) as due,
+    min(run_at) filter (where status = 'READY') as oldest_ready_run_at
+  from tiny.job
+  where $1::text is null or executor = $1::text
+  group by executor
+),
+runs as (
+  select
+    executor,
+    avg(extract(epoch from finished_at - started_at)::float8) as avg_duration,
+    percentile_cont(0.5) within group (order by extract(epoch from finished_at - started_at)::float8) as p50_duration,
+    percentile_cont(0.95) within group (order by extract(epoch from finished_at - started_at)::float8) as p95_duration,
+    percentile_cont(0.99) within group (order by extract(epoch from finished_at - started_at)::float8) as p99_duration,
+    count(*) filter (where finished_at > now() - interval '1 minute') as throughput_1m,
+    count(*) filter (where finished_at > now() - interval '5 minutes') as throughput_5m,
+    count(*) as throughput_1h
+  from tiny.job_run
+  where finished_at > now() - interval '1 hour'
+  and ($1::text is null or executor = $1::text)
+  group by executor
+)
+select
+  jobs.executor,
+  jobs.ready,
+  jobs.pending,
+  jobs.failure,
+  jobs.success,
+  jobs.paused,
+  jobs.cancelled,
+  jobs.due,
+  jobs.oldest_ready_run_at::timestamptz as oldest_ready_run_at,
+  coalesce(runs.avg_duration, 0)::float8 as avg_duration,
+  coalesce(runs.p50_duration, 0)::float8 as p50_duration,
+  coalesce(runs.p95_duration, 0)::float8 as p95_duration,
+  coalesce(runs.p99_duration, 0)::float8 as p99_duration,
+  coalesce(runs.throughput_1m, 0)::bigint as throughput_1m,
+  coalesce(runs.throughput_5m, 0)::bigint as throughput_5m,
+  coalesce(runs.throughput_1h, 0)::bigint as throughput_1h
+from jobs
+left join runs on runs.executor = jobs.executor
+order by jobs.executor
+`
+
+type ListExecutorStatsRow struct {
+	Executor         string             `json:"executor"`
+	Ready            int64              `json:"ready"`
+	Pending          int64              `json:"pending"`
+	Failure          int64              `json:"failure"`
+	Success          int64              `json:"success"`
+	Paused           int64              `json:"paused"`
+	Cancelled        int64              `json:"cancelled"`
+	Due              int64              `json:"due"`
+	OldestReadyRunAt pgtype.Timestamptz `json:"oldest_ready_run_at"`
+	AvgDuration      float64            `json:"avg_duration"`
+	P50Duration      float64            `json:"p50_duration"`
+	P95Duration      float64            `json:"p95_duration"`
+	P99Duration      float64            `json:"p99_duration"`
+	Throughput1m     int64              `json:"throughput_1m"`
+	Throughput5m     int64              `json:"throughput_5m"`
+	Throughput1h     int64              `json:"throughput_1h"`
+}
+
+// Durations (in seconds) and throughput are computed from runs
+// finished in the last hour. Stats of every executor are
+// returned when `executor` is null
+func (q *Queries) ListExecutorStats(ctx context.Context, executor pgtype.Text) ([]ListExecutorStatsRow, error) {
+	rows, err := q.db.Query(ctx, listExecutorStats, executor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListExecutorStatsRow
+	for rows.Next() {
+		var i ListExecutorStatsRow
+		if err := rows.Scan(
+			&i.Executor,
+			&i.Ready,
+			&i.Pending,
+			&i.Failure,
+			&i.Success,
+			&i.Paused,
+			&i.Cancelled,
+			&i.Due,
+			&i.OldestReadyRunAt,
+			&i.AvgDuration,
+			&i.P50Duration,
+			&i.P95Duration,
+			&i.P99Duration,
+			&i.Throughput1m,
+			&i.Throughput5m,
+			&i.Throughput1h,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listJobsByIDAsc = `-- name: ListJobsByIDAsc :many
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where executor = $1::text
+and ($2::text is null or status::text = any(string_to_array($2::text, ',')))
+and ($3::boolean is null or tiny.is_one_shot(expr) = $3::boolean)
+and ($4::text is null
+  or name like concat($4::text, '%')
+  or name like concat('%', $4::text))
+and ($5::text is null or owner = $5::text)
+and ($6::text is null or meta::jsonb @> ($6::text)::jsonb)
+and ($7::text is null or meta::jsonb @? ($7::text)::jsonpath)
+and ($8::timestamptz is null or run_at > $8::timestamptz)
+and ($9::timestamptz is null or run_at < $9::timestamptz)
+and ($10::timestamptz is null or updated_at > $10::timestamptz)
+and ($11::timestamptz is null or updated_at < $11::timestamptz)
+and id > coalesce($12::bigint, 0)
+order by id
+limit $13::int
+`
+
+type ListJobsByIDAscParams struct {
+	Executor      string             `json:"executor"`
+	Statuses      pgtype.Text        `json:"statuses"`
+	IsOneShot     pgtype.Bool        `json:"is_one_shot"`
+	Name          pgtype.Text        `json:"name"`
+	Owner         pgtype.Text        `json:"owner"`
+	Meta          pgtype.Text        `json:"meta"`
+	MetaPath      pgtype.Text        `json:"meta_path"`
+	RunAfter      pgtype.Timestamptz `json:"run_after"`
+	RunBefore     pgtype.Timestamptz `json:"run_before"`
+	UpdatedAfter  pgtype.Timestamptz `json:"updated_after"`
+	UpdatedBefore pgtype.Timestamptz `json:"updated_before"`
+	AfterID       pgtype.Int8        `json:"after_id"`
+	Limit         int32              `json:"limit"`
+}
+
+// Jobs are paginated with keyset cursors, so pages are
+// as fast as the first one regardless of the table size.
+// Walks job_executor_id_idx; a missing cursor falls back to a sentinel
+// so the range condition stays usable by generic plans
+func (q *Queries) ListJobsByIDAsc(ctx context.Context, arg ListJobsByIDAscParams) ([]TinyJob, error) {
+	rows, err := q.db.Query(ctx, listJobsByIDAsc,
+		arg.Executor,
+		arg.Statuses,
+		arg.IsOneShot,
+		arg.Name,
+		arg.Owner,
+		arg.Meta,
+		arg.MetaPath,
+		arg.RunAfter,
+		arg.RunBefore,
+		arg.UpdatedAfter,
+		arg.UpdatedBefore,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyJob
+	for rows.Next() {
+		var i TinyJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listJobsByIDDesc = `-- name: ListJobsByIDDesc :many
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where executor = $1::text
+and ($2::text is null or status::text = any(string_to_array($2::text, ',')))
+and ($3::boolean is null or tiny.is_one_shot(expr) = $3::boolean)
+and ($4::text is null
+  or name like concat($4::text, '%')
+  or name like concat('%', $4::text))
+and ($5::text is null or owner = $5::text)
+and ($6::text is null or meta::jsonb @> ($6::text)::jsonb)
+and ($7::text is null or meta::jsonb @? ($7::text)::jsonpath)
+and ($8::timestamptz is null or run_at > $8::timestamptz)
+and ($9::timestamptz is null or run_at < $9::timestamptz)
+and ($10::timestamptz is null or updated_at > $10::timestamptz)
+and ($11::timestamptz is null or updated_at < $11::timestamptz)
+and id < coalesce($12::bigint, 9223372036854775807)
+order by id desc
+limit $13::int
+`
+
+type ListJobsByIDDescParams struct {
+	Executor      string             `json:"executor"`
+	Statuses      pgtype.Text        `json:"statuses"`
+	IsOneShot     pgtype.Bool        `json:"is_one_shot"`
+	Name          pgtype.Text        `json:"name"`
+	Owner         pgtype.Text        `json:"owner"`
+	Meta          pgtype.Text        `json:"meta"`
+	MetaPath      pgtype.Text        `json:"meta_path"`
+	RunAfter      pgtype.Timestamptz `json:"run_after"`
+	RunBefore     pgtype.Timestamptz `json:"run_before"`
+	UpdatedAfter  pgtype.Timestamptz `json:"updated_after"`
+	UpdatedBefore pgtype.Timestamptz `json:"updated_before"`
+	AfterID       pgtype.Int8        `json:"after_id"`
+	Limit         int32              `json:"limit"`
+}
+
+// Walks job_executor_id_idx
+func (q *Queries) ListJobsByIDDesc(ctx context.Context, arg ListJobsByIDDescParams) ([]TinyJob, error) {
+	rows, err := q.db.Query(ctx, listJobsByIDDesc,
+		arg.Executor,
+		arg.Statuses,
+		arg.IsOneShot,
+		arg.Name,
+		arg.Owner,
+		arg.Meta,
+		arg.MetaPath,
+		arg.RunAfter,
+		arg.RunBefore,
+		arg.UpdatedAfter,
+		arg.UpdatedBefore,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyJob
+	for rows.Next() {
+		var i TinyJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listJobsByRunAtAsc = `-- name: ListJobsByRunAtAsc :many
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where executor = $1::text
+and ($2::text is null or status::text = any(string_to_array($2::text, ',')))
+and ($3::boolean is null or tiny.is_one_shot(expr) = $3::boolean)
+and ($4::text is null
+  or name like concat($4::text, '%')
+  or name like concat('%', $4::text))
+and ($5::text is null or owner = $5::text)
+and ($6::text is null or meta::jsonb @> ($6::text)::jsonb)
+and ($7::text is null or meta::jsonb @? ($7::text)::jsonpath)
+and ($8::timestamptz is null or run_at > $8::timestamptz)
+and ($9::timestamptz is null or run_at < $9::timestamptz)
+and ($10::timestamptz is null or updated_at > $10::timestamptz)
+and ($11::timestamptz is null or updated_at < $11::timestamptz)
+and (run_at, id) > (coalesce($12::timestamptz, '-infinity'), coalesce($13::bigint, 0))
+order by run_at, id
+limit $14::int
+`
+
+type ListJobsByRunAtAscParams struct {
+	Executor      string             `json:"executor"`
+	Statuses      pgtype.Text        `json:"statuses"`
+	IsOneShot     pgtype.Bool        `json:"is_one_shot"`
+	Name          pgtype.Text        `json:"name"`
+	Owner         pgtype.Text        `json:"owner"`
+	Meta          pgtype.Text        `json:"meta"`
+	MetaPath      pgtype.Text        `json:"meta_path"`
+	RunAfter      pgtype.Timestamptz `json:"run_after"`
+	RunBefore     pgtype.Timestamptz `json:"run_before"`
+	UpdatedAfter  pgtype.Timestamptz `json:"updated_after"`
+	UpdatedBefore pgtype.Timestamptz `json:"updated_before"`
+	AfterAt       pgtype.Timestamptz `json:"after_at"`
+	AfterID       pgtype.Int8        `json:"after_id"`
+	Limit         int32              `json:"limit"`
+}
+
+// Walks job_executor_run_at_idx
+func (q *Queries) ListJobsByRunAtAsc(ctx context.Context, arg ListJobsByRunAtAscParams) ([]TinyJob, error) {
+	rows, err := q.db.Query(ctx, listJobsByRunAtAsc,
+		arg.Executor,
+		arg.Statuses,
+		arg.IsOneShot,
+		arg.Name,
+		arg.Owner,
+		arg.Meta,
+		arg.MetaPath,
+		arg.RunAfter,
+		arg.RunBefore,
+		arg.UpdatedAfter,
+		arg.UpdatedBefore,
+		arg.AfterAt,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyJob
+	for rows.Next() {
+		var i TinyJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listJobsByRunAtDesc = `-- name: ListJobsByRunAtDesc :many
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where executor = $1::text
+and ($2::text is null or status::text = any(string_to_array($2::text, ',')))
+and ($3::boolean is null or tiny.is_one_shot(expr) = $3::boolean)
+and ($4::text is null
+  or name like concat($4::text, '%')
+  or name like concat('%', $4::text))
+and ($5::text is null or owner = $5::text)
+and ($6::text is null or meta::jsonb @> ($6::text)::jsonb)
+and ($7::text is null or meta::jsonb @? ($7::text)::jsonpath)
+and ($8::timestamptz is null or run_at > $8::timestamptz)
+and ($9::timestamptz is null or run_at < $9::timestamptz)
+and ($10::timestamptz is null or updated_at > $10::timestamptz)
+and ($11::timestamptz is null or updated_at < $11::timestamptz)
+and (run_at, id) < (coalesce($12::timestamptz, 'infinity'), coalesce($13::bigint, 0))
+order by run_at desc, id desc
+limit $14::int
+`
+
+type ListJobsByRunAtDescParams struct {
+	Executor      string             `json:"executor"`
+	Statuses      pgtype.Text        `json:"statuses"`
+	IsOneShot     pgtype.Bool        `json:"is_one_shot"`
+	Name          pgtype.Text        `json:"name"`
+	Owner         pgtype.Text        `json:"owner"`
+	Meta          pgtype.Text        `json:"meta"`
+	MetaPath      pgtype.Text        `json:"meta_path"`
+	RunAfter      pgtype.Timestamptz `json:"run_after"`
+	RunBefore     pgtype.Timestamptz `json:"run_before"`
+	UpdatedAfter  pgtype.Timestamptz `json:"updated_after"`
+	UpdatedBefore pgtype.Timestamptz `json:"updated_before"`
+	AfterAt       pgtype.Timestamptz `json:"after_at"`
+	AfterID       pgtype.Int8        `json:"after_id"`
+	Limit         int32              `json:"limit"`
+}
+
+// Walks job_executor_run_at_idx
+func (q *Queries) ListJobsByRunAtDesc(ctx context.Context, arg ListJobsByRunAtDescParams) ([]TinyJob, error) {
+	rows, err := q.db.Query(ctx, listJobsByRunAtDesc,
+		arg.Executor,
+		arg.Statuses,
+		arg.IsOneShot,
+		arg.Name,
+		arg.Owner,
+		arg.Meta,
+		arg.MetaPath,
+		arg.RunAfter,
+		arg.RunBefore,
+		arg.UpdatedAfter,
+		arg.UpdatedBefore,
+		arg.AfterAt,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyJob
+	for rows.Next() {
+		var i TinyJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listJobsByUpdatedAtAsc = `-- name: ListJobsByUpdatedAtAsc :many
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where executor = $1::text
+and ($2::text is null or status::text = any(string_to_array($2::text, ',')))
+and ($3::boolean is null or tiny.is_one_shot(expr) = $3::boolean)
+and ($4::text is null
+  or name like concat($4::text, '%')
+  or name like concat('%', $4::text))
+and ($5::text is null or owner = $5::text)
+and ($6::text is null or meta::jsonb @> ($6::text)::jsonb)
+and ($7::text is null or meta::jsonb @? ($7::text)::jsonpath)
+and ($8::timestamptz is null or run_at > $8::timestamptz)
+and ($9::timestamptz is null or run_at < $9::timestamptz)
+and ($10::timestamptz is null or updated_at > $10::timestamptz)
+and ($11::timestamptz is null or updated_at < $11::timestamptz)
+and (updated_at, id) > (coalesce($12::timestamptz, '-infinity'), coalesce($13::bigint, 0))
+order by updated_at, id
+limit $14::int
+`
+
+type ListJobsByUpdatedAtAscParams struct {
+	Executor      string             `json:"executor"`
+	Statuses      pgtype.Text        `json:"statuses"`
+	IsOneShot     pgtype.Bool        `json:"is_one_shot"`
+	Name          pgtype.Text        `json:"name"`
+	Owner         pgtype.Text        `json:"owner"`
+	Meta          pgtype.Text        `json:"meta"`
+	MetaPath      pgtype.Text        `json:"meta_path"`
+	RunAfter      pgtype.Timestamptz `json:"run_after"`
+	RunBefore     pgtype.Timestamptz `json:"run_before"`
+	UpdatedAfter  pgtype.Timestamptz `json:"updated_after"`
+	UpdatedBefore pgtype.Timestamptz `json:"updated_before"`
+	AfterAt       pgtype.Timestamptz `json:"after_at"`
+	AfterID       pgtype.Int8        `json:"after_id"`
+	Limit         int32              `json:"limit"`
+}
+
+// Walks job_executor_updated_at_idx
+func (q *Queries) ListJobsByUpdatedAtAsc(ctx context.Context, arg ListJobsByUpdatedAtAscParams) ([]TinyJob, error) {
+	rows, err := q.db.Query(ctx, listJobsByUpdatedAtAsc,
+		arg.Executor,
+		arg.Statuses,
+		arg.IsOneShot,
+		arg.Name,
+		arg.Owner,
+		arg.Meta,
+		arg.MetaPath,
+		arg.RunAfter,
+		arg.RunBefore,
+		arg.UpdatedAfter,
+		arg.UpdatedBefore,
+		arg.AfterAt,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyJob
+	for rows.Next() {
+		var i TinyJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listJobsByUpdatedAtDesc = `-- name: ListJobsByUpdatedAtDesc :many
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where executor = $1::text
+and ($2::text is null or status::text = any(string_to_array($2::text, ',')))
+and ($3::boolean is null or tiny.is_one_shot(expr) = $3::boolean)
+and ($4::text is null
+  or name like concat($4::text, '%')
+  or name like concat('%', $4::text))
+and ($5::text is null or owner = $5::text)
+and ($6::text is null or meta::jsonb @> ($6::text)::jsonb)
+and ($7::text is null or meta::jsonb @? ($7::text)::jsonpath)
+and ($8::timestamptz is null or run_at > $8::timestamptz)
+and ($9::timestamptz is null or run_at < $9::timestamptz)
+and ($10::timestamptz is null or updated_at > $10::timestamptz)
+and ($11::timestamptz is null or updated_at < $11::timestamptz)
+and (updated_at, id) < (coalesce($12::timestamptz, 'infinity'), coalesce($13::bigint, 0))
+order by updated_at desc, id desc
+limit $14::int
+`
+
+type ListJobsByUpdatedAtDescParams struct {
+	Executor      string             `json:"executor"`
+	Statuses      pgtype.Text        `json:"statuses"`
+	IsOneShot     pgtype.Bool        `json:"is_one_shot"`
+	Name          pgtype.Text        `json:"name"`
+	Owner         pgtype.Text        `json:"owner"`
+	Meta          pgtype.Text        `json:"meta"`
+	MetaPath      pgtype.Text        `json:"meta_path"`
+	RunAfter      pgtype.Timestamptz `json:"run_after"`
+	RunBefore     pgtype.Timestamptz `json:"run_before"`
+	UpdatedAfter  pgtype.Timestamptz `json:"updated_after"`
+	UpdatedBefore pgtype.Timestamptz `json:"updated_before"`
+	AfterAt       pgtype.Timestamptz `json:"after_at"`
+	AfterID       pgtype.Int8        `json:"after_id"`
+	Limit         int32              `json:"limit"`
+}
+
+// Walks job_executor_updated_at_idx
+func (q *Queries) ListJobsByUpdatedAtDesc(ctx context.Context, arg ListJobsByUpdatedAtDescParams) ([]TinyJob, error) {
+	rows, err := q.db.Query(ctx, listJobsByUpdatedAtDesc,
+		arg.Executor,
+		arg.Statuses,
+		arg.IsOneShot,
+		arg.Name,
+		arg.Owner,
+		arg.Meta,
+		arg.MetaPath,
+		arg.RunAfter,
+		arg.RunBefore,
+		arg.UpdatedAfter,
+		arg.UpdatedBefore,
+		arg.AfterAt,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyJob
+	for rows.Next() {
+		var i TinyJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPolicies = `-- name: ListPolicies :many
+select id, owner, role, executor, operation, created_at from tiny.policy
+where owner = coalesce(nullif($1::text, ''), 'default')
+order by role, executor, operation
+`
+
+func (q *Queries) ListPolicies(ctx context.Context, owner string) ([]TinyPolicy, error) {
+	rows, err := q.db.Query(ctx, listPolicies, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyPolicy
+	for rows.Next() {
+		var i TinyPolicy
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Role,
+			&i.Executor,
+			&i.Operation,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listQuarantinedJobs = `-- name: ListQuarantinedJobs :many
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where executor = $1
+and quarantined_at is not null
+order by quarantined_at desc
+limit $2
+offset $3
+`
+
+type ListQuarantinedJobsParams struct {
+	Executor string `json:"executor"`
+	Limit    int32  `json:"limit"`
+	Offset   int32  `json:"offset"`
+}
+
+func (q *Queries) ListQuarantinedJobs(ctx context.Context, arg ListQuarantinedJobsParams) ([]TinyJob, error) {
+	rows, err := q.db.Query(ctx, listQuarantinedJobs, arg.Executor, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyJob
+	for rows.Next() {
+		var i TinyJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRateLimits = `-- name: ListRateLimits :many
+select
+  executor,
+  owner,
+  rate,
+  burst,
+  least(burst, tokens + extract(epoch from now() - updated_at) * rate)::float8 as tokens
+from tiny.rate_limit
+where executor = $1
+order by owner
+`
+
+type ListRateLimitsRow struct {
+	Executor string  `json:"executor"`
+	Owner    string  `json:"owner"`
+	Rate     float64 `json:"rate"`
+	Burst    int32   `json:"burst"`
+	Tokens   float64 `json:"tokens"`
+}
+
+func (q *Queries) ListRateLimits(ctx context.Context, executor string) ([]ListRateLimitsRow, error) {
+	rows, err := q.db.Query(ctx, listRateLimits, executor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRateLimitsRow
+	for rows.Next() {
+		var i ListRateLimitsRow
+		if err := rows.Scan(
+			&i.Executor,
+			&i.Owner,
+			&i.Rate,
+			&i.Burst,
+			&i.Tokens,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkerJobs = `-- name: ListWorkerJobs :many
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
+where locked_by = $1::text
+and executor = $2::text
+and status = 'PENDING'
+order by locked_at
+`
+
+type ListWorkerJobsParams struct {
+	Worker   string `json:"worker"`
+	Executor string `json:"executor"`
+}
+
+func (q *Queries) ListWorkerJobs(ctx context.Context, arg ListWorkerJobsParams) ([]TinyJob, error) {
+	rows, err := q.db.Query(ctx, listWorkerJobs, arg.Worker, arg.Executor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyJob
+	for rows.Next() {
+		var i TinyJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.Expr,
+			&i.RunAt,
+			&i.LastRunAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.StartAt,
+			&i.ExecutionAmount,
+			&i.Retries,
+			&i.Name,
+			&i.Meta,
+			&i.Timeout,
+			&i.Status,
+			&i.State,
+			&i.Executor,
+			&i.Owner,
+			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkers = `-- name: ListWorkers :many
+select id, executor, started_at, heartbeat_at, expires_at from tiny.worker
+where executor = $1
+and expires_at > now()
+order by started_at
+`
+
+func (q *Queries) ListWorkers(ctx context.Context, executor string) ([]TinyWorker, error) {
+	rows, err := q.db.Query(ctx, listWorkers, executor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TinyWorker
+	for rows.Next() {
+		var i TinyWorker
+		if err := rows.Scan(
+			&i.ID,
+			&i.Executor,
+			&i.StartedAt,
+			&i.HeartbeatAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockConcurrencyKeys = `-- name: LockConcurrencyKeys :many
+select l.key::text
+from (
+  select k.key, pg_advisory_xact_lock(hashtextextended(k.key, 0))
+  from (
+    select distinct owner || ':' || concurrency_key as key
+    from tiny.job
+    where executor = $1
+      and status = 'READY'
+      and run_at < now()
+      and concurrency_key is not null
+  ) as k
+  order by k.key
+) as l
+`
+
+// Serializes fetches of due jobs sharing a concurrency key,
+// so that every fetch counts the PENDING jobs committed by
+// the previous one. Keys are locked in order to avoid deadlocks
+// and released when the transaction ends
+func (q *Queries) LockConcurrencyKeys(ctx context.Context, executor string) ([]string, error) {
+	rows, err := q.db.Query(ctx, lockConcurrencyKeys, executor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		items = append(items, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const muteJobNotifications = `-- name: MuteJobNotifications :exec
+select set_config('tiny.mute_notify', 'on', true)
+`
+
+// Inserts of the current transaction are not notified
+func (q *Queries) MuteJobNotifications(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, muteJobNotifications)
+	return err
+}
+
+const next = `-- name: Next :one
+select run_at::timestamptz
+from tiny.next(
+  $1::timestamptz,
+  $2::text
+) as run_at
+`
+
+type NextParams struct {
+	From pgtype.Timestamptz `json:"from"`
+	Expr string             `json:"expr"`
+}
+
+func (q *Queries) Next(ctx context.Context, arg NextParams) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, next, arg.From, arg.Expr)
+	var run_at pgtype.Timestamptz
+	err := row.Scan(&run_at)
+	return run_at, err
+}
+
+const pauseExecutor = `-- name: PauseExecutor :one
+insert into tiny.executor (name, paused, paused_at)
+values ($1, true, now())
+on conflict (name) do update
+set paused = true,
+  paused_at = coalesce(tiny.executor.paused_at, now()),
+  updated_at = now()
+returning name, paused, paused_at, updated_at
+`
+
+func (q *Queries) PauseExecutor(ctx context.Context, name string) (TinyExecutor, error) {
+	row := q.db.QueryRow(ctx, pauseExecutor, name)
+	var i TinyExecutor
+	err := row.Scan(
+		&i.Name,
+		&i.Paused,
+		&i.PausedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const pauseJobsByFilter = `-- name: PauseJobsByFilter :execrows
+update tiny.job
+set status = 'PAUSED',
+  updated_at = now()
+where executor = $1::text
+and ($2::text is null or meta::jsonb @> ($2::text)::jsonb)
+and ($3::text is null or status::text = any(string_to_array($3::text, ',')))
+and ($4::timestamptz is null or created_at > $4::timestamptz)
+and ($5::timestamptz is null or created_at < $5::timestamptz)
+and ($6::text is null
+  or name like concat($6::text, '%')
+  or name like concat('%', $6::text))
+and ($7::boolean is null or tiny.is_one_shot(expr) = $7::boolean)
+and status = 'READY'
+`
+
+type PauseJobsByFilterParams struct {
+	Executor  string             `json:"executor"`
+	Query     pgtype.Text        `json:"query"`
+	Statuses  pgtype.Text        `json:"statuses"`
+	From      pgtype.Timestamptz `json:"from"`
+	To        pgtype.Timestamptz `json:"to"`
+	Name      pgtype.Text        `json:"name"`
+	IsOneShot pgtype.Bool        `json:"is_one_shot"`
+}
+
+func (q *Queries) PauseJobsByFilter(ctx context.Context, arg PauseJobsByFilterParams) (int64, error) {
+	result, err := q.db.Exec(ctx, pauseJobsByFilter,
+		arg.Executor,
+		arg.Query,
+		arg.Statuses,
+		arg.From,
+		arg.To,
+		arg.Name,
+		arg.IsOneShot,
 	)
-	return i, err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
-const deleteJobByName = `-- name: DeleteJobByName :one
-delete from tiny.job
-where name = $1
-and executor = $2 
-returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key
+const pruneJobRuns = `-- name: PruneJobRuns :execrows
+delete from tiny.job_run
+where executor = $1::text
+and finished_at < now() - make_interval(secs => $2::int)
 `
 
-type DeleteJobByNameParams struct {
-	Name     string `json:"name"`
-	Executor string `json:"executor"`
+type PruneJobRunsParams struct {
+	Executor  string `json:"executor"`
+	Retention int32  `json:"retention"`
 }
 
-func (q *Queries) DeleteJobByName(ctx context.Context, arg DeleteJobByNameParams) (TinyJob, error) {
-	row := q.db.QueryRow(ctx, deleteJobByName, arg.Name, arg.Executor)
-	var i TinyJob
-	err := row.Scan(
-		&i.ID,
-		&i.Expr,
-		&i.RunAt,
-		&i.LastRunAt,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.StartAt,
-		&i.ExecutionAmount,
-		&i.Retries,
-		&i.Name,
-		&i.Meta,
-		&i.Timeout,
-		&i.Status,
-		&i.State,
-		&i.Executor,
-		&i.Owner,
-		&i.DeduplicationKey,
-	)
-	return i, err
+func (q *Queries) PruneJobRuns(ctx context.Context, arg PruneJobRunsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, pruneJobRuns, arg.Executor, arg.Retention)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
-const fetchDueJobs = `-- name: FetchDueJobs :many
-with due_jobs as (
-  select id
-  from tiny.job j
-  where j.run_at < now()
-    and j.status = 'READY'
-    and j.executor = $2
-  order by j.created_at
-  limit $1
-  for update skip locked
-)
-update tiny.job as updated_jobs
-set status = 'PENDING',
-  updated_at = now(),
-  last_run_at = now()
-from due_jobs
-where due_jobs.id = updated_jobs.id
-returning updated_jobs.id, updated_jobs.expr, updated_jobs.run_at, updated_jobs.last_run_at, updated_jobs.created_at, updated_jobs.updated_at, updated_jobs.start_at, updated_jobs.execution_amount, updated_jobs.retries, updated_jobs.name, updated_jobs.meta, updated_jobs.timeout, updated_jobs.status, updated_jobs.state, updated_jobs.executor, updated_jobs.owner, updated_jobs.deduplication_key
+const refillRateLimits = `-- name: RefillRateLimits :many
+update tiny.rate_limit
+set tokens = least(burst, tokens + extract(epoch from now() - updated_at) * rate),
+  updated_at = now()
+where executor = $1
+returning executor, owner, rate, burst, tokens, updated_at
 `
 
-type FetchDueJobsParams struct {
-	Limit    int32  `json:"limit"`
-	Executor string `json:"executor"`
-}
-
-func (q *Queries) FetchDueJobs(ctx context.Context, arg FetchDueJobsParams) ([]TinyJob, error) {
-	rows, err := q.db.Query(ctx, fetchDueJobs, arg.Limit, arg.Executor)
+func (q *Queries) RefillRateLimits(ctx context.Context, executor string) ([]TinyRateLimit, error) {
+	rows, err := q.db.Query(ctx, refillRateLimits, executor)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []TinyJob
+	var items []TinyRateLimit
 	for rows.Next() {
-		var i TinyJob
+		var i TinyRateLimit
 		if err := rows.Scan(
-			&i.ID,
-			&i.Expr,
-			&i.RunAt,
-			&i.LastRunAt,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.StartAt,
-			&i.ExecutionAmount,
-			&i.Retries,
-			&i.Name,
-			&i.Meta,
-			&i.Timeout,
-			&i.Status,
-			&i.State,
 			&i.Executor,
 			&i.Owner,
-			&i.DeduplicationKey,
+			&i.Rate,
+			&i.Burst,
+			&i.Tokens,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -256,57 +2640,81 @@ func (q *Queries) FetchDueJobs(ctx context.Context, arg FetchDueJobsParams) ([]T
 	return items, nil
 }
 
-const getJobByID = `-- name: GetJobByID :one
-select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key from tiny.job
-where id = $1
-and executor = $2 
-limit 1
+const releaseOrphanedJobs = `-- name: ReleaseOrphanedJobs :many
+update tiny.job j
+set status = case
+    when j.cancel_requested_at is not null then 'CANCELLED'::tiny.status
+    when $1::int > 0 and j.reset_count + 1 >= $1::int then 'FAILURE'::tiny.status
+    else 'READY'::tiny.status
+  end,
+  quarantined_at = case
+    when j.cancel_requested_at is null and $1::int > 0 and j.reset_count + 1 >= $1::int then now()
+  end,
+  updated_at = now(),
+  lease = lease + 1,
+  reset_count = reset_count + 1,
+  locked_by = null,
+  locked_at = null
+where j.executor = $2
+and j.status = 'PENDING'
+and j.locked_by is not null
+and exists (
+  select 1 from tiny.worker w
+  where w.id = j.locked_by
+  and w.executor = j.executor
+  and w.expires_at <= now()
+)
+returning j.id
 `
 
-type GetJobByIDParams struct {
-	ID       int64  `json:"id"`
-	Executor string `json:"executor"`
+type ReleaseOrphanedJobsParams struct {
+	MaxResets int32  `json:"max_resets"`
+	Executor  string `json:"executor"`
 }
 
-func (q *Queries) GetJobByID(ctx context.Context, arg GetJobByIDParams) (TinyJob, error) {
-	row := q.db.QueryRow(ctx, getJobByID, arg.ID, arg.Executor)
-	var i TinyJob
-	err := row.Scan(
-		&i.ID,
-		&i.Expr,
-		&i.RunAt,
-		&i.LastRunAt,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.StartAt,
-		&i.ExecutionAmount,
-		&i.Retries,
-		&i.Name,
-		&i.Meta,
-		&i.Timeout,
-		&i.Status,
-		&i.State,
-		&i.Executor,
-		&i.Owner,
-		&i.DeduplicationKey,
-	)
-	return i, err
+// Jobs held by a registered worker that stopped heartbeating
+// are released without waiting for their timeout. Jobs held
+// by workers that never heartbeat are left to their timeout
+func (q *Queries) ReleaseOrphanedJobs(ctx context.Context, arg ReleaseOrphanedJobsParams) ([]int64, error) {
+	rows, err := q.db.Query(ctx, releaseOrphanedJobs, arg.MaxResets, arg.Executor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getJobByName = `-- name: GetJobByName :one
-select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key from tiny.job
-where name = $1 
+const requeueJob = `-- name: RequeueJob :one
+update tiny.job
+set status = 'READY',
+  quarantined_at = null,
+  reset_count = 0,
+  run_at = now(),
+  updated_at = now()
+where id = $1
 and executor = $2
-limit 1
+and quarantined_at is not null
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
 `
 
-type GetJobByNameParams struct {
-	Name     string `json:"name"`
+type RequeueJobParams struct {
+	ID       int64  `json:"id"`
 	Executor string `json:"executor"`
 }
 
-func (q *Queries) GetJobByName(ctx context.Context, arg GetJobByNameParams) (TinyJob, error) {
-	row := q.db.QueryRow(ctx, getJobByName, arg.Name, arg.Executor)
+func (q *Queries) RequeueJob(ctx context.Context, arg RequeueJobParams) (TinyJob, error) {
+	row := q.db.QueryRow(ctx, requeueJob, arg.ID, arg.Executor)
 	var i TinyJob
 	err := row.Scan(
 		&i.ID,
@@ -326,57 +2734,50 @@ func (q *Queries) GetJobByName(ctx context.Context, arg GetJobByNameParams) (Tin
 		&i.Executor,
 		&i.Owner,
 		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
 	)
 	return i, err
 }
 
-const lastUpdate = `-- name: LastUpdate :one
-select max(updated_at)::timestamptz as last_update 
-from tiny.job
-where executor = $1
-`
-
-func (q *Queries) LastUpdate(ctx context.Context, executor string) (pgtype.Timestamptz, error) {
-	row := q.db.QueryRow(ctx, lastUpdate, executor)
-	var last_update pgtype.Timestamptz
-	err := row.Scan(&last_update)
-	return last_update, err
-}
-
-const next = `-- name: Next :one
-select run_at::timestamptz
-from tiny.next(
-  $1::timestamptz,
-  $2::text
-) as run_at
-`
-
-type NextParams struct {
-	From pgtype.Timestamptz `json:"from"`
-	Expr string             `json:"expr"`
-}
-
-func (q *Queries) Next(ctx context.Context, arg NextParams) (pgtype.Timestamptz, error) {
-	row := q.db.QueryRow(ctx, next, arg.From, arg.Expr)
-	var run_at pgtype.Timestamptz
-	err := row.Scan(&run_at)
-	return run_at, err
-}
-
 const resetTimeoutJobs = `-- name: ResetTimeoutJobs :many
 update tiny.job
-set status = 'READY',
-  updated_at = now()
+set status = case
+    when cancel_requested_at is not null then 'CANCELLED'::tiny.status
+    when $1::int > 0 and reset_count + 1 >= $1::int then 'FAILURE'::tiny.status
+    else 'READY'::tiny.status
+  end,
+  quarantined_at = case
+    when cancel_requested_at is null and $1::int > 0 and reset_count + 1 >= $1::int then now()
+  end,
+  updated_at = now(),
+  lease = lease + 1,
+  reset_count = reset_count + 1,
+  locked_by = null,
+  locked_at = null
 where timeout is not null
 and timeout > 0
 and now() - last_run_at > make_interval(secs => timeout)
-and executor = $1
+and executor = $2
 and status = 'PENDING'
 returning id
 `
 
-func (q *Queries) ResetTimeoutJobs(ctx context.Context, executor string) ([]int64, error) {
-	rows, err := q.db.Query(ctx, resetTimeoutJobs, executor)
+type ResetTimeoutJobsParams struct {
+	MaxResets int32  `json:"max_resets"`
+	Executor  string `json:"executor"`
+}
+
+// Jobs reset `max_resets` times in a row are quarantined.
+// Quarantine is disabled when `max_resets` is zero
+func (q *Queries) ResetTimeoutJobs(ctx context.Context, arg ResetTimeoutJobsParams) ([]int64, error) {
+	rows, err := q.db.Query(ctx, resetTimeoutJobs, arg.MaxResets, arg.Executor)
 	if err != nil {
 		return nil, err
 	}
@@ -395,14 +2796,83 @@ func (q *Queries) ResetTimeoutJobs(ctx context.Context, executor string) ([]int6
 	return items, nil
 }
 
-const restartJob = `-- name: RestartJob :one
+const resumeExecutor = `-- name: ResumeExecutor :one
+insert into tiny.executor (name, paused)
+values ($1, false)
+on conflict (name) do update
+set paused = false,
+  paused_at = null,
+  updated_at = now()
+returning name, paused, paused_at, updated_at
+`
+
+func (q *Queries) ResumeExecutor(ctx context.Context, name string) (TinyExecutor, error) {
+	row := q.db.QueryRow(ctx, resumeExecutor, name)
+	var i TinyExecutor
+	err := row.Scan(
+		&i.Name,
+		&i.Paused,
+		&i.PausedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const resumeJobsByFilter = `-- name: ResumeJobsByFilter :execrows
 update tiny.job
 set status = 'READY',
-  updated_at = now() 
+  updated_at = now()
+where executor = $1::text
+and ($2::text is null or meta::jsonb @> ($2::text)::jsonb)
+and ($3::text is null or status::text = any(string_to_array($3::text, ',')))
+and ($4::timestamptz is null or created_at > $4::timestamptz)
+and ($5::timestamptz is null or created_at < $5::timestamptz)
+and ($6::text is null
+  or name like concat($6::text, '%')
+  or name like concat('%', $6::text))
+and ($7::boolean is null or tiny.is_one_shot(expr) = $7::boolean)
+and status = 'PAUSED'
+`
+
+type ResumeJobsByFilterParams struct {
+	Executor  string             `json:"executor"`
+	Query     pgtype.Text        `json:"query"`
+	Statuses  pgtype.Text        `json:"statuses"`
+	From      pgtype.Timestamptz `json:"from"`
+	To        pgtype.Timestamptz `json:"to"`
+	Name      pgtype.Text        `json:"name"`
+	IsOneShot pgtype.Bool        `json:"is_one_shot"`
+}
+
+func (q *Queries) ResumeJobsByFilter(ctx context.Context, arg ResumeJobsByFilterParams) (int64, error) {
+	result, err := q.db.Exec(ctx, resumeJobsByFilter,
+		arg.Executor,
+		arg.Query,
+		arg.Statuses,
+		arg.From,
+		arg.To,
+		arg.Name,
+		arg.IsOneShot,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restartJob = `-- name: RestartJob :one
+update tiny.job
+set status = case
+    when status = 'PENDING' then status
+    else 'READY'::tiny.status
+  end,
+  cancel_requested_at = null,
+  updated_at = now()
 where id = $1
 and executor = $2
-and status = 'PAUSED'
-returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key
+and (status in ('PAUSED', 'CANCELLED')
+  or (status = 'PENDING' and cancel_requested_at is not null))
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
 `
 
 type RestartJobParams struct {
@@ -410,6 +2880,8 @@ type RestartJobParams struct {
 	Executor string `json:"executor"`
 }
 
+// Restarting a cancelled job, or a running one whose cancel
+// was not acknowledged yet, withdraws the cancellation
 func (q *Queries) RestartJob(ctx context.Context, arg RestartJobParams) (TinyJob, error) {
 	row := q.db.QueryRow(ctx, restartJob, arg.ID, arg.Executor)
 	var i TinyJob
@@ -431,12 +2903,52 @@ func (q *Queries) RestartJob(ctx context.Context, arg RestartJobParams) (TinyJob
 		&i.Executor,
 		&i.Owner,
 		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
+	)
+	return i, err
+}
+
+const revokeApiKey = `-- name: RevokeApiKey :one
+update tiny.api_key
+set revoked_at = coalesce(revoked_at, now())
+where id = $1::bigint
+and owner = coalesce(nullif($2::text, ''), 'default')
+returning id, name, prefix, hash, owner, scopes, executors, created_at, expires_at, revoked_at, roles
+`
+
+type RevokeApiKeyParams struct {
+	ID    int64  `json:"id"`
+	Owner string `json:"owner"`
+}
+
+func (q *Queries) RevokeApiKey(ctx context.Context, arg RevokeApiKeyParams) (TinyApiKey, error) {
+	row := q.db.QueryRow(ctx, revokeApiKey, arg.ID, arg.Owner)
+	var i TinyApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.Hash,
+		&i.Owner,
+		&i.Scopes,
+		&i.Executors,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.Roles,
 	)
 	return i, err
 }
 
 const searchJobs = `-- name: SearchJobs :many
-select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key from tiny.job
+select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
 where (name like concat($4::text, '%')
   or name like concat('%', $4::text))
 and executor = $3 
@@ -483,6 +2995,14 @@ func (q *Queries) SearchJobs(ctx context.Context, arg SearchJobsParams) ([]TinyJ
 			&i.Executor,
 			&i.Owner,
 			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -496,7 +3016,7 @@ func (q *Queries) SearchJobs(ctx context.Context, arg SearchJobsParams) ([]TinyJ
 
 const searchJobsByMeta = `-- name: SearchJobsByMeta :many
 with jobs as (
-  select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key from tiny.job
+  select id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at from tiny.job
   where meta::jsonb @> ($3::text)::jsonb
   and status::text = any(string_to_array($4::text, ','))
   and created_at > $5::timestamptz
@@ -510,7 +3030,7 @@ with jobs as (
 total as (
   select count(*) as total_count from jobs
 )
-select jobs.id, jobs.expr, jobs.run_at, jobs.last_run_at, jobs.created_at, jobs.updated_at, jobs.start_at, jobs.execution_amount, jobs.retries, jobs.name, jobs.meta, jobs.timeout, jobs.status, jobs.state, jobs.executor, jobs.owner, jobs.deduplication_key, total_count from jobs, total
+select jobs.id, jobs.expr, jobs.run_at, jobs.last_run_at, jobs.created_at, jobs.updated_at, jobs.start_at, jobs.execution_amount, jobs.retries, jobs.name, jobs.meta, jobs.timeout, jobs.status, jobs.state, jobs.executor, jobs.owner, jobs.deduplication_key, jobs.concurrency_key, jobs.concurrency_limit, jobs.lease, jobs.locked_by, jobs.locked_at, jobs.cancel_requested_at, jobs.reset_count, jobs.quarantined_at, total_count from jobs, total
 order by last_run_at desc
 limit $2::int
 offset $1::int
@@ -529,24 +3049,32 @@ type SearchJobsByMetaParams struct {
 }
 
 type SearchJobsByMetaRow struct {
-	ID               int64              `json:"id"`
-	Expr             string             `json:"expr"`
-	RunAt            pgtype.Timestamptz `json:"run_at"`
-	LastRunAt        pgtype.Timestamptz `json:"last_run_at"`
-	CreatedAt        pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
-	StartAt          pgtype.Timestamptz `json:"start_at"`
-	ExecutionAmount  int32              `json:"execution_amount"`
-	Retries          int32              `json:"retries"`
-	Name             string             `json:"name"`
-	Meta             []byte             `json:"meta"`
-	Timeout          int32              `json:"timeout"`
-	Status           TinyStatus         `json:"status"`
-	State            string             `json:"state"`
-	Executor         string             `json:"executor"`
-	Owner            string             `json:"owner"`
-	DeduplicationKey pgtype.Text        `json:"deduplication_key"`
-	TotalCount       int64              `json:"total_count"`
+	ID                int64              `json:"id"`
+	Expr              string             `json:"expr"`
+	RunAt             pgtype.Timestamptz `json:"run_at"`
+	LastRunAt         pgtype.Timestamptz `json:"last_run_at"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+	StartAt           pgtype.Timestamptz `json:"start_at"`
+	ExecutionAmount   int32              `json:"execution_amount"`
+	Retries           int32              `json:"retries"`
+	Name              string             `json:"name"`
+	Meta              []byte             `json:"meta"`
+	Timeout           int32              `json:"timeout"`
+	Status            TinyStatus         `json:"status"`
+	State             string             `json:"state"`
+	Executor          string             `json:"executor"`
+	Owner             string             `json:"owner"`
+	DeduplicationKey  pgtype.Text        `json:"deduplication_key"`
+	ConcurrencyKey    pgtype.Text        `json:"concurrency_key"`
+	ConcurrencyLimit  int32              `json:"concurrency_limit"`
+	Lease             int32              `json:"lease"`
+	LockedBy          pgtype.Text        `json:"locked_by"`
+	LockedAt          pgtype.Timestamptz `json:"locked_at"`
+	CancelRequestedAt pgtype.Timestamptz `json:"cancel_requested_at"`
+	ResetCount        int32              `json:"reset_count"`
+	QuarantinedAt     pgtype.Timestamptz `json:"quarantined_at"`
+	TotalCount        int64              `json:"total_count"`
 }
 
 func (q *Queries) SearchJobsByMeta(ctx context.Context, arg SearchJobsByMetaParams) ([]SearchJobsByMetaRow, error) {
@@ -586,6 +3114,14 @@ func (q *Queries) SearchJobsByMeta(ctx context.Context, arg SearchJobsByMetaPara
 			&i.Executor,
 			&i.Owner,
 			&i.DeduplicationKey,
+			&i.ConcurrencyKey,
+			&i.ConcurrencyLimit,
+			&i.Lease,
+			&i.LockedBy,
+			&i.LockedAt,
+			&i.CancelRequestedAt,
+			&i.ResetCount,
+			&i.QuarantinedAt,
 			&i.TotalCount,
 		); err != nil {
 			return nil, err
@@ -598,6 +3134,49 @@ func (q *Queries) SearchJobsByMeta(ctx context.Context, arg SearchJobsByMetaPara
 	return items, nil
 }
 
+const setOwnerWeight = `-- name: SetOwnerWeight :exec
+insert into tiny.owner_weight (owner, weight)
+values ($1, $2)
+on conflict (owner) do update
+set weight = excluded.weight
+`
+
+type SetOwnerWeightParams struct {
+	Owner  string `json:"owner"`
+	Weight int32  `json:"weight"`
+}
+
+func (q *Queries) SetOwnerWeight(ctx context.Context, arg SetOwnerWeightParams) error {
+	_, err := q.db.Exec(ctx, setOwnerWeight, arg.Owner, arg.Weight)
+	return err
+}
+
+const setRateLimit = `-- name: SetRateLimit :exec
+insert into tiny.rate_limit (executor, owner, rate, burst, tokens)
+values ($1, $2, $3, $4, $4)
+on conflict (executor, owner) do update
+set rate = excluded.rate,
+  burst = excluded.burst,
+  tokens = least(tiny.rate_limit.tokens, excluded.burst)
+`
+
+type SetRateLimitParams struct {
+	Executor string  `json:"executor"`
+	Owner    string  `json:"owner"`
+	Rate     float64 `json:"rate"`
+	Burst    int32   `json:"burst"`
+}
+
+func (q *Queries) SetRateLimit(ctx context.Context, arg SetRateLimitParams) error {
+	_, err := q.db.Exec(ctx, setRateLimit,
+		arg.Executor,
+		arg.Owner,
+		arg.Rate,
+		arg.Burst,
+	)
+	return err
+}
+
 const stopJob = `-- name: StopJob :one
 update tiny.job
 set status = 'PAUSED',
@@ -605,7 +3184,7 @@ set status = 'PAUSED',
 where id = $1
 and executor = $2
 and status not in ('FAILURE', 'SUCCESS', 'PENDING')
-returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
 `
 
 type StopJobParams struct {
@@ -636,17 +3215,38 @@ func (q *Queries) StopJob(ctx context.Context, arg StopJobParams) (TinyJob, erro
 		&i.Executor,
 		&i.Owner,
 		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
 	)
 	return i, err
 }
 
+const tryAdvisoryLock = `-- name: TryAdvisoryLock :one
+select pg_try_advisory_lock(hashtext('qron'), hashtext($1::text)) as locked
+`
+
+// Locks are namespaced not to clash with advisory
+// locks taken by applications sharing the database
+func (q *Queries) TryAdvisoryLock(ctx context.Context, name string) (bool, error) {
+	row := q.db.QueryRow(ctx, tryAdvisoryLock, name)
+	var locked bool
+	err := row.Scan(&locked)
+	return locked, err
+}
+
 const updateExprByID = `-- name: UpdateExprByID :one
 update tiny.job
 set expr = coalesce(nullif($3, ''), expr),
   updated_at = now()
 where id = $1
 and executor = $2 
-returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
 `
 
 type UpdateExprByIDParams struct {
@@ -676,6 +3276,14 @@ func (q *Queries) UpdateExprByID(ctx context.Context, arg UpdateExprByIDParams)
 		&i.Executor,
 		&i.Owner,
 		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
 	)
 	return i, err
 }
@@ -693,7 +3301,7 @@ set expr = coalesce(nullif($3, ''), expr),
   )
 where id = $1
 and executor = $2 
-returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
 `
 
 type UpdateJobByIDParams struct {
@@ -731,6 +3339,14 @@ func (q *Queries) UpdateJobByID(ctx context.Context, arg UpdateJobByIDParams) (T
 		&i.Executor,
 		&i.Owner,
 		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
 	)
 	return i, err
 }
@@ -748,7 +3364,7 @@ set expr = coalesce(nullif($3, ''), expr),
   )
 where name = $1
 and executor = $2 
-returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
 `
 
 type UpdateJobByNameParams struct {
@@ -786,6 +3402,14 @@ func (q *Queries) UpdateJobByName(ctx context.Context, arg UpdateJobByNameParams
 		&i.Executor,
 		&i.Owner,
 		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
 	)
 	return i, err
 }
@@ -796,7 +3420,7 @@ set state = coalesce(nullif($3, ''), state),
   updated_at = now()
 where id = $1
 and executor = $2 
-returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key
+returning id, expr, run_at, last_run_at, created_at, updated_at, start_at, execution_amount, retries, name, meta, timeout, status, state, executor, owner, deduplication_key, concurrency_key, concurrency_limit, lease, locked_by, locked_at, cancel_requested_at, reset_count, quarantined_at
 `
 
 type UpdateStateByIDParams struct {
@@ -826,6 +3450,14 @@ func (q *Queries) UpdateStateByID(ctx context.Context, arg UpdateStateByIDParams
 		&i.Executor,
 		&i.Owner,
 		&i.DeduplicationKey,
+		&i.ConcurrencyKey,
+		&i.ConcurrencyLimit,
+		&i.Lease,
+		&i.LockedBy,
+		&i.LockedAt,
+		&i.CancelRequestedAt,
+		&i.ResetCount,
+		&i.QuarantinedAt,
 	)
 	return i, err
 }
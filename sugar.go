@@ -33,12 +33,15 @@ func (j Scheduled[T]) fork() Scheduled[T] {
 		State:        j.State,
 		client:       j.client,
 		args: model.CreateJobArgs{
-			Name:             j.args.Name,
-			Expr:             j.args.Expr,
-			Timeout:          j.args.Timeout,
-			StartAt:          j.args.StartAt,
-			Retries:          j.args.Retries,
-			DeduplicationKey: j.args.DeduplicationKey,
+			Name:                j.args.Name,
+			Expr:                j.args.Expr,
+			Timeout:             j.args.Timeout,
+			StartAt:             j.args.StartAt,
+			Retries:             j.args.Retries,
+			DeduplicationKey:    j.args.DeduplicationKey,
+			DeduplicationWindow: j.args.DeduplicationWindow,
+			ConcurrencyKey:      j.args.ConcurrencyKey,
+			ConcurrencyLimit:    j.args.ConcurrencyLimit,
 		},
 	}
 }
@@ -48,6 +51,20 @@ func (j Scheduled[T]) DeduplicationKey(key string) Scheduled[T] {
 	return j.fork()
 }
 
+// DeduplicationWindow keeps the key taken for `window` minutes after the job finished.
+func (j Scheduled[T]) DeduplicationWindow(window int) Scheduled[T] {
+	j.args.DeduplicationWindow = &window
+	return j.fork()
+}
+
+// ConcurrencyKey limits the jobs sharing `key` to at most
+// `limit` running at the same time, across executors.
+func (j Scheduled[T]) ConcurrencyKey(key string, limit int) Scheduled[T] {
+	j.args.ConcurrencyKey = &key
+	j.args.ConcurrencyLimit = &limit
+	return j.fork()
+}
+
 func (j Scheduled[T]) Name(name string) Scheduled[T] {
 	j.args.Name = name
 	return j.fork()
@@ -73,22 +90,46 @@ func (j Scheduled[T]) Retries(retries int) Scheduled[T] {
 	return j.fork()
 }
 
-func (j Scheduled[T]) Schedule(ctx context.Context, state T) (sqlc.TinyJob, error) {
+func (j Scheduled[T]) createArgs(state T) (model.CreateJobArgs, error) {
 	// TODO: use bytea and encode/decode using gob
 	buf, err := json.Marshal(state)
+	if err != nil {
+		return model.CreateJobArgs{}, err
+	}
+
+	return model.CreateJobArgs{
+		Name:                j.args.Name,
+		Expr:                j.args.Expr,
+		Timeout:             j.args.Timeout,
+		StartAt:             j.args.StartAt,
+		Retries:             j.args.Retries,
+		State:               string(buf),
+		DeduplicationKey:    j.args.DeduplicationKey,
+		DeduplicationWindow: j.args.DeduplicationWindow,
+		ConcurrencyKey:      j.args.ConcurrencyKey,
+		ConcurrencyLimit:    j.args.ConcurrencyLimit,
+	}, nil
+}
+
+func (j Scheduled[T]) Schedule(ctx context.Context, state T) (sqlc.TinyJob, error) {
+	args, err := j.createArgs(state)
+	if err != nil {
+		return sqlc.TinyJob{}, err
+	}
+
+	return j.client.CreateJob(ctx, j.ExecutorName, args)
+}
+
+// Upsert schedules a named job, resolving conflicts with an already
+// registered job of the same name according to `mode`.
+// It is useful for registering cron jobs on every boot.
+func (j Scheduled[T]) Upsert(ctx context.Context, state T, mode model.UpsertMode) (sqlc.TinyJob, error) {
+	args, err := j.createArgs(state)
 	if err != nil {
 		return sqlc.TinyJob{}, err
 	}
 
-	return j.client.CreateJob(ctx, j.ExecutorName, model.CreateJobArgs{
-		Name:             j.args.Name,
-		Expr:             j.args.Expr,
-		Timeout:          j.args.Timeout,
-		StartAt:          j.args.StartAt,
-		Retries:          j.args.Retries,
-		State:            string(buf),
-		DeduplicationKey: j.args.DeduplicationKey,
-	})
+	return j.client.CreateOrUpdateJob(ctx, j.ExecutorName, args, mode)
 }
 
 type ScheduledJob[T any] struct {
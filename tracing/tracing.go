@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const name = "github.com/lucagez/qron"
+
+// metaKey is the job meta field holding the
+// trace context of the request creating the job
+const metaKey = "_trace"
+
+// Trace context is always propagated in W3C format,
+// regardless of the globally configured propagator
+var propagator = propagation.TraceContext{}
+
+// Tracer returns the qron tracer from the global provider.
+// Spans are dropped until a provider is registered.
+func Tracer() trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// Inject captures the trace context of ctx into job `meta`.
+// Meta is returned unchanged when ctx carries no span or
+// when meta is not a json object.
+func Inject(ctx context.Context, meta []byte) []byte {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return meta
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(meta, &fields); err != nil {
+		return meta
+	}
+	trace, err := json.Marshal(carrier)
+	if err != nil {
+		return meta
+	}
+	fields[metaKey] = trace
+
+	buf, err := json.Marshal(fields)
+	if err != nil {
+		return meta
+	}
+	return buf
+}
+
+// Extract restores the trace context captured in job `meta`
+// as the remote parent of ctx.
+func Extract(ctx context.Context, meta []byte) context.Context {
+	var fields struct {
+		Trace propagation.MapCarrier `json:"_trace"`
+	}
+	if err := json.Unmarshal(meta, &fields); err != nil || fields.Trace == nil {
+		return ctx
+	}
+	return propagator.Extract(ctx, fields.Trace)
+}
+
+// InjectHeaders adds the `traceparent` header of ctx to `header`
+func InjectHeaders(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Middleware continues the trace of incoming requests
+// carrying a `traceparent` header
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
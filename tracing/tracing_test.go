@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPropagation(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer("test").Start(context.Background(), "create")
+	defer span.End()
+
+	t.Run("Should capture trace context into meta", func(t *testing.T) {
+		meta := Inject(ctx, []byte(`{"url":"http://localhost"}`))
+
+		var fields map[string]interface{}
+		assert.Nil(t, json.Unmarshal(meta, &fields))
+		assert.Equal(t, "http://localhost", fields["url"])
+		assert.Contains(t, fields, "_trace")
+
+		restored := trace.SpanContextFromContext(Extract(context.Background(), meta))
+		assert.True(t, restored.IsRemote())
+		assert.Equal(t, span.SpanContext().TraceID(), restored.TraceID())
+		assert.Equal(t, span.SpanContext().SpanID(), restored.SpanID())
+	})
+
+	t.Run("Should leave meta untouched without span", func(t *testing.T) {
+		meta := Inject(context.Background(), []byte(`{"url":"http://localhost"}`))
+		assert.Equal(t, `{"url":"http://localhost"}`, string(meta))
+
+		meta = Inject(ctx, []byte(`[1, 2]`))
+		assert.Equal(t, `[1, 2]`, string(meta))
+
+		restored := trace.SpanContextFromContext(Extract(context.Background(), []byte(`{}`)))
+		assert.False(t, restored.IsValid())
+	})
+
+	t.Run("Should inject traceparent header", func(t *testing.T) {
+		header := http.Header{}
+		InjectHeaders(ctx, header)
+		assert.Contains(t, header.Get("traceparent"), span.SpanContext().TraceID().String())
+	})
+}